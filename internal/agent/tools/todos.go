@@ -1,12 +1,21 @@
 package tools
 
 import (
+	"cmp"
 	"context"
 	_ "embed"
 	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/session"
+	"github.com/charmbracelet/crush/internal/vcs"
 )
 
 //go:embed todos.md
@@ -19,9 +28,13 @@ type TodosParams struct {
 }
 
 type TodoItem struct {
-	Content    string `json:"content" description:"What needs to be done (imperative form)"`
-	Status     string `json:"status" description:"Task status: pending, in_progress, or completed"`
-	ActiveForm string `json:"active_form" description:"Present continuous form (e.g., 'Running tests')"`
+	ID         string   `json:"id,omitempty" description:"Optional identifier other todos can reference as their parent_id"`
+	ParentID   string   `json:"parent_id,omitempty" description:"Optional id of the todo this is a subtask of"`
+	Content    string   `json:"content" description:"What needs to be done (imperative form)"`
+	Status     string   `json:"status" description:"Task status: pending, in_progress, or completed"`
+	ActiveForm string   `json:"active_form" description:"Present continuous form (e.g., 'Running tests')"`
+	Files      []string `json:"files,omitempty" description:"Paths of files this task touches (optional)"`
+	Category   string   `json:"category,omitempty" description:"Short label grouping related tasks, e.g. 'tests' or 'docs' (optional)"`
 }
 
 type TodosResponseMetadata struct {
@@ -31,9 +44,10 @@ type TodosResponseMetadata struct {
 	JustStarted   string         `json:"just_started,omitempty"`
 	Completed     int            `json:"completed"`
 	Total         int            `json:"total"`
+	Churn         int            `json:"churn"`
 }
 
-func NewTodosTool(sessions session.Service) fantasy.AgentTool {
+func NewTodosTool(sessions session.Service, workingDir string, todosConfig config.ToolTodos) fantasy.AgentTool {
 	return fantasy.NewAgentTool(
 		TodosToolName,
 		string(todosDescription),
@@ -49,38 +63,51 @@ func NewTodosTool(sessions session.Service) fantasy.AgentTool {
 			}
 
 			isNew := len(currentSession.Todos) == 0
-			oldStatusByContent := make(map[string]session.TodoStatus)
+			oldByContent := make(map[string]session.Todo)
 			for _, todo := range currentSession.Todos {
-				oldStatusByContent[todo.Content] = todo.Status
+				oldByContent[todo.Content] = todo
 			}
 
-			for _, item := range params.Todos {
-				switch item.Status {
-				case "pending", "in_progress", "completed":
-				default:
-					return fantasy.ToolResponse{}, fmt.Errorf("invalid status %q for todo %q", item.Status, item.Content)
-				}
+			if err := ValidateTodos(params.Todos); err != nil {
+				return fantasy.NewTextErrorResponse(err.Error()), nil
+			}
+
+			if err := checkTodosLimit(len(params.Todos), todosConfig); err != nil {
+				return fantasy.NewTextErrorResponse(err.Error()), nil
+			}
+
+			if err := checkActiveForms(params.Todos, todosConfig); err != nil {
+				return fantasy.NewTextErrorResponse(err.Error()), nil
 			}
 
 			todos := make([]session.Todo, len(params.Todos))
-			var justCompleted []string
+			var newlyCompleted []int
 			var justStarted string
 			completedCount := 0
+			now := time.Now().Unix()
 
 			for i, item := range params.Todos {
 				todos[i] = session.Todo{
+					ID:         item.ID,
+					ParentID:   item.ParentID,
 					Content:    item.Content,
 					Status:     session.TodoStatus(item.Status),
 					ActiveForm: item.ActiveForm,
+					Files:      item.Files,
+					Category:   item.Category,
 				}
 
 				newStatus := session.TodoStatus(item.Status)
-				oldStatus, existed := oldStatusByContent[item.Content]
+				old, existed := oldByContent[item.Content]
+				oldStatus := old.Status
 
 				if newStatus == session.TodoStatusCompleted {
 					completedCount++
-					if existed && oldStatus != session.TodoStatusCompleted {
-						justCompleted = append(justCompleted, item.Content)
+					if existed && oldStatus == session.TodoStatusCompleted {
+						todos[i].CompletedAt = cmp.Or(old.CompletedAt, now)
+						todos[i].CompletedSeq = old.CompletedSeq
+					} else {
+						newlyCompleted = append(newlyCompleted, i)
 					}
 				}
 
@@ -95,40 +122,289 @@ func NewTodosTool(sessions session.Service) fantasy.AgentTool {
 				}
 			}
 
+			justCompleted := stampCompletions(todos, newlyCompleted, oldByContent, currentSession.Todos, now)
+
+			previousTodos := currentSession.Todos
 			currentSession.Todos = todos
 			_, err = sessions.Save(ctx, currentSession)
 			if err != nil {
 				return fantasy.ToolResponse{}, fmt.Errorf("failed to save todos: %w", err)
 			}
 
-			response := "Todo list updated successfully.\n\n"
-
-			pendingCount := 0
-			inProgressCount := 0
-
-			for _, todo := range todos {
-				switch todo.Status {
-				case session.TodoStatusPending:
-					pendingCount++
-				case session.TodoStatusInProgress:
-					inProgressCount++
+			if todosConfig.CommitTemplate {
+				if err := writeCommitTemplate(workingDir, todosConfig.CommitTemplatePath, todos); err != nil {
+					slog.Warn("Failed to write todos to commit template", "error", err)
 				}
 			}
 
-			response += fmt.Sprintf("Status: %d pending, %d in progress, %d completed\n",
-				pendingCount, inProgressCount, completedCount)
-
-			response += "Todos have been modified successfully. Ensure that you continue to use the todo list to track your progress. Please proceed with the current tasks if applicable."
+			justCompletedOrder := completionOrder(justCompleted)
+			response := todosResponse(todos, justCompletedOrder, todosConfig.Compact)
 
 			metadata := TodosResponseMetadata{
 				IsNew:         isNew,
 				Todos:         todos,
-				JustCompleted: justCompleted,
+				JustCompleted: justCompletedOrder,
 				JustStarted:   justStarted,
 				Completed:     completedCount,
 				Total:         len(todos),
+				Churn:         todoChurn(previousTodos, todos),
 			}
 
 			return fantasy.WithResponseMetadata(fantasy.NewTextResponse(response), metadata), nil
 		})
 }
+
+// todosResponse builds the text returned to the model after an update. The
+// compact form is a single line such as `Todos: 3/7 (+1 done: "Run tests")`
+// for models that flood the transcript with todo updates.
+func todosResponse(todos []session.Todo, justCompleted []string, compact bool) string {
+	var pendingCount, inProgressCount, completedCount int
+	for _, todo := range todos {
+		switch todo.Status {
+		case session.TodoStatusPending:
+			pendingCount++
+		case session.TodoStatusInProgress:
+			inProgressCount++
+		case session.TodoStatusCompleted:
+			completedCount++
+		}
+	}
+
+	if compact {
+		response := fmt.Sprintf("Todos: %d/%d", completedCount, len(todos))
+		if len(justCompleted) > 0 {
+			response += fmt.Sprintf(" (+%d done: %q)", len(justCompleted), justCompleted[len(justCompleted)-1])
+		}
+		return response
+	}
+
+	response := "Todo list updated successfully.\n\n"
+	response += fmt.Sprintf("Status: %d pending, %d in progress, %d completed\n",
+		pendingCount, inProgressCount, completedCount)
+	response += "Todos have been modified successfully. Ensure that you continue to use the todo list to track your progress. Please proceed with the current tasks if applicable."
+	return response
+}
+
+// todosChecklist serializes todos as a Markdown checklist, one item per line.
+func todosChecklist(todos []session.Todo) string {
+	var b strings.Builder
+	for _, todo := range todos {
+		mark := " "
+		if todo.Status == session.TodoStatusCompleted {
+			mark = "x"
+		}
+		fmt.Fprintf(&b, "- [%s] %s\n", mark, todo.Content)
+	}
+	return b.String()
+}
+
+// writeCommitTemplate writes todos as a checklist to the commit message
+// template of the git repository containing workingDir, so the next commit
+// starts with the task context. path is relative to the repository root;
+// when empty, the repository's commit.template is used, and nothing is
+// written if that isn't set either. Outside a git repository it does
+// nothing.
+func writeCommitTemplate(workingDir, path string, todos []session.Todo) error {
+	root, _, ok := vcs.GitDir(workingDir)
+	if !ok {
+		return nil
+	}
+	if path == "" {
+		path = vcs.CommitTemplate(root)
+		if path == "" {
+			return nil
+		}
+	} else if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	return os.WriteFile(path, []byte(todosChecklist(todos)), 0o644)
+}
+
+// ValidateTodos checks a proposed todo list without touching any session:
+// statuses must be known, content non-empty, ids unique, parent_ids must
+// point at another todo without forming a cycle, file references must be
+// non-empty, and at most one todo may be in progress. It lets callers lint a
+// list before handing it to the todos tool, which applies the same checks.
+func ValidateTodos(items []TodoItem) error {
+	if err := validateTodoItems(items); err != nil {
+		return err
+	}
+
+	inProgress := 0
+	for _, item := range items {
+		if strings.TrimSpace(item.Content) == "" {
+			return fmt.Errorf("todo with status %q has empty content", item.Status)
+		}
+		if item.Status == string(session.TodoStatusInProgress) {
+			inProgress++
+		}
+	}
+	if inProgress > 1 {
+		return fmt.Errorf("%d todos are in_progress; only one may be in_progress at a time", inProgress)
+	}
+
+	parents := make(map[string]string, len(items))
+	for _, item := range items {
+		if item.ID != "" {
+			parents[item.ID] = item.ParentID
+		}
+	}
+	for _, item := range items {
+		seen := map[string]bool{item.ID: true}
+		for parent := item.ParentID; parent != ""; parent = parents[parent] {
+			if seen[parent] {
+				return fmt.Errorf("todo %q is part of a parent_id cycle", item.Content)
+			}
+			seen[parent] = true
+		}
+	}
+	return nil
+}
+
+// validateTodoItems checks that every item has a known status, that ids are
+// unique and parent_ids point at another todo in the list, and that any file
+// references are non-empty. File existence is not checked.
+func validateTodoItems(items []TodoItem) error {
+	ids := make(map[string]bool, len(items))
+	for _, item := range items {
+		if item.ID == "" {
+			continue
+		}
+		if ids[item.ID] {
+			return fmt.Errorf("duplicate id %q for todo %q", item.ID, item.Content)
+		}
+		ids[item.ID] = true
+	}
+
+	for _, item := range items {
+		if item.ParentID != "" && (item.ParentID == item.ID || !ids[item.ParentID]) {
+			return fmt.Errorf("todo %q references unknown parent_id %q", item.Content, item.ParentID)
+		}
+		switch item.Status {
+		case "pending", "in_progress", "completed":
+		default:
+			return fmt.Errorf("invalid status %q for todo %q", item.Status, item.Content)
+		}
+		for _, file := range item.Files {
+			if strings.TrimSpace(file) == "" {
+				return fmt.Errorf("empty file reference for todo %q", item.Content)
+			}
+		}
+	}
+	return nil
+}
+
+// checkTodosLimit returns an error asking the model to consolidate its list
+// when it exceeds the configured maximum. A limit of zero disables the check.
+func checkTodosLimit(count int, todosConfig config.ToolTodos) error {
+	limit := todosConfig.Limit()
+	if limit > 0 && count > limit {
+		return fmt.Errorf("todo list has %d items but the limit is %d; consolidate related tasks or drop completed ones and try again", count, limit)
+	}
+	return nil
+}
+
+// checkActiveForms returns an error when RequireActiveForm is set and an
+// in_progress todo has no active_form, so the status display always has
+// present-tense text for the task being worked on.
+func checkActiveForms(items []TodoItem, todosConfig config.ToolTodos) error {
+	if !todosConfig.RequireActiveForm {
+		return nil
+	}
+	for _, item := range items {
+		if item.Status == string(session.TodoStatusInProgress) && strings.TrimSpace(item.ActiveForm) == "" {
+			return fmt.Errorf("todo %q is in_progress but has no active_form; set active_form to the present continuous form of the task (e.g. 'Running tests') and try again", item.Content)
+		}
+	}
+	return nil
+}
+
+// stampCompletions sets CompletedAt to now and the next CompletedSeq of the
+// session on the todos at indexes, which were completed in this update, and
+// returns the ones that were on the previous list. A single update doesn't
+// say which finished first, so todos that were in progress are counted as
+// completed before those that weren't, and list order breaks the tie.
+func stampCompletions(todos []session.Todo, indexes []int, oldByContent map[string]session.Todo, previous []session.Todo, now int64) []session.Todo {
+	var seq int64
+	for _, todo := range previous {
+		seq = max(seq, todo.CompletedSeq)
+	}
+	wasInProgress := func(i int) bool {
+		return oldByContent[todos[i].Content].Status == session.TodoStatusInProgress
+	}
+	slices.SortStableFunc(indexes, func(a, b int) int {
+		switch {
+		case wasInProgress(a) == wasInProgress(b):
+			return 0
+		case wasInProgress(a):
+			return -1
+		}
+		return 1
+	})
+
+	var justCompleted []session.Todo
+	for _, i := range indexes {
+		seq++
+		todos[i].CompletedAt = now
+		todos[i].CompletedSeq = seq
+		if _, existed := oldByContent[todos[i].Content]; existed {
+			justCompleted = append(justCompleted, todos[i])
+		}
+	}
+	return justCompleted
+}
+
+// completionOrder returns the content of the given todos ordered by when they
+// were completed: by CompletedSeq when both todos have one, and otherwise by
+// CompletedAt. Todos without either go last, and input order is kept for
+// equal or missing timestamps.
+func completionOrder(todos []session.Todo) []string {
+	if len(todos) == 0 {
+		return nil
+	}
+	sorted := slices.Clone(todos)
+	slices.SortStableFunc(sorted, func(a, b session.Todo) int {
+		switch {
+		case a.CompletedSeq != 0 && b.CompletedSeq != 0:
+			return cmp.Compare(a.CompletedSeq, b.CompletedSeq)
+		case a.CompletedAt == b.CompletedAt:
+			return 0
+		case a.CompletedAt == 0:
+			return 1
+		case b.CompletedAt == 0:
+			return -1
+		}
+		return cmp.Compare(a.CompletedAt, b.CompletedAt)
+	})
+	contents := make([]string, len(sorted))
+	for i, todo := range sorted {
+		contents[i] = todo.Content
+	}
+	return contents
+}
+
+// todoChurn counts the changes between two todo lists: todos added, todos
+// removed and status transitions of todos present in both. A high churn on
+// every call suggests the model is thrashing its list.
+func todoChurn(previous, current []session.Todo) int {
+	previousStatus := make(map[string]session.TodoStatus, len(previous))
+	for _, todo := range previous {
+		previousStatus[todo.Content] = todo.Status
+	}
+
+	churn := 0
+	seen := make(map[string]bool, len(current))
+	for _, todo := range current {
+		seen[todo.Content] = true
+		status, existed := previousStatus[todo.Content]
+		if !existed || status != todo.Status {
+			churn++
+		}
+	}
+	for content := range previousStatus {
+		if !seen[content] {
+			churn++
+		}
+	}
+	return churn
+}
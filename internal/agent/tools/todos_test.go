@@ -0,0 +1,519 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckTodosLimit(t *testing.T) {
+	t.Parallel()
+
+	limit := 3
+	capped := config.ToolTodos{MaxTodos: &limit}
+
+	t.Run("no limit by default", func(t *testing.T) {
+		t.Parallel()
+		require.NoError(t, checkTodosLimit(500, config.ToolTodos{}))
+	})
+
+	t.Run("under the limit", func(t *testing.T) {
+		t.Parallel()
+		require.NoError(t, checkTodosLimit(2, capped))
+		require.NoError(t, checkTodosLimit(3, capped))
+	})
+
+	t.Run("over the limit", func(t *testing.T) {
+		t.Parallel()
+		err := checkTodosLimit(4, capped)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "limit is 3")
+		require.Contains(t, err.Error(), "consolidate")
+	})
+}
+
+func TestCompletionOrder(t *testing.T) {
+	t.Parallel()
+
+	t.Run("orders by completion time", func(t *testing.T) {
+		t.Parallel()
+		got := completionOrder([]session.Todo{
+			{Content: "third", CompletedAt: 300},
+			{Content: "first", CompletedAt: 100},
+			{Content: "second", CompletedAt: 200},
+		})
+		require.Equal(t, []string{"first", "second", "third"}, got)
+	})
+
+	t.Run("keeps input order for equal or missing timestamps", func(t *testing.T) {
+		t.Parallel()
+		got := completionOrder([]session.Todo{
+			{Content: "c"},
+			{Content: "a", CompletedAt: 100},
+			{Content: "d"},
+			{Content: "b", CompletedAt: 100},
+		})
+		require.Equal(t, []string{"a", "b", "c", "d"}, got)
+	})
+
+	t.Run("sequence wins over equal timestamps", func(t *testing.T) {
+		t.Parallel()
+		got := completionOrder([]session.Todo{
+			{Content: "second", CompletedAt: 100, CompletedSeq: 2},
+			{Content: "first", CompletedAt: 100, CompletedSeq: 1},
+		})
+		require.Equal(t, []string{"first", "second"}, got)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		t.Parallel()
+		require.Nil(t, completionOrder(nil))
+	})
+}
+
+// memorySessions is a session.Service holding a single session in memory.
+// Methods the todos tool doesn't use panic through the nil embedded Service.
+type memorySessions struct {
+	session.Service
+	session session.Session
+}
+
+func (m *memorySessions) Get(context.Context, string) (session.Session, error) {
+	return m.session, nil
+}
+
+func (m *memorySessions) Save(_ context.Context, s session.Session) (session.Session, error) {
+	m.session = s
+	return s, nil
+}
+
+// runTodos runs the todos tool with items against sessions and returns the
+// response metadata.
+func runTodos(t *testing.T, tool fantasy.AgentTool, items []TodoItem) TodosResponseMetadata {
+	t.Helper()
+	input, err := json.Marshal(TodosParams{Todos: items})
+	require.NoError(t, err)
+	ctx := context.WithValue(t.Context(), SessionIDContextKey, "session")
+	resp, err := tool.Run(ctx, fantasy.ToolCall{ID: "call", Name: TodosToolName, Input: string(input)})
+	require.NoError(t, err)
+	require.False(t, resp.IsError, resp.Content)
+
+	var metadata TodosResponseMetadata
+	require.NoError(t, json.Unmarshal([]byte(resp.Metadata), &metadata))
+	return metadata
+}
+
+func TestTodosToolCompletionOrder(t *testing.T) {
+	t.Parallel()
+
+	sessions := &memorySessions{session: session.Session{ID: "session"}}
+	tool := NewTodosTool(sessions, t.TempDir(), config.ToolTodos{})
+	runTodos(t, tool, []TodoItem{
+		{Content: "a", Status: "pending"},
+		{Content: "b", Status: "pending"},
+		{Content: "c", Status: "in_progress", ActiveForm: "Doing c"},
+		{Content: "d", Status: "pending"},
+	})
+
+	// All three finish in one call; c was being worked on, so it's first.
+	metadata := runTodos(t, tool, []TodoItem{
+		{Content: "a", Status: "completed"},
+		{Content: "b", Status: "completed"},
+		{Content: "c", Status: "completed"},
+		{Content: "d", Status: "in_progress", ActiveForm: "Doing d"},
+	})
+	require.Equal(t, []string{"c", "a", "b"}, metadata.JustCompleted)
+
+	seqs := make(map[string]int64)
+	for _, todo := range sessions.session.Todos {
+		seqs[todo.Content] = todo.CompletedSeq
+	}
+	require.Equal(t, map[string]int64{"a": 2, "b": 3, "c": 1, "d": 0}, seqs)
+
+	// Later completions continue the session's sequence, and earlier ones
+	// keep theirs.
+	runTodos(t, tool, []TodoItem{
+		{Content: "a", Status: "completed"},
+		{Content: "b", Status: "completed"},
+		{Content: "c", Status: "completed"},
+		{Content: "d", Status: "completed"},
+	})
+	require.Equal(t, int64(4), sessions.session.Todos[3].CompletedSeq)
+	require.Equal(t, int64(2), sessions.session.Todos[0].CompletedSeq)
+}
+
+func TestTodoChurn(t *testing.T) {
+	t.Parallel()
+
+	pending := func(content string) session.Todo {
+		return session.Todo{Content: content, Status: session.TodoStatusPending}
+	}
+	withStatus := func(content string, status session.TodoStatus) session.Todo {
+		return session.Todo{Content: content, Status: status}
+	}
+
+	tests := []struct {
+		name     string
+		previous []session.Todo
+		current  []session.Todo
+		want     int
+	}{
+		{
+			name:    "new list counts every addition",
+			current: []session.Todo{pending("a"), pending("b"), pending("c")},
+			want:    3,
+		},
+		{
+			name:     "unchanged list",
+			previous: []session.Todo{pending("a"), pending("b")},
+			current:  []session.Todo{pending("a"), pending("b")},
+			want:     0,
+		},
+		{
+			name:     "status transitions",
+			previous: []session.Todo{pending("a"), pending("b")},
+			current:  []session.Todo{withStatus("a", session.TodoStatusCompleted), withStatus("b", session.TodoStatusInProgress)},
+			want:     2,
+		},
+		{
+			name:     "removals",
+			previous: []session.Todo{pending("a"), pending("b"), pending("c")},
+			current:  []session.Todo{pending("a")},
+			want:     2,
+		},
+		{
+			name:     "mixed additions, removals and transitions",
+			previous: []session.Todo{pending("a"), pending("b")},
+			current:  []session.Todo{withStatus("a", session.TodoStatusInProgress), pending("c"), pending("d")},
+			want:     4,
+		},
+		{
+			name:     "reordering is not churn",
+			previous: []session.Todo{pending("a"), pending("b")},
+			current:  []session.Todo{pending("b"), pending("a")},
+			want:     0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, todoChurn(tt.previous, tt.current))
+		})
+	}
+}
+
+func TestValidateTodoItems(t *testing.T) {
+	t.Parallel()
+
+	t.Run("accepts file references", func(t *testing.T) {
+		t.Parallel()
+		err := validateTodoItems([]TodoItem{
+			{Content: "Refactor parser", Status: "pending", Files: []string{"parser.go", "does/not/exist.go"}},
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("rejects empty file references", func(t *testing.T) {
+		t.Parallel()
+		err := validateTodoItems([]TodoItem{
+			{Content: "Refactor parser", Status: "pending", Files: []string{"parser.go", "  "}},
+		})
+		require.ErrorContains(t, err, "empty file reference")
+	})
+
+	t.Run("rejects unknown status", func(t *testing.T) {
+		t.Parallel()
+		err := validateTodoItems([]TodoItem{{Content: "Refactor parser", Status: "blocked"}})
+		require.ErrorContains(t, err, "invalid status")
+	})
+}
+
+func TestTodoItemFilesJSON(t *testing.T) {
+	t.Parallel()
+
+	var params TodosParams
+	err := json.Unmarshal([]byte(`{"todos":[{"content":"Refactor parser","status":"pending","active_form":"Refactoring parser","files":["parser.go","lexer.go"]}]}`), &params)
+	require.NoError(t, err)
+	require.Len(t, params.Todos, 1)
+	require.Equal(t, []string{"parser.go", "lexer.go"}, params.Todos[0].Files)
+}
+
+func TestValidateTodoItemsParents(t *testing.T) {
+	t.Parallel()
+
+	t.Run("accepts known parents", func(t *testing.T) {
+		t.Parallel()
+		err := validateTodoItems([]TodoItem{
+			{ID: "api", Content: "Build API", Status: "pending"},
+			{ParentID: "api", Content: "Write handlers", Status: "pending"},
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("rejects unknown parents", func(t *testing.T) {
+		t.Parallel()
+		err := validateTodoItems([]TodoItem{
+			{ParentID: "api", Content: "Write handlers", Status: "pending"},
+		})
+		require.ErrorContains(t, err, "unknown parent_id")
+	})
+
+	t.Run("rejects duplicate ids", func(t *testing.T) {
+		t.Parallel()
+		err := validateTodoItems([]TodoItem{
+			{ID: "api", Content: "Build API", Status: "pending"},
+			{ID: "api", Content: "Build CLI", Status: "pending"},
+		})
+		require.ErrorContains(t, err, "duplicate id")
+	})
+}
+
+func TestTodosResponse(t *testing.T) {
+	t.Parallel()
+
+	todos := []session.Todo{
+		{Content: "Write parser", Status: session.TodoStatusCompleted},
+		{Content: "Run tests", Status: session.TodoStatusCompleted},
+		{Content: "Update docs", Status: session.TodoStatusInProgress},
+		{Content: "Release", Status: session.TodoStatusPending},
+	}
+
+	t.Run("verbose", func(t *testing.T) {
+		t.Parallel()
+		got := todosResponse(todos, []string{"Run tests"}, false)
+		require.Contains(t, got, "Todo list updated successfully.")
+		require.Contains(t, got, "Status: 1 pending, 1 in progress, 2 completed\n")
+	})
+
+	t.Run("compact", func(t *testing.T) {
+		t.Parallel()
+		got := todosResponse(todos, []string{"Write parser", "Run tests"}, true)
+		require.Equal(t, `Todos: 2/4 (+2 done: "Run tests")`, got)
+		require.NotContains(t, got, "\n")
+	})
+
+	t.Run("compact without completions", func(t *testing.T) {
+		t.Parallel()
+		require.Equal(t, "Todos: 2/4", todosResponse(todos, nil, true))
+	})
+}
+
+func TestTodosChecklist(t *testing.T) {
+	t.Parallel()
+
+	todos := []session.Todo{
+		{Content: "Write parser", Status: session.TodoStatusCompleted},
+		{Content: "Update docs", Status: session.TodoStatusInProgress},
+		{Content: "Release", Status: session.TodoStatusPending},
+	}
+	require.Equal(t, "- [x] Write parser\n- [ ] Update docs\n- [ ] Release\n", todosChecklist(todos))
+	require.Empty(t, todosChecklist(nil))
+}
+
+func TestWriteCommitTemplate(t *testing.T) {
+	t.Parallel()
+
+	todos := []session.Todo{
+		{Content: "Write parser", Status: session.TodoStatusCompleted},
+		{Content: "Release", Status: session.TodoStatusPending},
+	}
+	want := todosChecklist(todos)
+
+	newRepo := func(t *testing.T) string {
+		dir := t.TempDir()
+		runGit(t, dir, "init", "-q")
+		return dir
+	}
+
+	t.Run("defaults to the repository's commit.template", func(t *testing.T) {
+		t.Parallel()
+		repo := newRepo(t)
+		runGit(t, repo, "config", "commit.template", ".gitmessage")
+		sub := filepath.Join(repo, "pkg")
+		require.NoError(t, os.Mkdir(sub, 0o755))
+
+		require.NoError(t, writeCommitTemplate(sub, "", todos))
+		got, err := os.ReadFile(filepath.Join(repo, ".gitmessage"))
+		require.NoError(t, err)
+		require.Equal(t, want, string(got))
+
+		// git commit starts from the template. Git refuses a message left
+		// as the template, so the editor adds a subject line.
+		editor := `sh -c 'printf "Subject\n\n" | cat - "$1" > "$1.new" && mv "$1.new" "$1"' sh`
+		commit := exec.Command("git", "commit", "-q", "--allow-empty")
+		commit.Dir = repo
+		commit.Env = append(gitTestEnv(), "GIT_EDITOR="+editor)
+		out, err := commit.CombinedOutput()
+		require.NoError(t, err, string(out))
+		require.Equal(t, "Subject\n\n"+want+"\n", runGit(t, repo, "log", "-1", "--format=%B"))
+	})
+
+	t.Run("nothing without a template", func(t *testing.T) {
+		t.Parallel()
+		repo := newRepo(t)
+
+		require.NoError(t, writeCommitTemplate(repo, "", todos))
+		require.NoFileExists(t, filepath.Join(repo, ".git", "COMMIT_EDITMSG"))
+	})
+
+	t.Run("configured path is relative to the repository root", func(t *testing.T) {
+		t.Parallel()
+		repo := newRepo(t)
+
+		require.NoError(t, writeCommitTemplate(repo, ".gitmessage", todos))
+		got, err := os.ReadFile(filepath.Join(repo, ".gitmessage"))
+		require.NoError(t, err)
+		require.Equal(t, want, string(got))
+	})
+
+	t.Run("outside a repository", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		require.NoError(t, writeCommitTemplate(dir, ".gitmessage", todos))
+		require.NoFileExists(t, filepath.Join(dir, ".gitmessage"))
+	})
+}
+
+// gitTestEnv is the environment for git commands in tests, with a fixed
+// identity.
+func gitTestEnv() []string {
+	return append(os.Environ(),
+		"GIT_AUTHOR_NAME=Crush Test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=Crush Test", "GIT_COMMITTER_EMAIL=test@example.com",
+	)
+}
+
+// runGit runs git with args in dir and returns its output.
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = gitTestEnv()
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+	return string(out)
+}
+
+func TestCheckActiveForms(t *testing.T) {
+	t.Parallel()
+
+	missing := []TodoItem{
+		{Content: "Run tests", Status: "in_progress"},
+		{Content: "Write docs", Status: "pending"},
+	}
+	present := []TodoItem{
+		{Content: "Run tests", Status: "in_progress", ActiveForm: "Running tests"},
+		{Content: "Write docs", Status: "pending"},
+	}
+	required := config.ToolTodos{RequireActiveForm: true}
+
+	t.Run("ignored by default", func(t *testing.T) {
+		t.Parallel()
+		require.NoError(t, checkActiveForms(missing, config.ToolTodos{}))
+	})
+
+	t.Run("required and missing", func(t *testing.T) {
+		t.Parallel()
+		err := checkActiveForms(missing, required)
+		require.ErrorContains(t, err, `"Run tests" is in_progress but has no active_form`)
+	})
+
+	t.Run("required and present", func(t *testing.T) {
+		t.Parallel()
+		require.NoError(t, checkActiveForms(present, required))
+	})
+}
+
+func TestValidateTodos(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		items   []TodoItem
+		wantErr string
+	}{
+		{
+			name: "valid list",
+			items: []TodoItem{
+				{ID: "api", Content: "Build API", Status: "in_progress"},
+				{ID: "handlers", ParentID: "api", Content: "Write handlers", Status: "completed"},
+				{ParentID: "handlers", Content: "Add routing", Status: "pending"},
+			},
+		},
+		{
+			name:  "empty list",
+			items: nil,
+		},
+		{
+			name:    "unknown status",
+			items:   []TodoItem{{Content: "Build API", Status: "blocked"}},
+			wantErr: "invalid status",
+		},
+		{
+			name:    "empty content",
+			items:   []TodoItem{{Content: "  ", Status: "pending"}},
+			wantErr: "empty content",
+		},
+		{
+			name: "more than one in progress",
+			items: []TodoItem{
+				{Content: "Build API", Status: "in_progress"},
+				{Content: "Build CLI", Status: "in_progress"},
+			},
+			wantErr: "only one may be in_progress",
+		},
+		{
+			name: "parent cycle",
+			items: []TodoItem{
+				{ID: "a", ParentID: "c", Content: "A", Status: "pending"},
+				{ID: "b", ParentID: "a", Content: "B", Status: "pending"},
+				{ID: "c", ParentID: "b", Content: "C", Status: "pending"},
+			},
+			wantErr: "cycle",
+		},
+		{
+			name:    "self parent",
+			items:   []TodoItem{{ID: "a", ParentID: "a", Content: "A", Status: "pending"}},
+			wantErr: "unknown parent_id",
+		},
+		{
+			name:    "unknown parent",
+			items:   []TodoItem{{ParentID: "missing", Content: "A", Status: "pending"}},
+			wantErr: "unknown parent_id",
+		},
+		{
+			name: "duplicate id",
+			items: []TodoItem{
+				{ID: "a", Content: "A", Status: "pending"},
+				{ID: "a", Content: "B", Status: "pending"},
+			},
+			wantErr: "duplicate id",
+		},
+		{
+			name:    "empty file reference",
+			items:   []TodoItem{{Content: "A", Status: "pending", Files: []string{""}}},
+			wantErr: "empty file reference",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := ValidateTodos(tt.items)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
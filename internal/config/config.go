@@ -197,7 +197,45 @@ type TUIOptions struct {
 	// Here we can add themes later or any TUI related options
 	//
 
-	Completions Completions `json:"completions,omitzero" jsonschema:"description=Completions UI options"`
+	Completions Completions  `json:"completions,omitzero" jsonschema:"description=Completions UI options"`
+	VCS         VCSOptions   `json:"vcs,omitzero" jsonschema:"description=Version control status display options"`
+	Pills       PillsOptions `json:"pills,omitzero" jsonschema:"description=Todo and queue pills display options"`
+}
+
+// PillsOptions defines how the pills above the editor are laid out.
+type PillsOptions struct {
+	Overflow bool `json:"overflow,omitempty" jsonschema:"description=Show the pills that fit followed by a +N counter for the hidden ones instead of clipping the row,default=false"`
+	MaxShown *int `json:"max_shown,omitempty" jsonschema:"description=Maximum number of pills shown before the overflow counter (0 for no limit),default=0,example=1"`
+}
+
+func (p PillsOptions) Limit() int {
+	return ptrValOr(p.MaxShown, 0)
+}
+
+// VCSOptions defines how the version control status is displayed.
+type VCSOptions struct {
+	Disabled          bool     `json:"disabled,omitempty" jsonschema:"description=Hide the version control status,default=false"`
+	Separator         *string  `json:"separator,omitempty" jsonschema:"description=Text placed between the status icon and the branch name (defaults to a single space),example= ,example= | "`
+	NameFirst         bool     `json:"name_first,omitempty" jsonschema:"description=Show the branch name before the status icon,default=false"`
+	StagedIsClean     bool     `json:"staged_is_clean,omitempty" jsonschema:"description=Treat a working tree with only staged changes as clean,default=false"`
+	PulseConflict     bool     `json:"pulse_conflict,omitempty" jsonschema:"description=Pulse the merge conflict icon to draw attention to it,default=false"`
+	LinkBranch        bool     `json:"link_branch,omitempty" jsonschema:"description=Make the branch name a clickable link to its page on the hosting service in terminals that support OSC 8 hyperlinks,default=false"`
+	ProtectedBranches []string `json:"protected_branches,omitempty" jsonschema:"description=Branch names (wildcards allowed) flagged as protected from direct commits (defaults to main and master and release/*),example=main,example=release/*"`
+	SyncWords         bool     `json:"sync_words,omitempty" jsonschema:"description=Describe the remote sync state in words (ahead 3 / behind 1 / diverged 3/1 / in sync) instead of arrow icons,default=false"`
+	SyncCounts        bool     `json:"sync_counts,omitempty" jsonschema:"description=Show how many commits the branch is ahead of and behind its upstream (↑3 ↓1) after the branch name,default=false"`
+	PreferJujutsu     bool     `json:"prefer_jujutsu,omitempty" jsonschema:"description=Show Jujutsu status instead of Git status for colocated repositories that have both a .jj and a .git directory,default=false"`
+	SearchAboveHome   bool     `json:"search_above_home,omitempty" jsonschema:"description=Keep looking for a repository above the home directory instead of stopping there,default=false"`
+	MaxNameWidth      *int     `json:"max_name_width,omitempty" jsonschema:"description=Shorten branch and change names wider than this many columns with an ellipsis (0 for no limit),default=24,example=40"`
+}
+
+func (v VCSOptions) IconSeparator() string {
+	return ptrValOr(v.Separator, " ")
+}
+
+// NameWidthLimit returns the widest branch or change name shown before it's
+// shortened, or 0 for no limit.
+func (v VCSOptions) NameWidthLimit() int {
+	return max(0, ptrValOr(v.MaxNameWidth, 24))
 }
 
 // Completions defines options for the completions UI.
@@ -340,7 +378,8 @@ type Agent struct {
 }
 
 type Tools struct {
-	Ls ToolLs `json:"ls,omitzero"`
+	Ls    ToolLs    `json:"ls,omitzero"`
+	Todos ToolTodos `json:"todos,omitzero"`
 }
 
 type ToolLs struct {
@@ -352,6 +391,20 @@ func (t ToolLs) Limits() (depth, items int) {
 	return ptrValOr(t.MaxDepth, 0), ptrValOr(t.MaxItems, 0)
 }
 
+type ToolTodos struct {
+	MaxTodos *int `json:"max_todos,omitempty" jsonschema:"description=Maximum number of todos the todos tool accepts (0 means no limit),default=0,example=50"`
+	Compact  bool `json:"compact,omitempty" jsonschema:"description=Reply to todo updates with a single summary line instead of the full status block,default=false"`
+
+	RequireActiveForm bool `json:"require_active_form,omitempty" jsonschema:"description=Reject todo updates whose in_progress task has no active_form,default=false"`
+
+	CommitTemplate     bool   `json:"commit_template,omitempty" jsonschema:"description=Write the todo list as a Markdown checklist to the commit message template after each update (git repositories only),default=false"`
+	CommitTemplatePath string `json:"commit_template_path,omitempty" jsonschema:"description=File the todo checklist is written to relative to the repository root (defaults to the commit.template set in the repository's git config; nothing is written without either),example=.gitmessage"`
+}
+
+func (t ToolTodos) Limit() int {
+	return ptrValOr(t.MaxTodos, 0)
+}
+
 // Config holds the configuration for crush.
 type Config struct {
 	Schema string `json:"$schema,omitempty"`
@@ -23,9 +23,25 @@ const (
 )
 
 type Todo struct {
-	Content    string     `json:"content"`
-	Status     TodoStatus `json:"status"`
-	ActiveForm string     `json:"active_form"`
+	ID           string     `json:"id,omitempty"`
+	ParentID     string     `json:"parent_id,omitempty"` // ID of the todo this is a subtask of
+	Content      string     `json:"content"`
+	Status       TodoStatus `json:"status"`
+	ActiveForm   string     `json:"active_form"`
+	CompletedAt  int64      `json:"completed_at,omitempty"`  // Unix time the todo was marked completed
+	CompletedSeq int64      `json:"completed_seq,omitempty"` // Position in the session's completion order, from 1
+	Files        []string   `json:"files,omitempty"`         // Files the task touches
+	Category     string     `json:"category,omitempty"`      // Free-form grouping label, e.g. "tests"
+}
+
+// AnyInProgress reports whether any todo is in progress.
+func AnyInProgress(todos []Todo) bool {
+	for _, todo := range todos {
+		if todo.Status == TodoStatusInProgress {
+			return true
+		}
+	}
+	return false
 }
 
 type Session struct {
@@ -0,0 +1,50 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnyInProgress(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		todos []Todo
+		want  bool
+	}{
+		{name: "empty", todos: nil, want: false},
+		{
+			name: "all completed",
+			todos: []Todo{
+				{Content: "a", Status: TodoStatusCompleted},
+				{Content: "b", Status: TodoStatusCompleted},
+			},
+			want: false,
+		},
+		{
+			name: "pending only",
+			todos: []Todo{
+				{Content: "a", Status: TodoStatusPending},
+			},
+			want: false,
+		},
+		{
+			name: "mixed",
+			todos: []Todo{
+				{Content: "a", Status: TodoStatusCompleted},
+				{Content: "b", Status: TodoStatusInProgress},
+				{Content: "c", Status: TodoStatusPending},
+			},
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, AnyInProgress(tt.todos))
+		})
+	}
+}
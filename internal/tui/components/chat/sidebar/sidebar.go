@@ -28,6 +28,7 @@ import (
 	"github.com/charmbracelet/crush/internal/tui/components/mcp"
 	"github.com/charmbracelet/crush/internal/tui/styles"
 	"github.com/charmbracelet/crush/internal/tui/util"
+	"github.com/charmbracelet/crush/internal/vcs"
 	"github.com/charmbracelet/crush/internal/version"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
@@ -58,12 +59,33 @@ type SessionFilesMsg struct {
 	Files []SessionFile
 }
 
-// VCSRefreshMsg is sent periodically to refresh VCS status.
+// VCSRefreshMsg is sent periodically to refresh VCS status. The sidebar
+// answers it by detecting in the background, so the tick itself never
+// blocks rendering.
 type VCSRefreshMsg struct{}
 
+// VCSPulseMsg is sent on every frame of the conflict icon pulse animation.
+type VCSPulseMsg struct{}
+
+// VCSForceRefreshMsg asks the sidebar to drop cached VCS state and re-detect
+// right away instead of waiting for the next refresh tick.
+type VCSForceRefreshMsg struct{}
+
+// VCSDetectedMsg carries the result of a background VCS detection started
+// by vcsDetectCmd. Until it arrives the sidebar keeps showing the last
+// detected status. Info is the zero value when no repository was found.
+type VCSDetectedMsg struct {
+	Info vcs.Info
+}
+
 const (
 	// VCSRefreshInterval is how often to refresh VCS status.
 	VCSRefreshInterval = 5 * time.Second
+	// VCSPulseInterval is how often the conflict icon alternates colors.
+	VCSPulseInterval = 600 * time.Millisecond
+	// VCSDetectTimeout bounds a background detection. When it runs out the
+	// last cached status is reported instead.
+	VCSDetectTimeout = 5 * time.Second
 )
 
 type Sidebar interface {
@@ -79,6 +101,10 @@ type sidebarCmp struct {
 	logo          string
 	cwd           string
 	vcsInfo       string
+	vcs           vcs.Info
+	vcsPulse      bool
+	vcsPulsing    bool // A VCSPulseMsg is scheduled
+	vcsRefreshing bool
 	lspClients    *csync.Map[string, *lsp.Client]
 	compactMode   bool
 	history       history.Service
@@ -98,6 +124,64 @@ func (m *sidebarCmp) Init() tea.Cmd {
 	return m.vcsRefreshCmd()
 }
 
+// startVCSPulse starts the conflict icon pulse when enabled and the
+// repository has conflicts, unless it's already running. The pulse stops by
+// itself once the conflicts are gone.
+func (m *sidebarCmp) startVCSPulse(enabled bool) tea.Cmd {
+	if !enabled || m.vcsPulsing || !m.vcs.Status.HasConflicts {
+		return nil
+	}
+	m.vcsPulsing = true
+	return m.vcsPulseCmd()
+}
+
+// vcsPulseCmd returns a command that schedules the next conflict icon pulse frame.
+func (m *sidebarCmp) vcsPulseCmd() tea.Cmd {
+	return tea.Tick(VCSPulseInterval, func(time.Time) tea.Msg {
+		return VCSPulseMsg{}
+	})
+}
+
+// vcsDetectCmd returns a command that detects the VCS status off the
+// render path and reports it with a VCSDetectedMsg.
+func (m *sidebarCmp) vcsDetectCmd() tea.Cmd {
+	return detectVCSCmd(util.DetectVCSContext)
+}
+
+// detectVCSCmd returns a command that runs detect with a VCSDetectTimeout
+// deadline and reports the result with a VCSDetectedMsg.
+func detectVCSCmd(detect func(context.Context) (vcs.Info, bool)) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), VCSDetectTimeout)
+		defer cancel()
+		info, _ := detect(ctx)
+		return VCSDetectedMsg{Info: info}
+	}
+}
+
+// renderVCS renders the last detected VCS status, marked with a spinner
+// while a forced refresh is in flight.
+func (m *sidebarCmp) renderVCS() {
+	if m.vcs.Type == vcs.TypeNone {
+		m.vcsInfo = ""
+		return
+	}
+	m.vcsInfo = util.RenderVCSInfo(m.vcs, m.vcsPulse)
+	if m.vcsRefreshing && m.vcsInfo != "" {
+		t := styles.CurrentTheme()
+		m.vcsInfo = t.S().Base.Foreground(t.FgSubtle).Render(styles.CenterSpinnerIcon) + " " + m.vcsInfo
+	}
+}
+
+// forceRefreshVCS invalidates cached VCS state and re-detects in the
+// background, reporting back with a VCSDetectedMsg.
+func (m *sidebarCmp) forceRefreshVCS() tea.Cmd {
+	util.InvalidateVCSCache()
+	m.vcsRefreshing = true
+	m.renderVCS()
+	return m.vcsDetectCmd()
+}
+
 // vcsRefreshCmd returns a command that schedules a VCS refresh after the configured interval.
 func (m *sidebarCmp) vcsRefreshCmd() tea.Cmd {
 	return tea.Tick(VCSRefreshInterval, func(time.Time) tea.Msg {
@@ -116,15 +200,33 @@ func (m *sidebarCmp) Update(msg tea.Msg) (util.Model, tea.Cmd) {
 
 	case VCSRefreshMsg:
 		// Refresh VCS info and schedule the next refresh.
-		m.vcsInfo = util.VCSInfo()
-		return m, m.vcsRefreshCmd()
+		return m, tea.Batch(m.vcsDetectCmd(), m.vcsRefreshCmd())
+
+	case VCSForceRefreshMsg:
+		return m, m.forceRefreshVCS()
+
+	case VCSDetectedMsg:
+		m.vcs = msg.Info
+		m.vcsRefreshing = false
+		m.renderVCS()
+		return m, m.startVCSPulse(config.Get().Options.TUI.VCS.PulseConflict)
+
+	case VCSPulseMsg:
+		if !m.vcs.Status.HasConflicts {
+			// Nothing left to animate.
+			m.vcsPulsing = false
+			m.vcsPulse = false
+			return m, nil
+		}
+		m.vcsPulse = !m.vcsPulse
+		m.renderVCS()
+		return m, m.vcsPulseCmd()
 
 	case chat.SessionClearedMsg:
 		m.session = session.Session{}
 	case pubsub.Event[history.File]:
 		// Refresh VCS info when files change, as this often means git status changed.
-		m.vcsInfo = util.VCSInfo()
-		return m, m.handleFileHistoryEvent(msg)
+		return m, tea.Batch(m.vcsDetectCmd(), m.handleFileHistoryEvent(msg))
 	case pubsub.Event[session.Session]:
 		if msg.Type == pubsub.UpdatedEvent {
 			if m.session.ID == msg.Payload.ID {
@@ -295,10 +397,10 @@ func (m *sidebarCmp) loadSessionFiles() tea.Msg {
 func (m *sidebarCmp) SetSize(width, height int) tea.Cmd {
 	m.logo = m.logoBlock()
 	m.cwd = cwd()
-	m.vcsInfo = util.VCSInfo()
+	m.renderVCS()
 	m.width = width
 	m.height = height
-	return nil
+	return m.vcsDetectCmd()
 }
 
 func (m *sidebarCmp) GetSize() (int, int) {
@@ -626,7 +728,10 @@ func (s *sidebarCmp) currentModelBlock() string {
 // SetSession implements Sidebar.
 func (m *sidebarCmp) SetSession(session session.Session) tea.Cmd {
 	m.session = session
-	return m.loadSessionFiles
+	// A different session may have left the repository in another state, so
+	// don't trust what's cached.
+	util.InvalidateVCSCache()
+	return tea.Batch(m.loadSessionFiles, m.vcsDetectCmd())
 }
 
 // SetCompactMode sets the compact mode for the sidebar.
@@ -0,0 +1,64 @@
+package sidebar
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/vcs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectVCSCmd(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reports the detected info", func(t *testing.T) {
+		t.Parallel()
+		want := vcs.Info{Type: vcs.TypeGit, Status: vcs.Status{CurrentBranch: "main"}}
+		cmd := detectVCSCmd(func(ctx context.Context) (vcs.Info, bool) {
+			deadline, ok := ctx.Deadline()
+			require.True(t, ok)
+			require.WithinDuration(t, time.Now().Add(VCSDetectTimeout), deadline, time.Second)
+			return want, true
+		})
+		require.Equal(t, VCSDetectedMsg{Info: want}, cmd())
+	})
+
+	t.Run("reports nothing outside a repository", func(t *testing.T) {
+		t.Parallel()
+		cmd := detectVCSCmd(func(context.Context) (vcs.Info, bool) {
+			return vcs.Info{}, false
+		})
+		require.Equal(t, VCSDetectedMsg{}, cmd())
+	})
+}
+
+func TestStartVCSPulse(t *testing.T) {
+	t.Parallel()
+
+	conflicted := vcs.Info{Type: vcs.TypeGit, Status: vcs.Status{HasConflicts: true}}
+
+	t.Run("only with conflicts", func(t *testing.T) {
+		t.Parallel()
+		m := &sidebarCmp{vcs: vcs.Info{Type: vcs.TypeGit}}
+		require.Nil(t, m.startVCSPulse(true))
+		m.vcs = conflicted
+		require.Nil(t, m.startVCSPulse(false))
+		require.False(t, m.vcsPulsing)
+	})
+
+	t.Run("starts once and stops when conflicts are resolved", func(t *testing.T) {
+		t.Parallel()
+		m := &sidebarCmp{vcs: conflicted}
+		require.NotNil(t, m.startVCSPulse(true))
+		require.Nil(t, m.startVCSPulse(true), "already pulsing")
+
+		m.vcs.Status.HasConflicts = false
+		_, cmd := m.Update(VCSPulseMsg{})
+		require.Nil(t, cmd)
+		require.False(t, m.vcsPulsing)
+
+		m.vcs = conflicted
+		require.NotNil(t, m.startVCSPulse(true), "restarts for new conflicts")
+	})
+}
@@ -0,0 +1,31 @@
+package todos
+
+import (
+	"hash/fnv"
+	"image/color"
+
+	"github.com/charmbracelet/crush/internal/tui/styles"
+)
+
+// categoryPalette returns the theme colors categories are mapped onto.
+func categoryPalette(t *styles.Theme) []color.Color {
+	return []color.Color{
+		t.Blue,
+		t.Citron,
+		t.Cherry,
+		t.GreenLight,
+		t.Secondary,
+		t.BlueLight,
+		t.Yellow,
+		t.RedLight,
+	}
+}
+
+// CategoryColor returns the color for a todo category. The same category
+// always maps to the same color for a given theme.
+func CategoryColor(category string, t *styles.Theme) color.Color {
+	palette := categoryPalette(t)
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(category))
+	return palette[h.Sum32()%uint32(len(palette))]
+}
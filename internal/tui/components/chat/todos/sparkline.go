@@ -0,0 +1,81 @@
+package todos
+
+import (
+	"time"
+
+	"github.com/charmbracelet/crush/internal/session"
+)
+
+const (
+	// SparklineBuckets is the number of intervals shown in the sparkline.
+	SparklineBuckets = 8
+	// SparklineInterval is the span of time covered by each bucket.
+	SparklineInterval = 2 * time.Minute
+
+	// minSparklineCompletions is how many recent completions are needed
+	// before the sparkline says anything useful.
+	minSparklineCompletions = 2
+)
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// CompletionBuckets counts the todos completed in each of n consecutive
+// intervals ending at now, oldest first. Todos without a completion time or
+// completed outside the window are ignored.
+func CompletionBuckets(todos []session.Todo, now time.Time, interval time.Duration, n int) []int {
+	if n <= 0 || interval <= 0 {
+		return nil
+	}
+
+	buckets := make([]int, n)
+	start := now.Add(-interval * time.Duration(n))
+	for _, todo := range todos {
+		if todo.Status != session.TodoStatusCompleted || todo.CompletedAt == 0 {
+			continue
+		}
+		at := time.Unix(todo.CompletedAt, 0)
+		if at.Before(start) || at.After(now) {
+			continue
+		}
+		i := int(at.Sub(start) / interval)
+		if i >= n {
+			// Completed exactly at now.
+			i = n - 1
+		}
+		buckets[i]++
+	}
+	return buckets
+}
+
+// Sparkline renders bucket counts as unicode block characters scaled to the
+// busiest bucket. Empty buckets use the lowest block so the timeline keeps its
+// width. It returns an empty string when the buckets hold fewer than two
+// completions in total.
+func Sparkline(buckets []int) string {
+	total, peak := 0, 0
+	for _, c := range buckets {
+		total += c
+		peak = max(peak, c)
+	}
+	if total < minSparklineCompletions {
+		return ""
+	}
+
+	line := make([]rune, len(buckets))
+	top := len(sparkBlocks) - 1
+	for i, c := range buckets {
+		level := 0
+		if c > 0 {
+			// Any activity is at least one step above an empty bucket.
+			level = max(1, c*top/peak)
+		}
+		line[i] = sparkBlocks[level]
+	}
+	return string(line)
+}
+
+// CompletionSparkline renders recent todo completions as a sparkline using
+// the default bucket size and count.
+func CompletionSparkline(todos []session.Todo, now time.Time) string {
+	return Sparkline(CompletionBuckets(todos, now, SparklineInterval, SparklineBuckets))
+}
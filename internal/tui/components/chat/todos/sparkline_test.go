@@ -0,0 +1,69 @@
+package todos
+
+import (
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompletionBuckets(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1_000_000, 0)
+	completedAt := func(ago time.Duration) session.Todo {
+		return session.Todo{Status: session.TodoStatusCompleted, CompletedAt: now.Add(-ago).Unix()}
+	}
+
+	todos := []session.Todo{
+		completedAt(0),
+		completedAt(30 * time.Second),
+		completedAt(90 * time.Second),
+		completedAt(5 * time.Minute),
+		completedAt(time.Hour),                // outside the window
+		{Status: session.TodoStatusCompleted}, // no timestamp
+		{Status: session.TodoStatusPending, CompletedAt: now.Unix()},
+	}
+
+	got := CompletionBuckets(todos, now, time.Minute, 8)
+	require.Equal(t, []int{0, 0, 0, 1, 0, 0, 1, 2}, got)
+
+	require.Nil(t, CompletionBuckets(todos, now, time.Minute, 0))
+	require.Nil(t, CompletionBuckets(todos, now, 0, 8))
+}
+
+func TestSparkline(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		buckets []int
+		want    string
+	}{
+		{"no history", []int{0, 0, 0, 0}, ""},
+		{"single completion", []int{0, 1, 0, 0}, ""},
+		{"even", []int{1, 1, 1, 1}, "████"},
+		{"scaled", []int{0, 1, 2, 4, 7}, "▁▂▃▅█"},
+		{"small counts stay visible", []int{1, 0, 14}, "▂▁█"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, Sparkline(tt.buckets))
+		})
+	}
+}
+
+func TestCompletionSparkline(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1_000_000, 0)
+	todos := []session.Todo{
+		{Status: session.TodoStatusCompleted, CompletedAt: now.Unix()},
+		{Status: session.TodoStatusCompleted, CompletedAt: now.Add(-3 * time.Minute).Unix()},
+	}
+	got := CompletionSparkline(todos, now)
+	require.Len(t, []rune(got), SparklineBuckets)
+	require.Equal(t, "▁▁▁▁▁▁██", got)
+}
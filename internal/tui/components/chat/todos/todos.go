@@ -1,6 +1,7 @@
 package todos
 
 import (
+	"fmt"
 	"slices"
 	"strings"
 
@@ -38,30 +39,130 @@ func FormatTodosList(todos []session.Todo, inProgressIcon string, t *styles.Them
 
 	var lines []string
 	for _, todo := range sorted {
-		var prefix string
-		var textStyle lipgloss.Style
-
-		switch todo.Status {
-		case session.TodoStatusCompleted:
-			prefix = t.S().Base.Foreground(t.Green).Render(styles.TodoCompletedIcon) + " "
-			textStyle = t.S().Base.Foreground(t.FgBase)
-		case session.TodoStatusInProgress:
-			prefix = t.S().Base.Foreground(t.GreenDark).Render(inProgressIcon + " ")
-			textStyle = t.S().Base.Foreground(t.FgBase)
-		default:
-			prefix = t.S().Base.Foreground(t.FgMuted).Render(styles.TodoPendingIcon) + " "
-			textStyle = t.S().Base.Foreground(t.FgBase)
+		line := formatTodo(todo, inProgressIcon, t)
+		line = ansi.Truncate(line, width, "…")
+
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// FormatTodosTree renders todos as a tree, drawing connectors between
+// parents and the children that reference them via ParentID. Todos without
+// a parent, or whose parent isn't in the list, are rendered as roots, and so
+// is the first todo of a ParentID cycle that no root leads to, which a
+// session saved before cycles were rejected can hold. Siblings are ordered
+// like FormatTodosList.
+func FormatTodosTree(todos []session.Todo, inProgressIcon string, t *styles.Theme, width int) string {
+	if len(todos) == 0 {
+		return ""
+	}
+
+	ids := make(map[string]bool, len(todos))
+	for _, todo := range todos {
+		if todo.ID != "" {
+			ids[todo.ID] = true
 		}
+	}
 
-		text := todo.Content
-		if todo.Status == session.TodoStatusInProgress && todo.ActiveForm != "" {
-			text = todo.ActiveForm
+	var roots []session.Todo
+	children := make(map[string][]session.Todo)
+	for _, todo := range todos {
+		if todo.ParentID != "" && todo.ParentID != todo.ID && ids[todo.ParentID] {
+			children[todo.ParentID] = append(children[todo.ParentID], todo)
+		} else {
+			roots = append(roots, todo)
 		}
-		line := prefix + textStyle.Render(text)
-		line = ansi.Truncate(line, width, "…")
+	}
+	sortTodos(roots)
 
-		lines = append(lines, line)
+	var lines []string
+	visited := make(map[string]bool)
+	var walk func(todo session.Todo, indent, connector string)
+	walk = func(todo session.Todo, indent, connector string) {
+		// Guard against ParentID cycles: a child that was already drawn
+		// closes one.
+		if connector != "" && visited[todo.ID] {
+			return
+		}
+		line := t.S().Base.Foreground(t.Border).Render(indent+connector) + formatTodo(todo, inProgressIcon, t)
+		lines = append(lines, ansi.Truncate(line, width, "…"))
+		if todo.ID == "" || visited[todo.ID] {
+			return
+		}
+		visited[todo.ID] = true
+
+		kids := children[todo.ID]
+		sortTodos(kids)
+		switch connector {
+		case "├─ ":
+			indent += "│  "
+		case "└─ ":
+			indent += "   "
+		}
+		for i, kid := range kids {
+			if i == len(kids)-1 {
+				walk(kid, indent, "└─ ")
+			} else {
+				walk(kid, indent, "├─ ")
+			}
+		}
+	}
+	for _, root := range roots {
+		walk(root, "", "")
+	}
+	rest := slices.Clone(todos)
+	sortTodos(rest)
+	for _, todo := range rest {
+		if todo.ID != "" && !visited[todo.ID] {
+			walk(todo, "", "")
+		}
 	}
 
 	return strings.Join(lines, "\n")
 }
+
+// formatTodo renders a single todo with its status icon.
+func formatTodo(todo session.Todo, inProgressIcon string, t *styles.Theme) string {
+	var prefix string
+	var textStyle lipgloss.Style
+
+	switch todo.Status {
+	case session.TodoStatusCompleted:
+		prefix = t.S().Base.Foreground(t.Green).Render(styles.TodoCompletedIcon) + " "
+		textStyle = t.S().Base.Foreground(t.FgBase)
+	case session.TodoStatusInProgress:
+		prefix = t.S().Base.Foreground(t.GreenDark).Render(inProgressIcon + " ")
+		textStyle = t.S().Base.Foreground(t.FgBase)
+	default:
+		prefix = t.S().Base.Foreground(t.FgMuted).Render(styles.TodoPendingIcon) + " "
+		textStyle = t.S().Base.Foreground(t.FgBase)
+	}
+
+	text := todo.Content
+	if todo.Status == session.TodoStatusInProgress && todo.ActiveForm != "" {
+		text = todo.ActiveForm
+	}
+	if todo.Category != "" {
+		prefix += t.S().Base.Foreground(CategoryColor(todo.Category, t)).Render(styles.TodoCategoryIcon) + " "
+	}
+	line := prefix + textStyle.Render(text)
+	if badge := filesBadge(todo.Files); badge != "" {
+		line += " " + t.S().Base.Foreground(t.FgSubtle).Render(badge)
+	}
+	return line
+}
+
+// filesBadge returns a short note with the number of files a todo touches,
+// or an empty string when it references none.
+func filesBadge(files []string) string {
+	switch len(files) {
+	case 0:
+		return ""
+	case 1:
+		return "· 1 file"
+	default:
+		return fmt.Sprintf("· %d files", len(files))
+	}
+}
@@ -0,0 +1,90 @@
+package todos
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/charmbracelet/crush/internal/tui/styles"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatTodosListFilesBadge(t *testing.T) {
+	t.Parallel()
+
+	todos := []session.Todo{
+		{Content: "Refactor parser", Status: session.TodoStatusPending, Files: []string{"parser.go", "lexer.go"}},
+		{Content: "Update docs", Status: session.TodoStatusPending, Files: []string{"README.md"}},
+		{Content: "Run tests", Status: session.TodoStatusPending},
+	}
+
+	got := ansi.Strip(FormatTodosList(todos, "*", styles.CurrentTheme(), 80))
+	require.Equal(t, "• Refactor parser · 2 files\n• Update docs · 1 file\n• Run tests", got)
+}
+
+func TestFormatTodosTree(t *testing.T) {
+	t.Parallel()
+
+	todos := []session.Todo{
+		{ID: "api", Content: "Build API", Status: session.TodoStatusInProgress},
+		{ID: "handlers", ParentID: "api", Content: "Write handlers", Status: session.TodoStatusCompleted},
+		{ID: "routes", ParentID: "api", Content: "Wire routes", Status: session.TodoStatusPending},
+		{ParentID: "handlers", Content: "Add auth handler", Status: session.TodoStatusCompleted},
+		{ParentID: "handlers", Content: "Add user handler", Status: session.TodoStatusPending},
+		{Content: "Write docs", Status: session.TodoStatusPending},
+		{ParentID: "missing", Content: "Orphan", Status: session.TodoStatusPending},
+	}
+
+	got := ansi.Strip(FormatTodosTree(todos, "*", styles.CurrentTheme(), 80))
+	want := "* Build API\n" +
+		"├─ ✓ Write handlers\n" +
+		"│  ├─ ✓ Add auth handler\n" +
+		"│  └─ • Add user handler\n" +
+		"└─ • Wire routes\n" +
+		"• Write docs\n" +
+		"• Orphan"
+	require.Equal(t, want, got)
+
+	t.Run("cycle", func(t *testing.T) {
+		t.Parallel()
+		todos := []session.Todo{
+			{ID: "a", ParentID: "b", Content: "A", Status: session.TodoStatusPending},
+			{ID: "b", ParentID: "a", Content: "B", Status: session.TodoStatusPending},
+			{Content: "C", Status: session.TodoStatusPending},
+		}
+		got := ansi.Strip(FormatTodosTree(todos, "*", styles.CurrentTheme(), 80))
+		require.Equal(t, "• C\n• A\n└─ • B", got)
+	})
+}
+
+func TestCategoryColor(t *testing.T) {
+	t.Parallel()
+
+	th := styles.CurrentTheme()
+	require.Equal(t, CategoryColor("tests", th), CategoryColor("tests", th))
+
+	palette := categoryPalette(th)
+	for _, category := range []string{"tests", "docs", "refactor", ""} {
+		require.Contains(t, palette, CategoryColor(category, th))
+	}
+}
+
+func TestFormatTodosListCategory(t *testing.T) {
+	t.Parallel()
+
+	th := styles.CurrentTheme()
+	todos := []session.Todo{
+		{Content: "Write unit tests", Status: session.TodoStatusPending, Category: "tests"},
+		{Content: "Update docs", Status: session.TodoStatusPending},
+		{Content: "Fix flaky test", Status: session.TodoStatusPending, Category: "tests"},
+	}
+
+	got := FormatTodosList(todos, "*", th, 80)
+	require.Equal(t, "• ● Write unit tests\n• Update docs\n• ● Fix flaky test", ansi.Strip(got))
+
+	lines := strings.Split(got, "\n")
+	dot := th.S().Base.Foreground(CategoryColor("tests", th)).Render(styles.TodoCategoryIcon)
+	require.Contains(t, lines[0], dot)
+	require.Contains(t, lines[2], dot)
+}
@@ -299,10 +299,10 @@ func (p *chatPage) Update(msg tea.Msg) (util.Model, tea.Cmd) {
 	case pubsub.Event[session.Session]:
 		if msg.Payload.ID == p.session.ID {
 			prevHasIncompleteTodos := hasIncompleteTodos(p.session.Todos)
-			prevHasInProgress := p.hasInProgressTodo()
+			prevHasInProgress := session.AnyInProgress(p.session.Todos)
 			p.session = msg.Payload
 			newHasIncompleteTodos := hasIncompleteTodos(p.session.Todos)
-			newHasInProgress := p.hasInProgressTodo()
+			newHasInProgress := session.AnyInProgress(p.session.Todos)
 			if prevHasIncompleteTodos != newHasIncompleteTodos {
 				cmds = append(cmds, p.SetSize(p.width, p.height))
 			}
@@ -360,13 +360,13 @@ func (p *chatPage) Update(msg tea.Msg) (util.Model, tea.Cmd) {
 		spinner.TickMsg:
 		// Update todo spinner if agent is busy and we have in-progress todos
 		agentBusy := p.app.AgentCoordinator != nil && p.app.AgentCoordinator.IsBusy()
-		if _, ok := msg.(spinner.TickMsg); ok && p.hasInProgressTodo() && agentBusy {
+		if _, ok := msg.(spinner.TickMsg); ok && session.AnyInProgress(p.session.Todos) && agentBusy {
 			var cmd tea.Cmd
 			p.todoSpinner, cmd = p.todoSpinner.Update(msg)
 			cmds = append(cmds, cmd)
 		}
 		// Start spinner when agent becomes busy and we have in-progress todos
-		if _, ok := msg.(pubsub.Event[message.Message]); ok && p.hasInProgressTodo() && agentBusy {
+		if _, ok := msg.(pubsub.Event[message.Message]); ok && session.AnyInProgress(p.session.Todos) && agentBusy {
 			cmds = append(cmds, p.todoSpinner.Tick)
 		}
 		if p.focusedPane == PanelTypeSplash {
@@ -385,7 +385,8 @@ func (p *chatPage) Update(msg tea.Msg) (util.Model, tea.Cmd) {
 		u, cmd := p.editor.Update(msg)
 		p.editor = u.(editor.Editor)
 		return p, cmd
-	case pubsub.Event[history.File], sidebar.SessionFilesMsg:
+	case pubsub.Event[history.File], sidebar.SessionFilesMsg,
+		sidebar.VCSRefreshMsg, sidebar.VCSPulseMsg, sidebar.VCSDetectedMsg:
 		u, cmd := p.sidebar.Update(msg)
 		p.sidebar = u.(sidebar.Sidebar)
 		cmds = append(cmds, cmd)
@@ -479,6 +480,10 @@ func (p *chatPage) Update(msg tea.Msg) (util.Model, tea.Cmd) {
 			if p.session.ID != "" && p.pillsExpanded {
 				return p, p.switchPillSection(1)
 			}
+		case key.Matches(msg, p.keyMap.RefreshVCS):
+			u, cmd := p.sidebar.Update(sidebar.VCSForceRefreshMsg{})
+			p.sidebar = u.(sidebar.Sidebar)
+			return p, cmd
 		}
 
 		switch p.focusedPane {
@@ -560,10 +565,7 @@ func (p *chatPage) View() string {
 
 		// Use spinner when agent is busy, otherwise show static icon
 		agentBusy := p.app.AgentCoordinator != nil && p.app.AgentCoordinator.IsBusy()
-		inProgressIcon := t.S().Base.Foreground(t.GreenDark).Render(styles.CenterSpinnerIcon)
-		if agentBusy {
-			inProgressIcon = p.todoSpinner.View()
-		}
+		inProgressIcon := todoInProgressIcon(p.todoSpinner.View(), agentBusy, t)
 
 		var pills []string
 		if hasIncompleteTodos {
@@ -576,7 +578,7 @@ func (p *chatPage) View() string {
 		var expandedList string
 		if p.pillsExpanded {
 			if todosFocused && hasIncompleteTodos {
-				expandedList = todoList(p.session.Todos, inProgressIcon, t, p.width-SideBarWidth)
+				expandedList = p.expandedTodos(inProgressIcon, t)
 			} else if queueFocused && hasQueue {
 				queueItems := p.app.AgentCoordinator.QueuedPromptsList(p.session.ID)
 				expandedList = queueList(queueItems, t)
@@ -585,8 +587,6 @@ func (p *chatPage) View() string {
 
 		var pillsArea string
 		if len(pills) > 0 {
-			pillsRow := lipgloss.JoinHorizontal(lipgloss.Top, pills...)
-
 			// Add help hint for expanding/collapsing pills based on state.
 			var helpDesc string
 			if p.pillsExpanded {
@@ -598,6 +598,24 @@ func (p *chatPage) View() string {
 			helpKey := t.S().Base.Foreground(t.FgMuted).Render("ctrl+space")
 			helpText := t.S().Base.Foreground(t.FgSubtle).Render(helpDesc)
 			helpHint := lipgloss.JoinHorizontal(lipgloss.Center, helpKey, " ", helpText)
+
+			if opts := config.Get().Options.TUI.Pills; opts.Overflow {
+				available := p.width - 3 - lipgloss.Width(helpHint) - 1
+				if !p.compact {
+					available -= SideBarWidth
+				}
+				widths := make([]int, len(pills))
+				for i, pill := range pills {
+					widths[i] = lipgloss.Width(pill)
+				}
+				overflowWidth := lipgloss.Width(overflowPill(len(pills), t))
+				shown := fitPills(widths, available, opts.Limit(), overflowWidth)
+				if hidden := len(pills) - shown; hidden > 0 {
+					pills = append(pills[:shown], overflowPill(hidden, t))
+				}
+			}
+
+			pillsRow := lipgloss.JoinHorizontal(lipgloss.Top, pills...)
 			pillsRow = lipgloss.JoinHorizontal(lipgloss.Center, pillsRow, " ", helpHint)
 
 			if expandedList != "" {
@@ -786,6 +804,15 @@ func (p *chatPage) handleCompactMode(newWidth int, newHeight int) {
 	}
 }
 
+// expandedTodos renders the session's todos for the expanded pills area,
+// as a tree when they form a hierarchy.
+func (p *chatPage) expandedTodos(inProgressIcon string, t *styles.Theme) string {
+	if hasTodoHierarchy(p.session.Todos) {
+		return todoTree(p.session.Todos, inProgressIcon, t, p.width-SideBarWidth, maxTodoRows)
+	}
+	return todoList(p.session.Todos, inProgressIcon, t, p.width-SideBarWidth, maxTodoRows)
+}
+
 func (p *chatPage) SetSize(width, height int) tea.Cmd {
 	p.handleCompactMode(width, height)
 	p.width = width
@@ -810,7 +837,9 @@ func (p *chatPage) SetSize(width, height int) tea.Cmd {
 			pillsAreaHeight = pillHeightWithBorder + 1 // +1 for padding top
 			if p.pillsExpanded {
 				if p.focusedPillSection == PillSectionTodos && hasIncompleteTodos {
-					pillsAreaHeight += len(p.session.Todos)
+					// Size from the rendered rows, which include the
+					// "+N more" footer.
+					pillsAreaHeight += lipgloss.Height(p.expandedTodos("", styles.CurrentTheme()))
 				} else if p.focusedPillSection == PillSectionQueue && hasQueue {
 					pillsAreaHeight += p.promptQueue
 				}
@@ -857,7 +886,7 @@ func (p *chatPage) setSession(sess session.Session) tea.Cmd {
 	var cmds []tea.Cmd
 	p.session = sess
 
-	if p.hasInProgressTodo() {
+	if session.AnyInProgress(p.session.Todos) {
 		cmds = append(cmds, p.todoSpinner.Tick)
 	}
 
@@ -1393,12 +1422,3 @@ func (p *chatPage) isMouseOverChat(x, y int) bool {
 	// Check if mouse coordinates are within chat bounds
 	return x >= chatX && x < chatX+chatWidth && y >= chatY && y < chatY+chatHeight
 }
-
-func (p *chatPage) hasInProgressTodo() bool {
-	for _, todo := range p.session.Todos {
-		if todo.Status == session.TodoStatusInProgress {
-			return true
-		}
-	}
-	return false
-}
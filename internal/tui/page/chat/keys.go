@@ -13,6 +13,7 @@ type KeyMap struct {
 	TogglePills   key.Binding
 	PillLeft      key.Binding
 	PillRight     key.Binding
+	RefreshVCS    key.Binding
 }
 
 func DefaultKeyMap() KeyMap {
@@ -49,5 +50,9 @@ func DefaultKeyMap() KeyMap {
 			key.WithKeys("right"),
 			key.WithHelp("←/→", "switch section"),
 		),
+		RefreshVCS: key.NewBinding(
+			key.WithKeys("alt+r"),
+			key.WithHelp("alt+r", "refresh git status"),
+		),
 	}
 }
@@ -0,0 +1,16 @@
+package chat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultKeyMapRefreshVCS(t *testing.T) {
+	t.Parallel()
+
+	binding := DefaultKeyMap().RefreshVCS
+	require.Equal(t, []string{"alt+r"}, binding.Keys())
+	require.Equal(t, "refresh git status", binding.Help().Desc)
+	require.True(t, binding.Enabled())
+}
@@ -3,6 +3,7 @@ package chat
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"charm.land/lipgloss/v2"
 	"github.com/charmbracelet/crush/internal/session"
@@ -19,10 +20,22 @@ func hasIncompleteTodos(todos []session.Todo) bool {
 	return false
 }
 
+func hasTodoHierarchy(todos []session.Todo) bool {
+	for _, todo := range todos {
+		if todo.ParentID != "" {
+			return true
+		}
+	}
+	return false
+}
+
 const (
 	pillHeightWithBorder  = 3
 	maxTaskDisplayLength  = 40
 	maxQueueDisplayLength = 60
+	maxTodoShortcuts      = 9
+	maxTodoRows           = 10
+	todoShortcutWidth     = len("[1] ")
 )
 
 func queuePill(queue int, focused, pillsPanelFocused bool, t *styles.Theme) string {
@@ -45,28 +58,76 @@ func queuePill(queue int, focused, pillsPanelFocused bool, t *styles.Theme) stri
 	return style.Render(content)
 }
 
-func todoPill(todos []session.Todo, spinnerView string, focused, pillsPanelFocused bool, t *styles.Theme) string {
-	if !hasIncompleteTodos(todos) {
+// overflowPill renders the "+K" counter shown in place of hidden pills.
+func overflowPill(hidden int, t *styles.Theme) string {
+	if hidden <= 0 {
+		return ""
+	}
+	return t.S().Base.
+		PaddingLeft(1).
+		PaddingRight(1).
+		Foreground(t.FgMuted).
+		BorderStyle(lipgloss.RoundedBorder()).
+		BorderForeground(t.BgOverlay).
+		Render(fmt.Sprintf("+%d", hidden))
+}
+
+// fitPills returns how many of the leading pills to show so that they, plus an
+// overflow counter of overflowWidth for the rest, fit in available columns.
+// A maxShown of zero or less means only the width limits the count.
+func fitPills(widths []int, available, maxShown, overflowWidth int) int {
+	limit := len(widths)
+	if maxShown > 0 && maxShown < limit {
+		limit = maxShown
+	}
+
+	total := 0
+	for _, w := range widths {
+		total += w
+	}
+	if limit == len(widths) && total <= available {
+		return limit
+	}
+
+	shown, used := 0, 0
+	for _, w := range widths[:limit] {
+		if used+w+overflowWidth > available {
+			break
+		}
+		used += w
+		shown++
+	}
+	return shown
+}
+
+// todoPill renders the collapsed todo summary. The live spinner is only shown
+// while the agent is busy; between turns a static marker is used instead so
+// an in-progress todo doesn't look like active work.
+func todoPill(sessionTodos []session.Todo, inProgressIcon string, focused, pillsPanelFocused bool, t *styles.Theme) string {
+	if !hasIncompleteTodos(sessionTodos) {
 		return ""
 	}
 
 	completed := 0
 	var currentTodo *session.Todo
-	for i := range todos {
-		switch todos[i].Status {
+	for i := range sessionTodos {
+		switch sessionTodos[i].Status {
 		case session.TodoStatusCompleted:
 			completed++
 		case session.TodoStatusInProgress:
 			if currentTodo == nil {
-				currentTodo = &todos[i]
+				currentTodo = &sessionTodos[i]
 			}
 		}
 	}
 
-	total := len(todos)
+	total := len(sessionTodos)
 
 	label := "To-Do"
 	progress := t.S().Base.Foreground(t.FgMuted).Render(fmt.Sprintf("%d/%d", completed, total))
+	if spark := todos.CompletionSparkline(sessionTodos, time.Now()); spark != "" {
+		progress += " " + t.S().Base.Foreground(t.GreenDark).Render(spark)
+	}
 
 	var content string
 	if pillsPanelFocused {
@@ -80,7 +141,7 @@ func todoPill(todos []session.Todo, spinnerView string, focused, pillsPanelFocus
 			taskText = taskText[:maxTaskDisplayLength-1] + "…"
 		}
 		task := t.S().Base.Foreground(t.FgSubtle).Render(taskText)
-		content = fmt.Sprintf("%s %s %s  %s", spinnerView, label, progress, task)
+		content = fmt.Sprintf("%s %s %s  %s", inProgressIcon, label, progress, task)
 	} else {
 		content = fmt.Sprintf("%s %s", label, progress)
 	}
@@ -94,8 +155,90 @@ func todoPill(todos []session.Todo, spinnerView string, focused, pillsPanelFocus
 	return style.Render(content)
 }
 
-func todoList(sessionTodos []session.Todo, spinnerView string, t *styles.Theme, width int) string {
-	return todos.FormatTodosList(sessionTodos, spinnerView, t, width)
+// todoInProgressIcon returns the marker for the in-progress todo: the
+// animated spinner while the agent is busy, a static icon otherwise.
+func todoInProgressIcon(spinnerView string, busy bool, t *styles.Theme) string {
+	if busy {
+		return spinnerView
+	}
+	return t.S().Base.Foreground(t.GreenDark).Render(styles.CenterSpinnerIcon)
+}
+
+// todoList renders the expanded todo list with a [1]..[9] shortcut hint in
+// front of the first nine items so they can be jump-selected. When there are
+// more todos than maxRows, only the most relevant ones are shown, followed by
+// a "+N more" footer; a maxRows of zero or less shows them all.
+func todoList(sessionTodos []session.Todo, spinnerView string, t *styles.Theme, width, maxRows int) string {
+	visible, hidden := visibleTodos(sessionTodos, maxRows)
+	list := todos.FormatTodosList(visible, spinnerView, t, width-todoShortcutWidth)
+	return withTodoShortcuts(list, hidden, t)
+}
+
+// withTodoShortcuts puts the shortcut hints of todoList in front of the rows
+// of a rendered todo list and, when hidden todos were left out, appends the
+// "+N more" footer.
+func withTodoShortcuts(list string, hidden int, t *styles.Theme) string {
+	if list == "" {
+		return ""
+	}
+
+	lines := strings.Split(list, "\n")
+	for i, line := range lines {
+		hint := strings.Repeat(" ", todoShortcutWidth)
+		if i < maxTodoShortcuts {
+			hint = fmt.Sprintf("[%d] ", i+1)
+		}
+		lines[i] = t.S().Base.Foreground(t.FgSubtle).Render(hint) + line
+	}
+	if hidden > 0 {
+		footer := strings.Repeat(" ", todoShortcutWidth) + fmt.Sprintf("+%d more", hidden)
+		lines = append(lines, t.S().Base.Foreground(t.FgSubtle).Render(footer))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// visibleTodos picks the todos to show when at most maxRows rows fit,
+// keeping one row for the "+N more" footer. In-progress todos come first,
+// then pending ones in list order and finally completed ones. It returns the
+// picked todos in their original order and how many were left out.
+func visibleTodos(sessionTodos []session.Todo, maxRows int) ([]session.Todo, int) {
+	if maxRows <= 0 || len(sessionTodos) <= maxRows {
+		return sessionTodos, 0
+	}
+
+	keep := max(maxRows-1, 0)
+	picked := make([]bool, len(sessionTodos))
+	n := 0
+	for _, status := range []session.TodoStatus{
+		session.TodoStatusInProgress,
+		session.TodoStatusPending,
+		session.TodoStatusCompleted,
+	} {
+		for i, todo := range sessionTodos {
+			if n < keep && todo.Status == status {
+				picked[i] = true
+				n++
+			}
+		}
+	}
+
+	visible := make([]session.Todo, 0, n)
+	for i, todo := range sessionTodos {
+		if picked[i] {
+			visible = append(visible, todo)
+		}
+	}
+	return visible, len(sessionTodos) - n
+}
+
+// todoTree renders todos with parent/child connectors. It's used instead of
+// todoList when the todos form a hierarchy, and picks the visible todos and
+// adds shortcut hints and the footer the same way. A subtask whose parent
+// was left out is shown as a root.
+func todoTree(sessionTodos []session.Todo, spinnerView string, t *styles.Theme, width, maxRows int) string {
+	visible, hidden := visibleTodos(sessionTodos, maxRows)
+	tree := todos.FormatTodosTree(visible, spinnerView, t, width-todoShortcutWidth)
+	return withTodoShortcuts(tree, hidden, t)
 }
 
 func queueList(queueItems []string, t *styles.Theme) string {
@@ -0,0 +1,161 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/charmbracelet/crush/internal/tui/styles"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTodoPillSpinner(t *testing.T) {
+	t.Parallel()
+
+	const spinnerView = "<spinner>"
+	todos := []session.Todo{
+		{Content: "Write tests", Status: session.TodoStatusInProgress, ActiveForm: "Writing tests"},
+		{Content: "Ship it", Status: session.TodoStatusPending},
+	}
+	theme := styles.CurrentTheme()
+
+	t.Run("shows the spinner while busy", func(t *testing.T) {
+		t.Parallel()
+		pill := todoPill(todos, todoInProgressIcon(spinnerView, true, theme), false, false, theme)
+		require.Contains(t, pill, spinnerView)
+	})
+
+	t.Run("shows a static marker while idle", func(t *testing.T) {
+		t.Parallel()
+		pill := todoPill(todos, todoInProgressIcon(spinnerView, false, theme), false, false, theme)
+		require.NotContains(t, pill, spinnerView)
+		require.Contains(t, pill, styles.CenterSpinnerIcon)
+	})
+}
+
+func TestTodoListShortcuts(t *testing.T) {
+	t.Parallel()
+
+	var items []session.Todo
+	for i := range 11 {
+		items = append(items, session.Todo{Content: fmt.Sprintf("Task %d", i+1), Status: session.TodoStatusPending})
+	}
+
+	list := ansi.Strip(todoList(items, "*", styles.CurrentTheme(), 80, 0))
+	lines := strings.Split(list, "\n")
+	require.Len(t, lines, 11)
+	for i, line := range lines[:9] {
+		require.True(t, strings.HasPrefix(line, fmt.Sprintf("[%d] ", i+1)), "line %d: %q", i, line)
+	}
+	for _, line := range lines[9:] {
+		require.True(t, strings.HasPrefix(line, "    •"), "line %q", line)
+	}
+	require.NotContains(t, list, "[10]")
+}
+
+func TestFitPills(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		widths        []int
+		available     int
+		maxShown      int
+		overflowWidth int
+		want          int
+	}{
+		{"all fit", []int{10, 20}, 30, 0, 4, 2},
+		{"none", nil, 30, 0, 4, 0},
+		{"second does not fit", []int{10, 20}, 29, 0, 4, 1},
+		{"first fits only without counter", []int{10, 20}, 12, 0, 4, 0},
+		{"max shown", []int{10, 10, 10}, 100, 1, 4, 1},
+		{"max shown above count", []int{10, 10}, 100, 5, 4, 2},
+		{"max shown and width", []int{10, 10, 10}, 25, 2, 4, 2},
+		{"width tighter than max", []int{10, 10, 10}, 23, 2, 4, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, fitPills(tt.widths, tt.available, tt.maxShown, tt.overflowWidth))
+		})
+	}
+}
+
+func TestOverflowPill(t *testing.T) {
+	t.Parallel()
+
+	theme := styles.CurrentTheme()
+	require.Empty(t, overflowPill(0, theme))
+	require.Contains(t, ansi.Strip(overflowPill(3, theme)), "+3")
+}
+
+func TestTodoListMaxRows(t *testing.T) {
+	t.Parallel()
+
+	theme := styles.CurrentTheme()
+	var items []session.Todo
+	for i := range 6 {
+		items = append(items, session.Todo{Content: fmt.Sprintf("Done %d", i+1), Status: session.TodoStatusCompleted})
+	}
+	items = append(items,
+		session.Todo{Content: "Current", Status: session.TodoStatusInProgress},
+		session.Todo{Content: "Next", Status: session.TodoStatusPending},
+		session.Todo{Content: "Later", Status: session.TodoStatusPending},
+	)
+
+	t.Run("footer counts hidden todos", func(t *testing.T) {
+		t.Parallel()
+		list := ansi.Strip(todoList(items, "*", theme, 80, 5))
+		lines := strings.Split(list, "\n")
+		require.Len(t, lines, 5)
+		require.Equal(t, "+5 more", strings.TrimSpace(lines[4]))
+		require.Contains(t, list, "Current")
+		require.Contains(t, list, "Next")
+		require.Contains(t, list, "Later")
+		require.Contains(t, list, "Done 1")
+		require.NotContains(t, list, "Done 2")
+	})
+
+	t.Run("in-progress is always shown", func(t *testing.T) {
+		t.Parallel()
+		list := ansi.Strip(todoList(items, "*", theme, 80, 2))
+		lines := strings.Split(list, "\n")
+		require.Len(t, lines, 2)
+		require.Contains(t, lines[0], "Current")
+		require.Equal(t, "+8 more", strings.TrimSpace(lines[1]))
+	})
+
+	t.Run("no footer when everything fits", func(t *testing.T) {
+		t.Parallel()
+		list := ansi.Strip(todoList(items, "*", theme, 80, len(items)))
+		require.NotContains(t, list, "more")
+		require.Len(t, strings.Split(list, "\n"), len(items))
+	})
+}
+
+func TestTodoTreeMaxRows(t *testing.T) {
+	t.Parallel()
+
+	theme := styles.CurrentTheme()
+	items := []session.Todo{{ID: "root", Content: "Release", Status: session.TodoStatusInProgress}}
+	for i := range 11 {
+		items = append(items, session.Todo{ParentID: "root", Content: fmt.Sprintf("Step %d", i+1), Status: session.TodoStatusPending})
+	}
+	require.True(t, hasTodoHierarchy(items))
+
+	tree := ansi.Strip(todoTree(items, "*", theme, 80, maxTodoRows))
+	lines := strings.Split(tree, "\n")
+	require.Len(t, lines, maxTodoRows)
+	require.True(t, strings.HasPrefix(lines[0], "[1] "), lines[0])
+	require.Contains(t, lines[0], "Release")
+	require.True(t, strings.HasPrefix(lines[1], "[2] "), lines[1])
+	require.Contains(t, lines[1], "├─ • Step 1")
+	require.Contains(t, lines[maxTodoRows-2], "└─ • Step 8") // The last subtask that fits.
+	require.Equal(t, "+3 more", strings.TrimSpace(lines[maxTodoRows-1]))
+
+	all := ansi.Strip(todoTree(items, "*", theme, 80, 0))
+	require.Len(t, strings.Split(all, "\n"), len(items))
+	require.NotContains(t, all, "more")
+}
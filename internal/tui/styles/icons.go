@@ -25,6 +25,18 @@ const (
 	GitDivergentIcon string = "↕" // Diverged from remote (both ahead and behind)
 	GitUntrackedIcon string = "?" // Untracked files
 	GitDetachedIcon  string = "⚠" // Detached HEAD state
+	GitLockedIcon    string = "⟳" // Another git process holds the index lock
+	GitStashIcon     string = "⚑" // Stashed changes
+	GitUnbornIcon    string = "∅" // No commits yet
+	GitBareIcon      string = "◇" // Bare repository, no working tree
+	GitGoneIcon      string = "↯" // The upstream branch no longer exists on the remote
+	VCSMissingIcon   string = "⊘" // The git or jj command isn't installed
+	VCSFailedIcon    string = "‼" // A VCS command failed, e.g. on a corrupt repository
+	JJCleanIcon      string = "@" // jj working-copy change with nothing unusual about it
+	JJDirtyIcon      string = "±" // jj working-copy change with file modifications
+	JJConflictIcon   string = "×" // Conflicted jj change, drawn like "jj log" draws it
+	JJDivergentIcon  string = "≠" // jj change id with more than one visible commit
+	JJEmptyIcon      string = "○" // Empty jj working-copy change, nothing to commit
 
 	// Tool call icons
 	ToolPending string = "●"
@@ -37,6 +49,7 @@ const (
 	// Todo icons
 	TodoCompletedIcon string = "✓"
 	TodoPendingIcon   string = "•"
+	TodoCategoryIcon  string = "●"
 )
 
 var SelectionIgnoreIcons = []string{
@@ -1,76 +1,771 @@
 package util
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"image/color"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"charm.land/lipgloss/v2"
 	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/csync"
 	"github.com/charmbracelet/crush/internal/tui/styles"
 	"github.com/charmbracelet/crush/internal/vcs"
+	"github.com/charmbracelet/x/ansi"
 )
 
+// vcsRenderTimeout bounds how long VCSInfo and its plain and detailed
+// variants wait for detection before falling back to the last known status.
+const vcsRenderTimeout = time.Second
+
 // VCSInfo returns a styled string representing the current VCS status and
-// branch/change name. Returns empty string if no VCS is detected.
+// branch/change name. Returns empty string if no VCS is detected. It's
+// VCSInfoContext with a vcsRenderTimeout deadline.
 func VCSInfo() string {
-	workingDir := config.Get().WorkingDir()
-	detector := vcs.NewDetector()
-	info, err := detector.Detect(workingDir)
-	if err != nil || info.Type == vcs.TypeNone {
+	ctx, cancel := context.WithTimeout(context.Background(), vcsRenderTimeout)
+	defer cancel()
+	return VCSInfoContext(ctx)
+}
+
+// VCSInfoContext is like VCSInfo, but gives up on detection when ctx is
+// done. It then renders the last status cached for the repository, however
+// old, or returns an empty string if there's none.
+func VCSInfoContext(ctx context.Context) string {
+	info, ok := DetectVCSContext(ctx)
+	if !ok {
 		return ""
 	}
+	return RenderVCSInfo(info, false)
+}
 
-	t := styles.CurrentTheme()
+// VCSInfoPlain returns the same status as VCSInfo without colors or other
+// escape sequences, for logs and status lines that do their own styling.
+// Returns empty string if no VCS is detected. It's VCSInfoPlainContext with
+// a vcsRenderTimeout deadline.
+func VCSInfoPlain() string {
+	ctx, cancel := context.WithTimeout(context.Background(), vcsRenderTimeout)
+	defer cancel()
+	return VCSInfoPlainContext(ctx)
+}
 
-	// Determine the status icon and render it with the appropriate color based on Git status (priority order).
-	var styledIcon string
+// VCSInfoPlainContext is like VCSInfoPlain, but gives up on detection when
+// ctx is done, falling back to the last cached status like VCSInfoContext.
+func VCSInfoPlainContext(ctx context.Context) string {
+	info, ok := DetectVCSContext(ctx)
+	if !ok {
+		return ""
+	}
+	opts := vcsOptionsFor(info.RootPath, config.Get().Options.TUI.VCS)
+	if opts.Disabled {
+		return ""
+	}
+	return formatVCSInfoPlain(info, opts)
+}
 
-	if info.Type == vcs.TypeGit {
-		status := info.Status
+// VCSInfoWidth returns the number of terminal columns VCSInfo occupies, so
+// layouts can reserve space for it without measuring styled text
+// themselves. It's 0 when no VCS is detected.
+func VCSInfoWidth() int {
+	return lipgloss.Width(VCSInfo())
+}
+
+// VCSInfoDetailed returns the same short status as VCSInfo along with a
+// multi-line summary for hover or detail views. Both are empty if no VCS is
+// detected or the VCS display is disabled. It's VCSInfoDetailedContext with
+// a vcsRenderTimeout deadline.
+func VCSInfoDetailed() (short string, detail string) {
+	ctx, cancel := context.WithTimeout(context.Background(), vcsRenderTimeout)
+	defer cancel()
+	return VCSInfoDetailedContext(ctx)
+}
+
+// VCSInfoDetailedContext is like VCSInfoDetailed, but gives up on detection
+// when ctx is done, falling back to the last cached status like
+// VCSInfoContext.
+func VCSInfoDetailedContext(ctx context.Context) (short string, detail string) {
+	info, ok := DetectVCSContext(ctx)
+	if !ok {
+		return "", ""
+	}
+	opts := vcsOptionsFor(info.RootPath, config.Get().Options.TUI.VCS)
+	return formatVCSInfoDetailed(info, opts, styles.CurrentTheme())
+}
+
+// formatVCSInfoDetailed renders the short status and detail summary for
+// VCSInfoDetailedContext, both empty when opts disables the VCS display.
+func formatVCSInfoDetailed(info vcs.Info, opts config.VCSOptions, t *styles.Theme) (short string, detail string) {
+	if opts.Disabled {
+		return "", ""
+	}
+	return formatVCSInfo(info, opts, false, t), formatVCSDetail(info)
+}
+
+// vcsCacheTTL bounds how long a detected status is reused. Editing tracked
+// or untracked files doesn't touch the index, so this is how long such
+// changes can go unnoticed.
+const vcsCacheTTL = 2 * time.Second
+
+// vcsDetectors are shared by every render so repeated lookups within
+// vcsCacheTTL don't re-run the VCS commands. There's one for each
+// search_above_home setting, so the cache finds repository roots as far up
+// as detection does.
+var vcsDetectors = map[bool]*vcs.CachingDetector{
+	false: vcs.NewCachingDetectorWithOptions(configDetector{}, vcsCacheTTL, vcs.DetectOptions{}),
+	true:  vcs.NewCachingDetectorWithOptions(configDetector{searchAboveHome: true}, vcsCacheTTL, vcs.DetectOptions{SearchAboveHome: true}),
+}
+
+// configDetector detects with the options currently set in the config,
+// except for search_above_home, which picks the detector in vcsDetectors.
+type configDetector struct {
+	searchAboveHome bool
+}
+
+func (d configDetector) Detect(path string) (vcs.Info, error) {
+	return d.DetectContext(context.Background(), path)
+}
+
+func (d configDetector) DetectContext(ctx context.Context, path string) (vcs.Info, error) {
+	opts := config.Get().Options.TUI.VCS
+	detectOpts := vcs.DetectOptions{
+		ProtectedBranches: opts.ProtectedBranches,
+		SearchAboveHome:   d.searchAboveHome,
+	}
+	if opts.PreferJujutsu {
+		detectOpts.Prefer = vcs.TypeJujutsu
+	}
+	return vcs.NewDetectorWithOptions(detectOpts).DetectContext(ctx, path)
+}
+
+// DetectVCS detects the VCS repository containing the working directory.
+// It reports false if none is found.
+func DetectVCS() (vcs.Info, bool) {
+	return DetectVCSContext(context.Background())
+}
+
+// DetectVCSContext is like DetectVCS, but stops detecting when ctx is done
+// and returns the last status cached for the repository instead, reporting
+// false if there's none.
+func DetectVCSContext(ctx context.Context) (vcs.Info, bool) {
+	cfg := config.Get()
+	return detectVCSIn(ctx, vcsDetectors[cfg.Options.TUI.VCS.SearchAboveHome], cfg.WorkingDir())
+}
+
+// detectVCSIn detects the repository containing dir through cache for
+// DetectVCSContext.
+func detectVCSIn(ctx context.Context, cache *vcs.CachingDetector, dir string) (vcs.Info, bool) {
+	info, err := cache.DetectContext(ctx, dir)
+	if ctx.Err() != nil {
+		// The partial result may leave out changes, so a dated but
+		// complete status is better.
+		stale, ok := cache.Stale(dir)
+		return stale, ok && stale.Type != vcs.TypeNone
+	}
+	// A failed VCS command is shown on the status itself (see
+	// vcs.Status.Failure) rather than hiding the repository.
+	if err != nil && !errors.Is(err, vcs.ErrVCSCommandFailed) || info.Type == vcs.TypeNone {
+		return vcs.Info{}, false
+	}
+	return info, true
+}
+
+// RenderVCSInfo renders an already detected info like VCSInfo. When
+// conflict pulsing is enabled, pulse selects the alternate frame of the
+// animation.
+func RenderVCSInfo(info vcs.Info, pulse bool) string {
+	opts := vcsOptionsFor(info.RootPath, config.Get().Options.TUI.VCS)
+	if opts.Disabled {
+		return ""
+	}
+	return formatVCSInfo(info, opts, pulse, styles.CurrentTheme())
+}
+
+// projectVCSOptionsFile is the repository-local file, relative to the
+// repository root, whose VCS display options override the global ones.
+var projectVCSOptionsFile = filepath.Join(".crush", "vcs.json")
+
+// projectVCSOptions caches the contents of each repository's project VCS
+// options file by root path. A nil entry means the repository has none.
+var projectVCSOptions = csync.NewMap[string, []byte]()
+
+// InvalidateVCSCache drops cached per-repository VCS state, such as project
+// VCS options and detected statuses, so the next detection reads everything
+// afresh.
+func InvalidateVCSCache() {
+	projectVCSOptions.Reset(map[string][]byte{})
+	for _, detector := range vcsDetectors {
+		detector.Invalidate()
+	}
+}
+
+// vcsOptionsFor returns global with any options set in the project file of
+// the repository at root applied on top. The file uses the same keys as
+// options.tui.vcs and is read once per root.
+func vcsOptionsFor(root string, global config.VCSOptions) config.VCSOptions {
+	if root == "" {
+		return global
+	}
+	data := projectVCSOptions.GetOrSet(root, func() []byte {
+		data, err := os.ReadFile(filepath.Join(root, projectVCSOptionsFile))
+		if err != nil {
+			return nil
+		}
+		return data
+	})
+	if data == nil {
+		return global
+	}
+
+	// Unmarshaling over a copy only replaces the keys the file sets. The
+	// separator is copied too so decoding doesn't write through to global.
+	opts := global
+	if global.Separator != nil {
+		sep := *global.Separator
+		opts.Separator = &sep
+	}
+	if err := json.Unmarshal(data, &opts); err != nil {
+		slog.Warn("Ignoring invalid project VCS options", "path", filepath.Join(root, projectVCSOptionsFile), "error", err)
+		return global
+	}
+	return opts
+}
+
+// vcsTone is the role a part of the rendered VCS status plays. The styled
+// rendering maps each to a theme color; the plain one ignores it.
+type vcsTone int
+
+const (
+	vcsToneMuted vcsTone = iota
+	vcsToneSubtle
+	vcsToneSuccess
+	vcsToneInfo
+	vcsToneWarning
+	vcsToneError
+	vcsToneConflict // Error, or RedLight on the alternate frame of the conflict pulse
+)
+
+// vcsPart is a piece of text in the rendered VCS status.
+type vcsPart struct {
+	text string
+	tone vcsTone
+}
+
+// vcsLine is the VCS status broken into the pieces that formatVCSInfo and
+// formatVCSInfoPlain lay out: the status icon, the branch or change name,
+// and the annotations after it (a failure, the operation, the sync state
+// and the stash count), each separated by a space.
+type vcsLine struct {
+	icon   vcsPart
+	name   string
+	link   string // URL for the name when opts.LinkBranch is set
+	extras []vcsPart
+}
+
+// vcsLineFor decides what the VCS status shows for info, without styling.
+func vcsLineFor(info vcs.Info, opts config.VCSOptions) vcsLine {
+	line := vcsLine{
+		icon: vcsStatusIcon(info, opts),
+		name: truncateVCSName(vcsDisplayName(info), opts.NameWidthLimit()),
+	}
+	if opts.LinkBranch {
+		if url, err := vcs.BrowseURL(info); err == nil {
+			line.link = url
+		}
+	}
+
+	add := func(text string, tone vcsTone) {
+		line.extras = append(line.extras, vcsPart{text: text, tone: tone})
+	}
+	status := info.Status
+	if status.ToolUnavailable {
+		add(toolMissingText(info.Type), vcsToneMuted)
+	} else if status.Failure != nil {
+		add(failureText(status.Failure), vcsToneError)
+	}
+	if op := status.Operation; op != vcs.OpNone {
+		// Say why the tree is conflicted or mid-way, e.g. "rebase".
+		add(op.String(), vcsToneWarning)
+	}
+	if status.IsShallow {
+		// Ahead/behind counts are unreliable, so they're left out below.
+		add("shallow", vcsToneMuted)
+	} else if opts.SyncWords && info.Type == vcs.TypeGit {
+		if words := syncWords(status); words != "" {
+			add(words, vcsToneSubtle)
+		}
+	} else if opts.SyncCounts && info.Type == vcs.TypeGit {
+		// Leave out a direction with nothing in it.
+		if status.AheadCount > 0 {
+			add(fmt.Sprintf("%s%d", styles.GitUnpushedIcon, status.AheadCount), vcsToneInfo)
+		}
+		if status.BehindCount > 0 {
+			add(fmt.Sprintf("%s%d", styles.GitBehindIcon, status.BehindCount), vcsToneWarning)
+		}
+	}
+	if status.StashCount > 0 {
+		add(fmt.Sprintf("%s%d", styles.GitStashIcon, status.StashCount), vcsToneSubtle)
+	}
+	return line
+}
+
+// vcsStatusIcon picks the status icon for info, in priority order.
+func vcsStatusIcon(info vcs.Info, opts config.VCSOptions) vcsPart {
+	status := info.Status
+	if status.ToolUnavailable {
+		// Nothing is known about the working tree; don't claim it's clean.
+		return vcsPart{styles.VCSMissingIcon, vcsToneMuted}
+	}
+	if status.Failure != nil {
+		return vcsPart{styles.VCSFailedIcon, vcsToneError}
+	}
+
+	switch info.Type {
+	case vcs.TypeGit:
+		stagedOnly := status.HasStaged && !status.HasUncommitted && !status.HasUntracked && !status.HasConflicts
 		switch {
+		case status.Locked:
+			// Working tree status was skipped, so nothing below is reliable.
+			return vcsPart{styles.GitLockedIcon, vcsToneMuted}
+		case status.IsBare:
+			// No working tree, so nothing can be dirty.
+			return vcsPart{styles.GitBareIcon, vcsToneMuted}
 		case status.HasConflicts:
-			styledIcon = t.S().Base.Foreground(t.Error).Render(styles.GitConflictIcon)
+			return vcsPart{styles.GitConflictIcon, vcsToneConflict}
 		case status.IsDetached:
-			styledIcon = t.S().Base.Foreground(t.Warning).Render(styles.GitDetachedIcon)
-		case status.HasStaged:
-			styledIcon = t.S().Base.Foreground(t.Warning).Render(styles.GitStagedIcon)
+			return vcsPart{styles.GitDetachedIcon, vcsToneWarning}
+		case status.HasStaged && !(opts.StagedIsClean && stagedOnly):
+			return vcsPart{styles.GitStagedIcon, vcsToneWarning}
 		case status.HasUncommitted:
-			styledIcon = t.S().Base.Foreground(t.Warning).Render(styles.GitDirtyIcon)
+			return vcsPart{styles.GitDirtyIcon, vcsToneWarning}
 		case status.HasUntracked:
-			styledIcon = t.S().Base.Foreground(t.FgSubtle).Render(styles.GitUntrackedIcon)
+			return vcsPart{styles.GitUntrackedIcon, vcsToneSubtle}
+		case status.IsUnborn:
+			// Nothing committed yet; "clean" would be misleading.
+			return vcsPart{styles.GitUnbornIcon, vcsToneMuted}
+		case opts.SyncWords || status.IsShallow:
+			// The sync state is spelled out after the name instead, or is
+			// unknown because history was cut off.
+			return vcsPart{styles.GitCleanIcon, vcsToneSuccess}
+		case status.UpstreamGone:
+			// Nothing to push to or pull from anymore.
+			return vcsPart{styles.GitGoneIcon, vcsToneWarning}
 		case status.AheadCount > 0 && status.BehindCount > 0:
-			styledIcon = t.S().Base.Foreground(t.Warning).Render(styles.GitDivergentIcon)
+			return vcsPart{styles.GitDivergentIcon, vcsToneWarning}
 		case status.HasUnpushed || status.AheadCount > 0:
-			styledIcon = t.S().Base.Foreground(t.Info).Render(styles.GitUnpushedIcon)
+			return vcsPart{styles.GitUnpushedIcon, vcsToneInfo}
 		case status.BehindCount > 0:
-			styledIcon = t.S().Base.Foreground(t.Info).Render(styles.GitBehindIcon)
+			return vcsPart{styles.GitBehindIcon, vcsToneInfo}
 		default:
 			// Clean repository - everything committed and pushed.
-			styledIcon = t.S().Base.Foreground(t.Success).Render(styles.GitCleanIcon)
+			return vcsPart{styles.GitCleanIcon, vcsToneSuccess}
 		}
-	} else if info.Type == vcs.TypeJujutsu {
-		status := info.Status
-		// Apply similar status logic for Jujutsu.
+	case vcs.TypeJujutsu:
 		switch {
 		case status.HasConflicts:
-			styledIcon = t.S().Base.Foreground(t.Error).Render(styles.GitConflictIcon)
+			return vcsPart{styles.JJConflictIcon, vcsToneConflict}
+		case status.IsDivergent || status.HasDivergentChanges:
+			return vcsPart{styles.JJDivergentIcon, vcsToneWarning}
 		case status.HasUncommitted:
-			styledIcon = t.S().Base.Foreground(t.Warning).Render(styles.GitDirtyIcon)
+			return vcsPart{styles.JJDirtyIcon, vcsToneWarning}
+		case status.NothingToCommit:
+			return vcsPart{styles.JJEmptyIcon, vcsToneSubtle}
 		default:
-			// Clean or unknown state - use jj icon.
-			styledIcon = t.S().Base.Foreground(t.Success).Render("jj")
+			return vcsPart{styles.JJCleanIcon, vcsToneSuccess}
 		}
-	} else {
-		styledIcon = t.S().Base.Foreground(t.FgMuted).Render(string(info.Type))
+	case vcs.TypeMercurial, vcs.TypeSubversion, vcs.TypeFossil:
+		switch {
+		case status.HasConflicts:
+			return vcsPart{styles.GitConflictIcon, vcsToneConflict}
+		case status.HasUncommitted:
+			return vcsPart{styles.GitDirtyIcon, vcsToneWarning}
+		case status.HasUntracked:
+			return vcsPart{styles.GitUntrackedIcon, vcsToneSubtle}
+		default:
+			return vcsPart{styles.GitCleanIcon, vcsToneSuccess}
+		}
+	default:
+		return vcsPart{string(info.Type), vcsToneMuted}
+	}
+}
+
+// formatVCSInfo renders the status icon and branch/change name for info,
+// laid out according to opts.
+func formatVCSInfo(info vcs.Info, opts config.VCSOptions, pulse bool, t *styles.Theme) string {
+	render := func(part vcsPart) string {
+		return renderVCSPart(part, opts.PulseConflict && pulse, t)
+	}
+
+	line := vcsLineFor(info, opts)
+	name := t.S().Muted.Render(line.name)
+	if line.link != "" {
+		// Terminals without OSC 8 support ignore the escapes and show the
+		// name as plain text.
+		name = ansi.SetHyperlink(line.link) + name + ansi.ResetHyperlink()
+	}
+	extras := make([]string, len(line.extras))
+	for i, part := range line.extras {
+		extras[i] = render(part)
 	}
+	return layoutVCSLine(render(line.icon), name, extras, opts)
+}
 
-	// Display branch/change name for Git and Jujutsu, repo name for other VCS.
-	var displayName string
-	if (info.Type == vcs.TypeGit || info.Type == vcs.TypeJujutsu) && info.Status.CurrentBranch != "" {
-		displayName = info.Status.CurrentBranch
+// renderVCSPart renders part in its tone's theme color. pulse selects the
+// alternate frame of the conflict pulse.
+func renderVCSPart(part vcsPart, pulse bool, t *styles.Theme) string {
+	var c color.Color
+	switch part.tone {
+	case vcsToneSubtle:
+		c = t.FgSubtle
+	case vcsToneSuccess:
+		c = t.Success
+	case vcsToneInfo:
+		c = t.Info
+	case vcsToneWarning:
+		c = t.Warning
+	case vcsToneError:
+		c = t.Error
+	case vcsToneConflict:
+		c = t.Error
+		if pulse {
+			c = t.RedLight
+		}
+	default:
+		c = t.FgMuted
+	}
+	return t.S().Base.Foreground(c).Render(part.text)
+}
+
+// formatVCSInfoPlain renders the same status as formatVCSInfo as plain
+// text, without colors or hyperlinks.
+func formatVCSInfoPlain(info vcs.Info, opts config.VCSOptions) string {
+	line := vcsLineFor(info, opts)
+	extras := make([]string, len(line.extras))
+	for i, part := range line.extras {
+		extras[i] = part.text
+	}
+	return layoutVCSLine(line.icon.text, line.name, extras, opts)
+}
+
+// layoutVCSLine puts the rendered icon, name and annotations in the order
+// opts asks for.
+func layoutVCSLine(icon, name string, extras []string, opts config.VCSOptions) string {
+	var result string
+	if opts.NameFirst {
+		result = name + opts.IconSeparator() + icon
 	} else {
-		displayName = info.RepoName
+		result = icon + opts.IconSeparator() + name
+	}
+	for _, extra := range extras {
+		result += " " + extra
 	}
+	return result
+}
 
-	styledName := t.S().Muted.Render(displayName)
+// formatVCSDetail summarizes info in plain text, one section per line:
+// branch, upstream, changes and, when present, stashes and the last commit
+// time. It only uses what's already in info.Status.
+func formatVCSDetail(info vcs.Info) string {
+	status := info.Status
 
-	return fmt.Sprintf("%s %s", styledIcon, styledName)
+	var lines []string
+	switch {
+	case status.IsDetached:
+		lines = append(lines, "Branch: detached at "+status.DetachedRef)
+	case status.CurrentBranch != "":
+		lines = append(lines, "Branch: "+status.CurrentBranch)
+	default:
+		lines = append(lines, "Repository: "+info.RepoName)
+	}
+
+	if status.ToolUnavailable {
+		lines = append(lines, "Changes: unknown ("+toolMissingText(info.Type)+")")
+		return strings.Join(lines, "\n")
+	}
+	if status.Failure != nil {
+		lines = append(lines, "Changes: unknown ("+failureText(status.Failure)+")")
+		return strings.Join(lines, "\n")
+	}
+	if status.IsBare {
+		lines = append(lines, "Changes: none (bare repository)")
+		return strings.Join(lines, "\n")
+	}
+
+	if info.Type == vcs.TypeGit {
+		upstream := syncWords(status)
+		switch {
+		case status.IsShallow:
+			upstream = "unknown (shallow clone)"
+		case status.UpstreamGone:
+			upstream = "gone (deleted on the remote)"
+		case upstream == "":
+			upstream = "none"
+		}
+		lines = append(lines, "Upstream: "+upstream)
+	}
+
+	var changes []string
+	if status.HasConflicts {
+		changes = append(changes, countOrFlag(status.ConflictCount, "conflicted"))
+	}
+	if status.HasStaged {
+		changes = append(changes, countOrFlag(status.StagedCount, "staged"))
+	}
+	if status.HasUncommitted {
+		changes = append(changes, countOrFlag(status.ModifiedCount, "modified"))
+	}
+	if status.HasUntracked {
+		changes = append(changes, countOrFlag(status.UntrackedCount, "untracked files"))
+	}
+	if len(changes) == 0 {
+		changes = append(changes, "clean")
+	}
+	lines = append(lines, "Changes: "+strings.Join(changes, ", "))
+
+	if status.StashCount > 0 {
+		lines = append(lines, fmt.Sprintf("Stash: %d (latest: %s)", status.StashCount, status.TopStashDescription))
+	}
+
+	if !status.LastCommitCommitTime.IsZero() {
+		lines = append(lines, "Last commit: "+status.LastCommitCommitTime.Local().Format(time.DateTime))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// countOrFlag renders "3 staged" when the file count is known and just
+// "staged" when only the flag was gathered.
+func countOrFlag(count int, label string) string {
+	if count > 0 {
+		return fmt.Sprintf("%d %s", count, label)
+	}
+	return label
+}
+
+// toolMissingText says which VCS command is missing, e.g. "git not found".
+func toolMissingText(typ vcs.Type) string {
+	return string(typ) + " not found"
+}
+
+// failureText briefly describes a failed status read: "repository corrupt"
+// or "status failed".
+func failureText(err error) string {
+	if errors.Is(err, vcs.ErrRepoCorrupt) {
+		return "repository corrupt"
+	}
+	return "status failed"
+}
+
+// iconWithCount renders "✗5" when the file count is known and just the icon
+// when only the flag was gathered.
+func iconWithCount(icon string, count int) string {
+	if count > 0 {
+		return fmt.Sprintf("%s%d", icon, count)
+	}
+	return icon
+}
+
+// syncWords describes how the branch relates to its upstream: "ahead 3",
+// "behind 1", "diverged 3/1", "in sync" or "upstream gone". It returns an
+// empty string when there's no upstream to compare against.
+func syncWords(status vcs.Status) string {
+	switch {
+	case status.UpstreamGone:
+		return "upstream gone"
+	case status.AheadCount > 0 && status.BehindCount > 0:
+		return fmt.Sprintf("diverged %d/%d", status.AheadCount, status.BehindCount)
+	case status.AheadCount > 0:
+		return fmt.Sprintf("ahead %d", status.AheadCount)
+	case status.BehindCount > 0:
+		return fmt.Sprintf("behind %d", status.BehindCount)
+	case status.RemoteTrackingOK:
+		return "in sync"
+	default:
+		return ""
+	}
+}
+
+// vcsDisplayName returns the name shown for info: the detached ref when
+// HEAD is detached, the branch/change name when the VCS reports one, and
+// the repository name otherwise.
+func vcsDisplayName(info vcs.Info) string {
+	switch {
+	case info.Status.IsDetached && info.Status.DetachedRef != "":
+		return info.Status.DetachedRef
+	case info.Type != vcs.TypeNone && info.Status.CurrentBranch != "":
+		return info.Status.CurrentBranch
+	default:
+		return info.RepoName
+	}
+}
+
+// truncateVCSName shortens name to at most limit columns with an ellipsis.
+// A name with a short category prefix, such as "feature/", keeps the prefix
+// and the end of the name, starting at a word boundary where there is one:
+// "feature/JIRA-1234-really-long-description-here" becomes
+// "feature/…-here". Other names are cut at the end. A limit of zero or less
+// leaves name alone.
+func truncateVCSName(name string, limit int) string {
+	width := ansi.StringWidth(name)
+	if limit <= 0 || width <= limit {
+		return name
+	}
+	if i := strings.LastIndex(name, "/"); i > 0 {
+		prefix, rest := name[:i+1], name[i+1:]
+		avail := limit - ansi.StringWidth(prefix) - 1
+		if avail > 0 && ansi.StringWidth(prefix) <= limit/2 {
+			suffix := ansi.TruncateLeft(rest, ansi.StringWidth(rest)-avail, "")
+			if j := strings.IndexAny(suffix, "-_."); j >= 0 && j < len(suffix)-1 {
+				suffix = suffix[j:]
+			}
+			return prefix + "…" + suffix
+		}
+	}
+	return ansi.Truncate(name, limit, "…")
+}
+
+// vcsStateBadge picks the working tree badge for info: the highest priority
+// state with its file count when known, using the icons of info's VCS.
+func vcsStateBadge(info vcs.Info) vcsPart {
+	status := info.Status
+	switch {
+	case status.ToolUnavailable:
+		return vcsPart{styles.VCSMissingIcon, vcsToneMuted}
+	case status.Failure != nil:
+		return vcsPart{styles.VCSFailedIcon, vcsToneError}
+	case status.Locked:
+		return vcsPart{styles.GitLockedIcon, vcsToneMuted}
+	case status.IsBare:
+		return vcsPart{styles.GitBareIcon, vcsToneMuted}
+	}
+
+	switch info.Type {
+	case vcs.TypeJujutsu:
+		switch {
+		case status.HasConflicts:
+			return vcsPart{iconWithCount(styles.JJConflictIcon, status.ConflictCount), vcsToneConflict}
+		case status.IsDivergent || status.HasDivergentChanges:
+			return vcsPart{styles.JJDivergentIcon, vcsToneWarning}
+		case status.HasUncommitted:
+			return vcsPart{iconWithCount(styles.JJDirtyIcon, status.ModifiedCount), vcsToneWarning}
+		case status.NothingToCommit:
+			return vcsPart{styles.JJEmptyIcon, vcsToneSubtle}
+		default:
+			return vcsPart{styles.JJCleanIcon, vcsToneSuccess}
+		}
+	case vcs.TypeGit, vcs.TypeMercurial, vcs.TypeSubversion, vcs.TypeFossil:
+		switch {
+		case status.HasConflicts:
+			return vcsPart{iconWithCount(styles.GitConflictIcon, status.ConflictCount), vcsToneConflict}
+		case status.HasStaged:
+			return vcsPart{iconWithCount(styles.GitStagedIcon, status.StagedCount), vcsToneWarning}
+		case status.HasUncommitted:
+			return vcsPart{iconWithCount(styles.GitDirtyIcon, status.ModifiedCount), vcsToneWarning}
+		case status.HasUntracked:
+			return vcsPart{iconWithCount(styles.GitUntrackedIcon, status.UntrackedCount), vcsToneSubtle}
+		case status.IsUnborn:
+			return vcsPart{styles.GitUnbornIcon, vcsToneMuted}
+		default:
+			return vcsPart{styles.GitCleanIcon, vcsToneSuccess}
+		}
+	default:
+		return vcsPart{string(info.Type), vcsToneMuted}
+	}
+}
+
+// VCSBadges returns the VCS status as separate styled badges so a layout can
+// space them independently: the branch name, the working tree state (see
+// vcsStateBadge), the ahead/behind counts ("shallow" instead in a shallow
+// clone) and the stash count. Badges that don't apply are omitted. Returns
+// nil if no VCS is detected.
+func VCSBadges(info vcs.Info, t *styles.Theme) []string {
+	if info.Type == vcs.TypeNone {
+		return nil
+	}
+
+	status := info.Status
+	badges := []string{
+		t.S().Muted.Render(vcsDisplayName(info)),
+		renderVCSPart(vcsStateBadge(info), false, t),
+	}
+
+	switch {
+	case status.IsShallow:
+		badges = append(badges, t.S().Base.Foreground(t.FgMuted).Render("shallow"))
+	case status.UpstreamGone:
+		badges = append(badges, t.S().Base.Foreground(t.Warning).Render(styles.GitGoneIcon))
+	case status.AheadCount > 0 && status.BehindCount > 0:
+		badges = append(badges, t.S().Base.Foreground(t.Warning).Render(
+			fmt.Sprintf("%s%d %s%d", styles.GitUnpushedIcon, status.AheadCount, styles.GitBehindIcon, status.BehindCount),
+		))
+	case status.AheadCount > 0:
+		badges = append(badges, t.S().Base.Foreground(t.Info).Render(fmt.Sprintf("%s%d", styles.GitUnpushedIcon, status.AheadCount)))
+	case status.BehindCount > 0:
+		badges = append(badges, t.S().Base.Foreground(t.Info).Render(fmt.Sprintf("%s%d", styles.GitBehindIcon, status.BehindCount)))
+	}
+
+	if status.StashCount > 0 {
+		badges = append(badges, t.S().Base.Foreground(t.FgSubtle).Render(fmt.Sprintf("%s%d", styles.GitStashIcon, status.StashCount)))
+	}
+
+	return badges
+}
+
+// WorkspaceStatus counts the repositories of a multi-repo workspace by state.
+type WorkspaceStatus struct {
+	Dirty      int // Repositories with staged, modified or untracked files
+	Conflicted int // Repositories with merge conflicts
+	Unknown    int // Repositories whose working tree couldn't be read
+	Clean      int
+}
+
+// WorkspaceSummary aggregates infos into a WorkspaceStatus so one badge can
+// reflect the whole workspace. Repositories are bucketed like
+// vcs.Status.TrafficLight, except that locked, failed or unreadable ones are
+// counted as Unknown rather than as conflicted or dirty; infos without a
+// detected VCS are skipped.
+func WorkspaceSummary(infos []vcs.Info) WorkspaceStatus {
+	var ws WorkspaceStatus
+	for _, info := range infos {
+		if info.Type == vcs.TypeNone {
+			continue
+		}
+		status := info.Status
+		if status.Locked || status.Failure != nil || status.ToolUnavailable {
+			ws.Unknown++
+			continue
+		}
+		switch status.TrafficLight() {
+		case vcs.TrafficLightRed:
+			ws.Conflicted++
+		case vcs.TrafficLightYellow:
+			ws.Dirty++
+		default:
+			ws.Clean++
+		}
+	}
+	return ws
+}
+
+// String renders the non-zero counts, e.g. "2 dirty, 1 conflicted, 1
+// unknown, 5 clean".
+func (ws WorkspaceStatus) String() string {
+	var parts []string
+	if ws.Dirty > 0 {
+		parts = append(parts, fmt.Sprintf("%d dirty", ws.Dirty))
+	}
+	if ws.Conflicted > 0 {
+		parts = append(parts, fmt.Sprintf("%d conflicted", ws.Conflicted))
+	}
+	if ws.Unknown > 0 {
+		parts = append(parts, fmt.Sprintf("%d unknown", ws.Unknown))
+	}
+	if ws.Clean > 0 {
+		parts = append(parts, fmt.Sprintf("%d clean", ws.Clean))
+	}
+	return strings.Join(parts, ", ")
 }
@@ -0,0 +1,779 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"charm.land/lipgloss/v2"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/tui/styles"
+	"github.com/charmbracelet/crush/internal/vcs"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/charmbracelet/x/exp/golden"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatVCSInfoLayout(t *testing.T) {
+	t.Parallel()
+
+	info := vcs.Info{
+		Type:     vcs.TypeGit,
+		RepoName: "crush",
+		Status:   vcs.Status{CurrentBranch: "main"},
+	}
+	sep := func(s string) *string { return &s }
+	theme := styles.CurrentTheme()
+
+	tests := []struct {
+		name string
+		opts config.VCSOptions
+		want string
+	}{
+		{name: "default", opts: config.VCSOptions{}, want: "✓ main"},
+		{name: "custom separator", opts: config.VCSOptions{Separator: sep(" | ")}, want: "✓ | main"},
+		{name: "no separator", opts: config.VCSOptions{Separator: sep("")}, want: "✓main"},
+		{name: "name first", opts: config.VCSOptions{NameFirst: true}, want: "main ✓"},
+		{name: "name first with separator", opts: config.VCSOptions{Separator: sep(":"), NameFirst: true}, want: "main:✓"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := formatVCSInfo(info, tt.opts, false, theme)
+			require.Equal(t, tt.want, ansi.Strip(got))
+		})
+	}
+}
+
+func TestVCSBadges(t *testing.T) {
+	t.Parallel()
+
+	theme := styles.CurrentTheme()
+	plain := func(badges []string) []string {
+		out := make([]string, len(badges))
+		for i, b := range badges {
+			out[i] = ansi.Strip(b)
+		}
+		return out
+	}
+
+	t.Run("dirty, stashed and ahead", func(t *testing.T) {
+		t.Parallel()
+		info := vcs.Info{
+			Type: vcs.TypeGit,
+			Status: vcs.Status{
+				CurrentBranch:  "feature",
+				HasUncommitted: true,
+				AheadCount:     2,
+				HasUnpushed:    true,
+				StashCount:     1,
+			},
+		}
+		require.Equal(t, []string{"feature", "✗", "↑2", "⚑1"}, plain(VCSBadges(info, theme)))
+	})
+
+	t.Run("conflicted", func(t *testing.T) {
+		t.Parallel()
+		info := vcs.Info{Type: vcs.TypeGit, Status: vcs.Status{CurrentBranch: "main", HasConflicts: true, ConflictCount: 3}}
+		require.Equal(t, []string{"main", "✖3"}, plain(VCSBadges(info, theme)))
+
+		info.Status.ConflictCount = 0
+		require.Equal(t, []string{"main", "✖"}, plain(VCSBadges(info, theme)))
+	})
+
+	t.Run("counts modified and untracked files", func(t *testing.T) {
+		t.Parallel()
+		info := vcs.Info{Type: vcs.TypeGit, Status: vcs.Status{CurrentBranch: "main", HasUncommitted: true, ModifiedCount: 5}}
+		require.Equal(t, []string{"main", "✗5"}, plain(VCSBadges(info, theme)))
+
+		info.Status = vcs.Status{CurrentBranch: "main", HasUntracked: true, UntrackedCount: 2}
+		require.Equal(t, []string{"main", "?2"}, plain(VCSBadges(info, theme)))
+	})
+
+	t.Run("clean and in sync", func(t *testing.T) {
+		t.Parallel()
+		info := vcs.Info{Type: vcs.TypeGit, Status: vcs.Status{CurrentBranch: "main"}}
+		require.Equal(t, []string{"main", "✓"}, plain(VCSBadges(info, theme)))
+	})
+
+	t.Run("diverged", func(t *testing.T) {
+		t.Parallel()
+		info := vcs.Info{Type: vcs.TypeGit, Status: vcs.Status{CurrentBranch: "main", AheadCount: 3, BehindCount: 1}}
+		require.Equal(t, []string{"main", "✓", "↑3 ↓1"}, plain(VCSBadges(info, theme)))
+	})
+
+	t.Run("states without a readable working tree", func(t *testing.T) {
+		t.Parallel()
+		tests := []struct {
+			status vcs.Status
+			want   string
+		}{
+			{status: vcs.Status{Locked: true}, want: styles.GitLockedIcon},
+			{status: vcs.Status{IsUnborn: true}, want: styles.GitUnbornIcon},
+			{status: vcs.Status{Failure: vcs.ErrVCSCommandFailed}, want: styles.VCSFailedIcon},
+			{status: vcs.Status{ToolUnavailable: true}, want: styles.VCSMissingIcon},
+		}
+		for _, tt := range tests {
+			tt.status.CurrentBranch = "main"
+			info := vcs.Info{Type: vcs.TypeGit, Status: tt.status}
+			require.Equal(t, []string{"main", tt.want}, plain(VCSBadges(info, theme)))
+		}
+	})
+
+	t.Run("jujutsu icons", func(t *testing.T) {
+		t.Parallel()
+		info := vcs.Info{Type: vcs.TypeJujutsu, Status: vcs.Status{CurrentBranch: "kxqp"}}
+		require.Equal(t, []string{"kxqp", styles.JJCleanIcon}, plain(VCSBadges(info, theme)))
+
+		info.Status.HasUncommitted = true
+		require.Equal(t, []string{"kxqp", styles.JJDirtyIcon}, plain(VCSBadges(info, theme)))
+
+		info.Status.HasConflicts = true
+		require.Equal(t, []string{"kxqp", styles.JJConflictIcon}, plain(VCSBadges(info, theme)))
+	})
+
+	t.Run("no repository", func(t *testing.T) {
+		t.Parallel()
+		require.Nil(t, VCSBadges(vcs.Info{Type: vcs.TypeNone}, theme))
+	})
+}
+
+func TestFormatVCSInfoStagedIsClean(t *testing.T) {
+	t.Parallel()
+
+	theme := styles.CurrentTheme()
+	stagedOnly := vcs.Info{
+		Type:   vcs.TypeGit,
+		Status: vcs.Status{CurrentBranch: "main", HasStaged: true},
+	}
+	stagedAndDirty := vcs.Info{
+		Type:   vcs.TypeGit,
+		Status: vcs.Status{CurrentBranch: "main", HasStaged: true, HasUncommitted: true},
+	}
+
+	t.Run("staged icon by default", func(t *testing.T) {
+		t.Parallel()
+		got := formatVCSInfo(stagedOnly, config.VCSOptions{}, false, theme)
+		require.Equal(t, "● main", ansi.Strip(got))
+	})
+
+	t.Run("clean icon for staged-only when enabled", func(t *testing.T) {
+		t.Parallel()
+		got := formatVCSInfo(stagedOnly, config.VCSOptions{StagedIsClean: true}, false, theme)
+		require.Equal(t, "✓ main", ansi.Strip(got))
+	})
+
+	t.Run("staged icon when unstaged changes remain", func(t *testing.T) {
+		t.Parallel()
+		got := formatVCSInfo(stagedAndDirty, config.VCSOptions{StagedIsClean: true}, false, theme)
+		require.Equal(t, "● main", ansi.Strip(got))
+	})
+}
+
+func TestFormatVCSInfoDetached(t *testing.T) {
+	t.Parallel()
+
+	info := vcs.Info{
+		Type:     vcs.TypeGit,
+		RepoName: "crush",
+		Status:   vcs.Status{IsDetached: true, DetachedRef: "a1b2c3d"},
+	}
+	got := formatVCSInfo(info, config.VCSOptions{}, false, styles.CurrentTheme())
+	require.Equal(t, "⚠ a1b2c3d", ansi.Strip(got))
+}
+
+func TestFormatVCSInfoPulseConflict(t *testing.T) {
+	t.Parallel()
+
+	theme := styles.CurrentTheme()
+	info := vcs.Info{
+		Type:   vcs.TypeGit,
+		Status: vcs.Status{CurrentBranch: "main", HasConflicts: true},
+	}
+
+	t.Run("alternates colors on successive ticks when enabled", func(t *testing.T) {
+		t.Parallel()
+		opts := config.VCSOptions{PulseConflict: true}
+		pulse := false
+		first := formatVCSInfo(info, opts, pulse, theme)
+		pulse = !pulse
+		second := formatVCSInfo(info, opts, pulse, theme)
+		pulse = !pulse
+		third := formatVCSInfo(info, opts, pulse, theme)
+
+		require.NotEqual(t, first, second)
+		require.Equal(t, first, third)
+		require.Equal(t, ansi.Strip(first), ansi.Strip(second))
+	})
+
+	t.Run("static when disabled", func(t *testing.T) {
+		t.Parallel()
+		opts := config.VCSOptions{}
+		require.Equal(t, formatVCSInfo(info, opts, false, theme), formatVCSInfo(info, opts, true, theme))
+	})
+}
+
+func TestFormatVCSInfoJujutsuDivergent(t *testing.T) {
+	t.Parallel()
+
+	theme := styles.CurrentTheme()
+	info := vcs.Info{
+		Type:   vcs.TypeJujutsu,
+		Status: vcs.Status{CurrentBranch: "qpvuntsm", HasDivergentChanges: true, HasUncommitted: true},
+	}
+	require.Equal(t, "≠ qpvuntsm", ansi.Strip(formatVCSInfo(info, config.VCSOptions{}, false, theme)))
+
+	info.Status.HasConflicts = true
+	require.Equal(t, "× qpvuntsm", ansi.Strip(formatVCSInfo(info, config.VCSOptions{}, false, theme)))
+}
+
+func TestFormatVCSInfoSyncWords(t *testing.T) {
+	t.Parallel()
+
+	theme := styles.CurrentTheme()
+	opts := config.VCSOptions{SyncWords: true}
+
+	tests := []struct {
+		name   string
+		status vcs.Status
+		want   string
+	}{
+		{name: "ahead", status: vcs.Status{AheadCount: 3, HasUnpushed: true, RemoteTrackingOK: true}, want: "✓ main ahead 3"},
+		{name: "behind", status: vcs.Status{BehindCount: 1, RemoteTrackingOK: true}, want: "✓ main behind 1"},
+		{name: "diverged", status: vcs.Status{AheadCount: 3, BehindCount: 1, RemoteTrackingOK: true}, want: "✓ main diverged 3/1"},
+		{name: "in sync", status: vcs.Status{RemoteTrackingOK: true}, want: "✓ main in sync"},
+		{name: "no upstream", status: vcs.Status{}, want: "✓ main"},
+		{name: "dirty keeps its icon", status: vcs.Status{HasUncommitted: true, AheadCount: 2, RemoteTrackingOK: true}, want: "✗ main ahead 2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tt.status.CurrentBranch = "main"
+			info := vcs.Info{Type: vcs.TypeGit, Status: tt.status}
+			require.Equal(t, tt.want, ansi.Strip(formatVCSInfo(info, opts, false, theme)))
+		})
+	}
+
+	t.Run("arrows by default", func(t *testing.T) {
+		t.Parallel()
+		info := vcs.Info{Type: vcs.TypeGit, Status: vcs.Status{CurrentBranch: "main", AheadCount: 3, HasUnpushed: true}}
+		require.Equal(t, "↑ main", ansi.Strip(formatVCSInfo(info, config.VCSOptions{}, false, theme)))
+	})
+}
+
+func TestFormatVCSInfoSyncCounts(t *testing.T) {
+	t.Parallel()
+
+	theme := styles.CurrentTheme()
+	opts := config.VCSOptions{SyncCounts: true}
+
+	tests := []struct {
+		name   string
+		status vcs.Status
+		want   string
+	}{
+		{name: "diverged", status: vcs.Status{AheadCount: 3, BehindCount: 1}, want: "↕ main ↑3 ↓1"},
+		{name: "ahead", status: vcs.Status{AheadCount: 12, HasUnpushed: true}, want: "↑ main ↑12"},
+		{name: "behind", status: vcs.Status{BehindCount: 1}, want: "↓ main ↓1"},
+		{name: "in sync", status: vcs.Status{RemoteTrackingOK: true}, want: "✓ main"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tt.status.CurrentBranch = "main"
+			info := vcs.Info{Type: vcs.TypeGit, Status: tt.status}
+			require.Equal(t, tt.want, ansi.Strip(formatVCSInfo(info, opts, false, theme)))
+		})
+	}
+
+	t.Run("colors ahead and behind differently", func(t *testing.T) {
+		t.Parallel()
+		info := vcs.Info{Type: vcs.TypeGit, Status: vcs.Status{CurrentBranch: "main", AheadCount: 3, BehindCount: 1}}
+		got := formatVCSInfo(info, opts, false, theme)
+		require.Contains(t, got, theme.S().Base.Foreground(theme.Info).Render("↑3"))
+		require.Contains(t, got, theme.S().Base.Foreground(theme.Warning).Render("↓1"))
+	})
+
+	t.Run("icon only by default", func(t *testing.T) {
+		t.Parallel()
+		info := vcs.Info{Type: vcs.TypeGit, Status: vcs.Status{CurrentBranch: "main", AheadCount: 3, BehindCount: 1}}
+		require.Equal(t, "↕ main", ansi.Strip(formatVCSInfo(info, config.VCSOptions{}, false, theme)))
+	})
+}
+
+func TestFormatVCSInfoStash(t *testing.T) {
+	t.Parallel()
+
+	theme := styles.CurrentTheme()
+	info := vcs.Info{Type: vcs.TypeGit, Status: vcs.Status{CurrentBranch: "main", StashCount: 2}}
+	require.Equal(t, "✓ main ⚑2", ansi.Strip(formatVCSInfo(info, config.VCSOptions{}, false, theme)))
+
+	info.Status.AheadCount = 1
+	require.Equal(t, "↑ main ↑1 ⚑2", ansi.Strip(formatVCSInfo(info, config.VCSOptions{SyncCounts: true}, false, theme)))
+
+	info.Status.StashCount = 0
+	require.Equal(t, "↑ main", ansi.Strip(formatVCSInfo(info, config.VCSOptions{}, false, theme)))
+}
+
+func TestFormatVCSInfoOperation(t *testing.T) {
+	t.Parallel()
+
+	theme := styles.CurrentTheme()
+	info := vcs.Info{Type: vcs.TypeGit, Status: vcs.Status{CurrentBranch: "main", HasConflicts: true, Operation: vcs.OpRebase}}
+	got := formatVCSInfo(info, config.VCSOptions{}, false, theme)
+	require.Equal(t, "✖ main rebase", ansi.Strip(got))
+	require.Contains(t, got, theme.S().Base.Foreground(theme.Warning).Render("rebase"))
+
+	info.Status = vcs.Status{CurrentBranch: "main", Operation: vcs.OpBisect}
+	require.Equal(t, "✓ main bisect", ansi.Strip(formatVCSInfo(info, config.VCSOptions{}, false, theme)))
+}
+
+func TestFormatVCSInfoUnborn(t *testing.T) {
+	t.Parallel()
+
+	theme := styles.CurrentTheme()
+	info := vcs.Info{Type: vcs.TypeGit, Status: vcs.Status{CurrentBranch: "main", IsUnborn: true}}
+	require.Equal(t, "∅ main", ansi.Strip(formatVCSInfo(info, config.VCSOptions{}, false, theme)))
+
+	info.Status.HasUntracked = true
+	require.Equal(t, "? main", ansi.Strip(formatVCSInfo(info, config.VCSOptions{}, false, theme)))
+}
+
+func TestVCSOptionsForProjectOverride(t *testing.T) {
+	t.Parallel()
+
+	sep := "|"
+	global := config.VCSOptions{Separator: &sep, PulseConflict: true}
+
+	t.Run("project file overrides the keys it sets", func(t *testing.T) {
+		t.Parallel()
+		root := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(root, ".crush"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(root, ".crush", "vcs.json"), []byte(`{"name_first": true, "separator": " "}`), 0o644))
+
+		opts := vcsOptionsFor(root, global)
+		require.True(t, opts.NameFirst)
+		require.Equal(t, " ", opts.IconSeparator())
+		require.True(t, opts.PulseConflict, "unset keys keep the global value")
+		require.Equal(t, "|", *global.Separator, "global options are not modified")
+
+		info := vcs.Info{Type: vcs.TypeGit, RootPath: root, Status: vcs.Status{CurrentBranch: "main"}}
+		require.Equal(t, "main ✓", ansi.Strip(formatVCSInfo(info, opts, false, styles.CurrentTheme())))
+	})
+
+	t.Run("disabled per project", func(t *testing.T) {
+		t.Parallel()
+		root := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(root, ".crush"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(root, ".crush", "vcs.json"), []byte(`{"disabled": true}`), 0o644))
+		require.True(t, vcsOptionsFor(root, global).Disabled)
+	})
+
+	t.Run("no project file", func(t *testing.T) {
+		t.Parallel()
+		require.Equal(t, global, vcsOptionsFor(t.TempDir(), global))
+	})
+
+	t.Run("invalid project file", func(t *testing.T) {
+		t.Parallel()
+		root := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(root, ".crush"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(root, ".crush", "vcs.json"), []byte(`{"name_first": `), 0o644))
+		require.Equal(t, global, vcsOptionsFor(root, global))
+	})
+}
+
+func TestFormatVCSDetail(t *testing.T) {
+	t.Parallel()
+
+	t.Run("dirty and ahead", func(t *testing.T) {
+		t.Parallel()
+		committed := time.Date(2024, 5, 6, 7, 8, 9, 0, time.Local)
+		info := vcs.Info{
+			Type: vcs.TypeGit,
+			Status: vcs.Status{
+				CurrentBranch:        "feature",
+				RemoteTrackingOK:     true,
+				AheadCount:           2,
+				HasUnpushed:          true,
+				HasStaged:            true,
+				StagedCount:          1,
+				HasUncommitted:       true,
+				ModifiedCount:        3,
+				HasUntracked:         true,
+				LastCommitCommitTime: committed,
+			},
+		}
+		require.Equal(t, "Branch: feature\n"+
+			"Upstream: ahead 2\n"+
+			"Changes: 1 staged, 3 modified, untracked files\n"+
+			"Last commit: 2024-05-06 07:08:09", formatVCSDetail(info))
+	})
+
+	t.Run("stashed", func(t *testing.T) {
+		t.Parallel()
+		info := vcs.Info{Type: vcs.TypeGit, Status: vcs.Status{CurrentBranch: "main", StashCount: 2, TopStashDescription: "try new parser"}}
+		require.Contains(t, formatVCSDetail(info), "\nStash: 2 (latest: try new parser)")
+	})
+
+	t.Run("counts not gathered", func(t *testing.T) {
+		t.Parallel()
+		info := vcs.Info{Type: vcs.TypeGit, Status: vcs.Status{CurrentBranch: "main", HasUncommitted: true}}
+		require.Equal(t, "Branch: main\nUpstream: none\nChanges: modified", formatVCSDetail(info))
+	})
+
+	t.Run("clean detached", func(t *testing.T) {
+		t.Parallel()
+		info := vcs.Info{Type: vcs.TypeGit, Status: vcs.Status{IsDetached: true, DetachedRef: "PR #42"}}
+		require.Equal(t, "Branch: detached at PR #42\nUpstream: none\nChanges: clean", formatVCSDetail(info))
+	})
+}
+
+func TestFormatVCSInfoDetailed(t *testing.T) {
+	t.Parallel()
+
+	info := vcs.Info{Type: vcs.TypeGit, Status: vcs.Status{CurrentBranch: "main", HasUncommitted: true, ModifiedCount: 1}}
+	theme := styles.CurrentTheme()
+
+	short, detail := formatVCSInfoDetailed(info, config.VCSOptions{}, theme)
+	require.Equal(t, formatVCSInfo(info, config.VCSOptions{}, false, theme), short)
+	require.Equal(t, formatVCSDetail(info), detail)
+
+	short, detail = formatVCSInfoDetailed(info, config.VCSOptions{Disabled: true}, theme)
+	require.Empty(t, short)
+	require.Empty(t, detail)
+}
+
+func TestFormatVCSInfoLinkBranch(t *testing.T) {
+	t.Parallel()
+
+	theme := styles.CurrentTheme()
+	opts := config.VCSOptions{LinkBranch: true}
+	info := vcs.Info{
+		Type:     vcs.TypeGit,
+		FetchURL: "git@github.com:charmbracelet/crush.git",
+		Status:   vcs.Status{CurrentBranch: "main"},
+	}
+
+	t.Run("wraps the branch name in an OSC 8 link", func(t *testing.T) {
+		t.Parallel()
+		got := formatVCSInfo(info, opts, false, theme)
+		open := ansi.SetHyperlink("https://github.com/charmbracelet/crush/tree/main")
+		start := strings.Index(got, open)
+		end := strings.Index(got, ansi.ResetHyperlink())
+		require.GreaterOrEqual(t, start, 0)
+		require.Greater(t, end, start)
+		require.Equal(t, "main", ansi.Strip(got[start+len(open):end]))
+		require.Equal(t, "✓ main", ansi.Strip(got))
+	})
+
+	t.Run("plain without a browse URL", func(t *testing.T) {
+		t.Parallel()
+		noRemote := info
+		noRemote.FetchURL = ""
+		require.NotContains(t, formatVCSInfo(noRemote, opts, false, theme), "\x1b]8;")
+	})
+
+	t.Run("plain when disabled", func(t *testing.T) {
+		t.Parallel()
+		require.NotContains(t, formatVCSInfo(info, config.VCSOptions{}, false, theme), "\x1b]8;")
+	})
+}
+
+func TestWorkspaceSummary(t *testing.T) {
+	t.Parallel()
+
+	git := func(status vcs.Status) vcs.Info { return vcs.Info{Type: vcs.TypeGit, Status: status} }
+	infos := []vcs.Info{
+		git(vcs.Status{HasUncommitted: true}),
+		git(vcs.Status{HasUntracked: true}),
+		git(vcs.Status{HasConflicts: true, HasUncommitted: true}),
+		git(vcs.Status{}),
+		git(vcs.Status{AheadCount: 2, HasUnpushed: true}),
+		{Type: vcs.TypeJujutsu},
+		{Type: vcs.TypeNone},
+		git(vcs.Status{Failure: vcs.ErrVCSCommandFailed}),
+		git(vcs.Status{Locked: true}),
+		git(vcs.Status{ToolUnavailable: true}),
+	}
+
+	ws := WorkspaceSummary(infos)
+	require.Equal(t, WorkspaceStatus{Dirty: 2, Conflicted: 1, Unknown: 3, Clean: 3}, ws)
+	require.Equal(t, "2 dirty, 1 conflicted, 3 unknown, 3 clean", ws.String())
+
+	require.Equal(t, "1 clean", WorkspaceSummary(infos[3:4]).String())
+	require.Empty(t, WorkspaceSummary(nil).String())
+}
+
+func TestFormatVCSInfoJujutsuEmpty(t *testing.T) {
+	t.Parallel()
+
+	theme := styles.CurrentTheme()
+	info := vcs.Info{Type: vcs.TypeJujutsu, Status: vcs.Status{CurrentBranch: "qpvuntsm", NothingToCommit: true}}
+	require.Equal(t, "○ qpvuntsm", ansi.Strip(formatVCSInfo(info, config.VCSOptions{}, false, theme)))
+
+	info.Status.NothingToCommit = false
+	require.Equal(t, "@ qpvuntsm", ansi.Strip(formatVCSInfo(info, config.VCSOptions{}, false, theme)))
+
+	info.Status.HasUncommitted = true
+	require.Equal(t, "± qpvuntsm", ansi.Strip(formatVCSInfo(info, config.VCSOptions{}, false, theme)))
+
+	info.Status.IsDivergent = true
+	require.Equal(t, "≠ qpvuntsm", ansi.Strip(formatVCSInfo(info, config.VCSOptions{}, false, theme)))
+}
+
+func TestFormatVCSInfoMercurial(t *testing.T) {
+	t.Parallel()
+
+	theme := styles.CurrentTheme()
+	info := vcs.Info{Type: vcs.TypeMercurial, RepoName: "repo", Status: vcs.Status{CurrentBranch: "default"}}
+	require.Equal(t, "✓ default", ansi.Strip(formatVCSInfo(info, config.VCSOptions{}, false, theme)))
+
+	info.Status.HasUncommitted = true
+	require.Equal(t, "✗ default", ansi.Strip(formatVCSInfo(info, config.VCSOptions{}, false, theme)))
+}
+
+func TestInvalidateVCSCache(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	file := filepath.Join(root, ".crush", "vcs.json")
+	require.NoError(t, os.MkdirAll(filepath.Dir(file), 0o755))
+	require.NoError(t, os.WriteFile(file, []byte(`{"name_first": true}`), 0o644))
+	require.True(t, vcsOptionsFor(root, config.VCSOptions{}).NameFirst)
+
+	// The project file is cached, so edits aren't seen until invalidation.
+	require.NoError(t, os.WriteFile(file, []byte(`{"name_first": false}`), 0o644))
+	require.True(t, vcsOptionsFor(root, config.VCSOptions{}).NameFirst)
+
+	InvalidateVCSCache()
+	require.False(t, vcsOptionsFor(root, config.VCSOptions{}).NameFirst)
+}
+
+func TestVCSInfoWidthMatchesVisibleContent(t *testing.T) {
+	t.Parallel()
+
+	theme := styles.CurrentTheme()
+	info := vcs.Info{
+		Type:     vcs.TypeGit,
+		FetchURL: "git@github.com:charmbracelet/crush.git",
+		Status:   vcs.Status{CurrentBranch: "feature/x", HasUncommitted: true, AheadCount: 2},
+	}
+
+	for _, opts := range []config.VCSOptions{{}, {LinkBranch: true}} {
+		got := formatVCSInfo(info, opts, false, theme)
+		require.NotEqual(t, len(got), lipgloss.Width(got), "styled output should contain escapes")
+		require.Equal(t, utf8.RuneCountInString(ansi.Strip(got)), lipgloss.Width(got))
+	}
+}
+
+func TestFormatVCSInfoToolUnavailable(t *testing.T) {
+	t.Parallel()
+
+	theme := styles.CurrentTheme()
+	info := vcs.Info{Type: vcs.TypeGit, Status: vcs.Status{CurrentBranch: "main", ToolUnavailable: true}}
+	require.Equal(t, "⊘ main git not found", ansi.Strip(formatVCSInfo(info, config.VCSOptions{}, false, theme)))
+	require.Equal(t, "Branch: main\nChanges: unknown (git not found)", formatVCSDetail(info))
+
+	info = vcs.Info{Type: vcs.TypeJujutsu, RepoName: "repo", Status: vcs.Status{ToolUnavailable: true}}
+	require.Equal(t, "⊘ repo jj not found", ansi.Strip(formatVCSInfo(info, config.VCSOptions{}, false, theme)))
+}
+
+func TestFormatVCSInfoFailure(t *testing.T) {
+	t.Parallel()
+
+	theme := styles.CurrentTheme()
+	failed := fmt.Errorf("%w: git status: fatal: unable to read", vcs.ErrVCSCommandFailed)
+	info := vcs.Info{Type: vcs.TypeGit, Status: vcs.Status{CurrentBranch: "main", Failure: failed}}
+	require.Equal(t, "‼ main status failed", ansi.Strip(formatVCSInfo(info, config.VCSOptions{}, false, theme)))
+	require.Equal(t, "Branch: main\nChanges: unknown (status failed)", formatVCSDetail(info))
+
+	corrupt := fmt.Errorf("%w: %w: git status: index file corrupt", vcs.ErrRepoCorrupt, vcs.ErrVCSCommandFailed)
+	info.Status.Failure = corrupt
+	require.Equal(t, "‼ main repository corrupt", ansi.Strip(formatVCSInfo(info, config.VCSOptions{}, false, theme)))
+}
+
+func TestFormatVCSInfoBare(t *testing.T) {
+	t.Parallel()
+
+	theme := styles.CurrentTheme()
+	info := vcs.Info{Type: vcs.TypeGit, RepoName: "repo.git", Status: vcs.Status{CurrentBranch: "main", IsBare: true}}
+	require.Equal(t, "◇ main", ansi.Strip(formatVCSInfo(info, config.VCSOptions{}, false, theme)))
+	require.Equal(t, "Branch: main\nChanges: none (bare repository)", formatVCSDetail(info))
+}
+
+func TestFormatVCSInfoShallow(t *testing.T) {
+	t.Parallel()
+
+	theme := styles.CurrentTheme()
+	info := vcs.Info{Type: vcs.TypeGit, Status: vcs.Status{
+		CurrentBranch:    "main",
+		RemoteTrackingOK: true,
+		AheadCount:       40,
+		HasUnpushed:      true,
+		IsShallow:        true,
+	}}
+	for _, opts := range []config.VCSOptions{{}, {SyncWords: true}, {SyncCounts: true}} {
+		require.Equal(t, "✓ main shallow", ansi.Strip(formatVCSInfo(info, opts, false, theme)))
+	}
+	require.Equal(t, "Branch: main\nUpstream: unknown (shallow clone)\nChanges: clean", formatVCSDetail(info))
+
+	var badges []string
+	for _, badge := range VCSBadges(info, theme) {
+		badges = append(badges, ansi.Strip(badge))
+	}
+	require.Equal(t, []string{"main", "✓", "shallow"}, badges)
+}
+
+func TestFormatVCSInfoUpstreamGone(t *testing.T) {
+	t.Parallel()
+
+	theme := styles.CurrentTheme()
+	info := vcs.Info{Type: vcs.TypeGit, Status: vcs.Status{CurrentBranch: "feature", UpstreamGone: true}}
+	require.Equal(t, "↯ feature", ansi.Strip(formatVCSInfo(info, config.VCSOptions{}, false, theme)))
+	require.Equal(t, "✓ feature upstream gone", ansi.Strip(formatVCSInfo(info, config.VCSOptions{SyncWords: true}, false, theme)))
+	require.Equal(t, "Branch: feature\nUpstream: gone (deleted on the remote)\nChanges: clean", formatVCSDetail(info))
+
+	// Local changes still take priority.
+	info.Status.HasUncommitted = true
+	require.Equal(t, "✗ feature", ansi.Strip(formatVCSInfo(info, config.VCSOptions{}, false, theme)))
+}
+
+// plainStatuses covers the main branches of vcsLineFor for the plain-text
+// tests below.
+var plainStatuses = []struct {
+	name string
+	info vcs.Info
+	opts config.VCSOptions
+}{
+	{name: "clean", info: vcs.Info{Type: vcs.TypeGit, Status: vcs.Status{CurrentBranch: "main"}}},
+	{name: "dirty with stash", info: vcs.Info{Type: vcs.TypeGit, Status: vcs.Status{CurrentBranch: "main", HasUncommitted: true, StashCount: 2}}},
+	{name: "diverged counts", info: vcs.Info{Type: vcs.TypeGit, Status: vcs.Status{CurrentBranch: "main", AheadCount: 3, BehindCount: 1}}, opts: config.VCSOptions{SyncCounts: true}},
+	{name: "ahead words", info: vcs.Info{Type: vcs.TypeGit, Status: vcs.Status{CurrentBranch: "main", AheadCount: 2}}, opts: config.VCSOptions{SyncWords: true}},
+	{name: "rebase conflict", info: vcs.Info{Type: vcs.TypeGit, Status: vcs.Status{CurrentBranch: "feature", HasConflicts: true, Operation: vcs.OpRebase}}, opts: config.VCSOptions{PulseConflict: true}},
+	{name: "name first", info: vcs.Info{Type: vcs.TypeGit, Status: vcs.Status{CurrentBranch: "main", HasUntracked: true}}, opts: config.VCSOptions{NameFirst: true}},
+	{name: "linked", info: vcs.Info{Type: vcs.TypeGit, RemoteURL: "git@github.com:charmbracelet/crush.git", Status: vcs.Status{CurrentBranch: "main"}}, opts: config.VCSOptions{LinkBranch: true}},
+	{name: "tool missing", info: vcs.Info{Type: vcs.TypeGit, Status: vcs.Status{CurrentBranch: "main", ToolUnavailable: true}}},
+	{name: "jujutsu", info: vcs.Info{Type: vcs.TypeJujutsu, Status: vcs.Status{CurrentBranch: "kxqp", HasUncommitted: true}}},
+}
+
+func TestFormatVCSInfoPlain(t *testing.T) {
+	t.Parallel()
+
+	var b strings.Builder
+	for _, tt := range plainStatuses {
+		fmt.Fprintf(&b, "%s: %s\n", tt.name, formatVCSInfoPlain(tt.info, tt.opts))
+	}
+	golden.RequireEqual(t, []byte(b.String()))
+}
+
+func TestFormatVCSInfoPlainMatchesStyled(t *testing.T) {
+	t.Parallel()
+
+	theme := styles.CurrentTheme()
+	for _, tt := range plainStatuses {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			plain := formatVCSInfoPlain(tt.info, tt.opts)
+			require.Equal(t, ansi.Strip(formatVCSInfo(tt.info, tt.opts, true, theme)), plain)
+			require.Equal(t, plain, ansi.Strip(plain), "plain output has no escape sequences")
+		})
+	}
+}
+
+// slowDetector reports a clean git repository at the path it's given, or
+// waits for ctx when block is set.
+type slowDetector struct {
+	block bool
+}
+
+func (d *slowDetector) Detect(path string) (vcs.Info, error) {
+	return d.DetectContext(context.Background(), path)
+}
+
+func (d *slowDetector) DetectContext(ctx context.Context, path string) (vcs.Info, error) {
+	if d.block {
+		<-ctx.Done()
+		return vcs.Info{Type: vcs.TypeGit, RootPath: path}, ctx.Err()
+	}
+	return vcs.Info{Type: vcs.TypeGit, RootPath: path, Status: vcs.Status{CurrentBranch: "main"}}, nil
+}
+
+func TestDetectVCSInCancelled(t *testing.T) {
+	t.Parallel()
+
+	repo := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(repo, ".git"), 0o755))
+	detector := &slowDetector{block: true}
+	cache := vcs.NewCachingDetector(detector, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, ok := detectVCSIn(ctx, cache, repo)
+	require.False(t, ok, "nothing cached to fall back to")
+
+	detector.block = false
+	info, ok := detectVCSIn(context.Background(), cache, repo)
+	require.True(t, ok)
+	require.Equal(t, "main", info.Status.CurrentBranch)
+
+	// Once the entry has expired, a cancelled detection returns it rather
+	// than the partial result.
+	time.Sleep(5 * time.Millisecond)
+	detector.block = true
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	stale, ok := detectVCSIn(ctx, cache, repo)
+	require.True(t, ok)
+	require.Equal(t, info, stale)
+}
+
+func TestTruncateVCSName(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		in    string
+		limit int
+		want  string
+	}{
+		{name: "short", in: "main", limit: 24, want: "main"},
+		{name: "exact length", in: "feature/exactly-24-chars", limit: 24, want: "feature/exactly-24-chars"},
+		{name: "keeps prefix and suffix", in: "feature/JIRA-1234-really-long-description-here", limit: 24, want: "feature/…-here"},
+		{name: "suffix without boundary", in: "fix/abcdefghijklmnopqrstuvwxyz", limit: 12, want: "fix/…tuvwxyz"},
+		{name: "no prefix", in: "a-really-long-branch-name-without-a-category", limit: 24, want: "a-really-long-branch-na…"},
+		{name: "prefix too long", in: "a-really-long-category/name", limit: 24, want: "a-really-long-category/…"},
+		{name: "no limit", in: "feature/JIRA-1234-really-long-description-here", limit: 0, want: "feature/JIRA-1234-really-long-description-here"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := truncateVCSName(tt.in, tt.limit)
+			require.Equal(t, tt.want, got)
+			if tt.limit > 0 {
+				require.LessOrEqual(t, ansi.StringWidth(got), tt.limit)
+			}
+		})
+	}
+}
+
+func TestFormatVCSInfoTruncatesName(t *testing.T) {
+	t.Parallel()
+
+	theme := styles.CurrentTheme()
+	info := vcs.Info{Type: vcs.TypeGit, Status: vcs.Status{CurrentBranch: "feature/JIRA-1234-really-long-description-here"}}
+	require.Equal(t, "✓ feature/…-here", ansi.Strip(formatVCSInfo(info, config.VCSOptions{}, false, theme)))
+
+	limit := 0
+	require.Equal(t, "✓ feature/JIRA-1234-really-long-description-here", formatVCSInfoPlain(info, config.VCSOptions{MaxNameWidth: &limit}))
+	require.Equal(t, "Branch: feature/JIRA-1234-really-long-description-here\nUpstream: none\nChanges: clean", formatVCSDetail(info))
+}
@@ -0,0 +1,114 @@
+package vcs
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// AlertSeverity ranks how urgently an Alert needs attention.
+type AlertSeverity int
+
+const (
+	AlertInfo AlertSeverity = iota
+	AlertWarning
+	AlertError
+)
+
+func (s AlertSeverity) String() string {
+	switch s {
+	case AlertError:
+		return "error"
+	case AlertWarning:
+		return "warning"
+	default:
+		return "info"
+	}
+}
+
+// Alert is a single thing about a repository's status that may need the
+// user's attention.
+type Alert struct {
+	Severity AlertSeverity
+	Message  string
+}
+
+// Alerts lists what in the status needs attention, most severe first. Alerts
+// of the same severity keep a fixed order so the list is stable between
+// refreshes. A clean, in-sync repository has no alerts.
+func (s Status) Alerts() []Alert {
+	var alerts []Alert
+	add := func(severity AlertSeverity, format string, args ...any) {
+		alerts = append(alerts, Alert{Severity: severity, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch {
+	case errors.Is(s.Failure, ErrRepoCorrupt):
+		add(AlertError, "repository looks corrupt")
+	case s.Failure != nil:
+		add(AlertError, "status could not be read")
+	}
+	if s.HasConflicts {
+		add(AlertError, "merge conflicts present")
+	}
+	if s.Operation != OpNone {
+		add(AlertWarning, "%s in progress", s.Operation)
+	}
+	if s.Locked {
+		add(AlertWarning, "another git process is using the repository")
+	}
+	if s.ToolUnavailable {
+		add(AlertWarning, "version control tool not found on PATH")
+	}
+	if s.HasDivergentChanges {
+		add(AlertWarning, "divergent changes present")
+	}
+	if s.IsDetached {
+		add(AlertWarning, "HEAD is detached at %s", s.DetachedRef)
+	}
+	if s.UpstreamGone {
+		add(AlertWarning, "upstream branch is gone")
+	}
+	if s.AheadCount > 0 && s.BehindCount > 0 {
+		add(AlertWarning, "diverged from upstream: %s ahead, %s behind", commits(s.AheadCount), commits(s.BehindCount))
+	} else {
+		if s.AheadCount > 0 {
+			add(AlertInfo, "%s ahead", commits(s.AheadCount))
+		}
+		if s.BehindCount > 0 {
+			add(AlertInfo, "%s behind", commits(s.BehindCount))
+		}
+	}
+	if s.IsUnborn {
+		add(AlertInfo, "no commits yet")
+	}
+	if s.HasStaged {
+		add(AlertInfo, "staged changes not committed")
+	}
+	if s.HasUncommitted {
+		add(AlertInfo, "uncommitted changes")
+	}
+	if s.HasUntracked {
+		add(AlertInfo, "untracked files")
+	}
+	if s.StashCount > 0 {
+		add(AlertInfo, "%d stashed %s", s.StashCount, plural(s.StashCount, "change", "changes"))
+	}
+
+	slices.SortStableFunc(alerts, func(a, b Alert) int {
+		return cmp.Compare(b.Severity, a.Severity)
+	})
+	return alerts
+}
+
+func commits(n int) string {
+	return fmt.Sprintf("%d %s", n, plural(n, "commit", "commits"))
+}
+
+func plural(n int, singular, plural string) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
@@ -0,0 +1,112 @@
+package vcs
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusAlerts(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		status Status
+		want   []Alert
+	}{
+		{
+			name:   "clean",
+			status: Status{CurrentBranch: "main", RemoteTrackingOK: true},
+			want:   nil,
+		},
+		{
+			name:   "behind",
+			status: Status{CurrentBranch: "main", BehindCount: 1},
+			want:   []Alert{{AlertInfo, "1 commit behind"}},
+		},
+		{
+			name:   "conflicts rank above everything else",
+			status: Status{HasUntracked: true, StashCount: 2, HasConflicts: true, IsDetached: true, DetachedRef: "a1b2c3d"},
+			want: []Alert{
+				{AlertError, "merge conflicts present"},
+				{AlertWarning, "HEAD is detached at a1b2c3d"},
+				{AlertInfo, "untracked files"},
+				{AlertInfo, "2 stashed changes"},
+			},
+		},
+		{
+			name:   "diverged is a single warning",
+			status: Status{AheadCount: 3, BehindCount: 1, HasUncommitted: true},
+			want: []Alert{
+				{AlertWarning, "diverged from upstream: 3 commits ahead, 1 commit behind"},
+				{AlertInfo, "uncommitted changes"},
+			},
+		},
+		{
+			name:   "ahead with staged work",
+			status: Status{AheadCount: 2, HasUnpushed: true, HasStaged: true},
+			want: []Alert{
+				{AlertInfo, "2 commits ahead"},
+				{AlertInfo, "staged changes not committed"},
+			},
+		},
+		{
+			name:   "upstream gone",
+			status: Status{CurrentBranch: "feature", UpstreamGone: true},
+			want:   []Alert{{AlertWarning, "upstream branch is gone"}},
+		},
+		{
+			name:   "locked",
+			status: Status{Locked: true},
+			want:   []Alert{{AlertWarning, "another git process is using the repository"}},
+		},
+		{
+			name:   "status failed",
+			status: Status{CurrentBranch: "main", Failure: fmt.Errorf("%w: git status: fatal", ErrVCSCommandFailed)},
+			want:   []Alert{{AlertError, "status could not be read"}},
+		},
+		{
+			name:   "corrupt",
+			status: Status{Failure: fmt.Errorf("%w: %w: git status: bad object HEAD", ErrRepoCorrupt, ErrVCSCommandFailed)},
+			want:   []Alert{{AlertError, "repository looks corrupt"}},
+		},
+		{
+			name:   "tool unavailable",
+			status: Status{CurrentBranch: "main", ToolUnavailable: true},
+			want:   []Alert{{AlertWarning, "version control tool not found on PATH"}},
+		},
+		{
+			name:   "unborn",
+			status: Status{CurrentBranch: "main", IsUnborn: true, HasUntracked: true},
+			want: []Alert{
+				{AlertInfo, "no commits yet"},
+				{AlertInfo, "untracked files"},
+			},
+		},
+		{
+			name:   "rebase in progress",
+			status: Status{Operation: OpRebase, HasConflicts: true, IsDetached: true, DetachedRef: "a1b2c3d"},
+			want: []Alert{
+				{AlertError, "merge conflicts present"},
+				{AlertWarning, "rebase in progress"},
+				{AlertWarning, "HEAD is detached at a1b2c3d"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, tt.status.Alerts())
+		})
+	}
+}
+
+func TestAlertSeverityString(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "error", AlertError.String())
+	require.Equal(t, "warning", AlertWarning.String())
+	require.Equal(t, "info", AlertInfo.String())
+}
@@ -0,0 +1,136 @@
+package vcs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// CachingDetector wraps a Detector and reuses its last result for a
+// repository until the repository's state file changes: the index (and HEAD)
+// for Git, the working copy for Jujutsu. Edits to files in the working tree
+// don't touch those, so entries also expire after a TTL.
+type CachingDetector struct {
+	inner           Detector
+	ttl             time.Duration
+	searchAboveHome bool // See DetectOptions.SearchAboveHome
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+// cacheEntry is a cached detection result for one repository root.
+type cacheEntry struct {
+	info     Info
+	err      error // ErrVCSCommandFailed, which retrying right away won't fix
+	stamp    time.Time
+	cachedAt time.Time
+}
+
+// NewCachingDetector returns a CachingDetector around inner whose entries
+// expire after ttl even when the state files look unchanged, which also
+// covers filesystems with unreliable mtimes. A ttl of zero or less disables
+// expiry.
+func NewCachingDetector(inner Detector, ttl time.Duration) *CachingDetector {
+	return NewCachingDetectorWithOptions(inner, ttl, DetectOptions{})
+}
+
+// NewCachingDetectorWithOptions is like NewCachingDetector for an inner
+// detector created with opts. The cache looks up repository roots the same
+// way, so with opts.SearchAboveHome it also caches repositories above the
+// home directory.
+func NewCachingDetectorWithOptions(inner Detector, ttl time.Duration, opts DetectOptions) *CachingDetector {
+	return &CachingDetector{
+		inner:           inner,
+		ttl:             ttl,
+		searchAboveHome: opts.SearchAboveHome,
+		entries:         make(map[string]cacheEntry),
+	}
+}
+
+// repoRoot returns the root and type of the repository containing path,
+// searching as far up as the inner detector does.
+func (c *CachingDetector) repoRoot(path string) (string, Type, bool) {
+	return RepoRootForWithOptions(path, DetectOptions{SearchAboveHome: c.searchAboveHome})
+}
+
+// Detect returns the cached Info for the repository containing path, running
+// the inner detector only when there's no fresh entry.
+func (c *CachingDetector) Detect(path string) (Info, error) {
+	return c.DetectContext(context.Background(), path)
+}
+
+// DetectContext is like Detect, but honors ctx as described on Detector.
+// Results that come with an error aren't cached, except command failures
+// (ErrVCSCommandFailed), so a broken repository isn't queried on every
+// call.
+func (c *CachingDetector) DetectContext(ctx context.Context, path string) (Info, error) {
+	root, typ, ok := c.repoRoot(path)
+	if !ok {
+		return c.inner.DetectContext(ctx, path)
+	}
+	stamp := cacheStamp(root, typ)
+
+	c.mu.Lock()
+	entry, hit := c.entries[root]
+	c.mu.Unlock()
+	if hit && entry.stamp.Equal(stamp) && (c.ttl <= 0 || time.Since(entry.cachedAt) < c.ttl) {
+		return entry.info, entry.err
+	}
+
+	info, err := c.inner.DetectContext(ctx, path)
+	if err != nil && !errors.Is(err, ErrVCSCommandFailed) {
+		return info, err
+	}
+	c.mu.Lock()
+	c.entries[root] = cacheEntry{info: info, err: err, stamp: stamp, cachedAt: time.Now()}
+	c.mu.Unlock()
+	return info, err
+}
+
+// Stale returns the last Info cached for the repository containing path
+// however old it is, for callers that gave up on a fresh detection and would
+// rather show something dated than nothing. It reports false when nothing
+// was cached for the repository.
+func (c *CachingDetector) Stale(path string) (Info, bool) {
+	root, _, ok := c.repoRoot(path)
+	if !ok {
+		return Info{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, hit := c.entries[root]
+	return entry.info, hit
+}
+
+// Invalidate drops every cached entry.
+func (c *CachingDetector) Invalidate() {
+	c.mu.Lock()
+	clear(c.entries)
+	c.mu.Unlock()
+}
+
+// cacheStamp returns the latest mtime of the files whose changes invalidate
+// a cached status for the repository at root. It's the zero time for VCS
+// types without such a file, leaving only the TTL.
+func cacheStamp(root string, typ Type) time.Time {
+	var paths []string
+	switch typ {
+	case TypeGit:
+		gitDir := resolveGitDir(root)
+		paths = []string{filepath.Join(gitDir, "index"), filepath.Join(gitDir, "HEAD")}
+	case TypeJujutsu:
+		paths = []string{filepath.Join(root, ".jj", "working_copy")}
+	}
+
+	var latest time.Time
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
@@ -0,0 +1,170 @@
+package vcs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// countingDetector counts calls to the detector it wraps.
+type countingDetector struct {
+	inner Detector
+	calls atomic.Int32
+}
+
+func (d *countingDetector) Detect(path string) (Info, error) {
+	return d.DetectContext(context.Background(), path)
+}
+
+func (d *countingDetector) DetectContext(ctx context.Context, path string) (Info, error) {
+	d.calls.Add(1)
+	return d.inner.DetectContext(ctx, path)
+}
+
+// failingDetector reports a git repository along with err.
+type failingDetector struct {
+	err error
+}
+
+func (d *failingDetector) Detect(path string) (Info, error) {
+	return d.DetectContext(context.Background(), path)
+}
+
+func (d *failingDetector) DetectContext(_ context.Context, path string) (Info, error) {
+	return Info{Type: TypeGit, RootPath: path, Status: Status{Failure: d.err}}, d.err
+}
+
+func TestCachingDetector(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reuses the result while nothing changed", func(t *testing.T) {
+		t.Parallel()
+		repo := initGitRepo(t)
+		commitFile(t, repo, "a.txt", "a")
+		sub := filepath.Join(repo, "sub")
+		require.NoError(t, os.Mkdir(sub, 0o755))
+
+		counter := &countingDetector{inner: NewDetector()}
+		cache := NewCachingDetector(counter, time.Hour)
+		first, err := cache.Detect(repo)
+		require.NoError(t, err)
+		second, err := cache.Detect(sub)
+		require.NoError(t, err)
+
+		require.Equal(t, int32(1), counter.calls.Load())
+		require.Equal(t, first, second)
+	})
+
+	t.Run("re-detects when the index changes", func(t *testing.T) {
+		t.Parallel()
+		repo := initGitRepo(t)
+		commitFile(t, repo, "a.txt", "a")
+
+		counter := &countingDetector{inner: NewDetector()}
+		cache := NewCachingDetector(counter, time.Hour)
+		_, err := cache.Detect(repo)
+		require.NoError(t, err)
+
+		later := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(filepath.Join(repo, ".git", "index"), later, later))
+		_, err = cache.Detect(repo)
+		require.NoError(t, err)
+		require.Equal(t, int32(2), counter.calls.Load())
+	})
+
+	t.Run("caches command failures", func(t *testing.T) {
+		t.Parallel()
+		repo := initGitRepo(t)
+
+		failing := &failingDetector{err: fmt.Errorf("%w: git status", ErrVCSCommandFailed)}
+		counter := &countingDetector{inner: failing}
+		cache := NewCachingDetector(counter, time.Hour)
+		_, err := cache.Detect(repo)
+		require.ErrorIs(t, err, ErrVCSCommandFailed)
+		info, err := cache.Detect(repo)
+		require.ErrorIs(t, err, ErrVCSCommandFailed)
+		require.Equal(t, TypeGit, info.Type)
+		require.Equal(t, int32(1), counter.calls.Load())
+	})
+
+	t.Run("doesn't cache other errors", func(t *testing.T) {
+		t.Parallel()
+		repo := initGitRepo(t)
+
+		counter := &countingDetector{inner: &failingDetector{err: context.DeadlineExceeded}}
+		cache := NewCachingDetector(counter, time.Hour)
+		_, err := cache.Detect(repo)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		_, err = cache.Detect(repo)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Equal(t, int32(2), counter.calls.Load())
+	})
+
+	t.Run("re-detects after the TTL", func(t *testing.T) {
+		t.Parallel()
+		repo := initGitRepo(t)
+
+		counter := &countingDetector{inner: NewDetector()}
+		cache := NewCachingDetector(counter, time.Millisecond)
+		_, err := cache.Detect(repo)
+		require.NoError(t, err)
+		time.Sleep(5 * time.Millisecond)
+		_, err = cache.Detect(repo)
+		require.NoError(t, err)
+		require.Equal(t, int32(2), counter.calls.Load())
+	})
+
+	t.Run("invalidate drops entries", func(t *testing.T) {
+		t.Parallel()
+		repo := initGitRepo(t)
+
+		counter := &countingDetector{inner: NewDetector()}
+		cache := NewCachingDetector(counter, time.Hour)
+		_, err := cache.Detect(repo)
+		require.NoError(t, err)
+		cache.Invalidate()
+		_, err = cache.Detect(repo)
+		require.NoError(t, err)
+		require.Equal(t, int32(2), counter.calls.Load())
+	})
+
+	t.Run("stale returns expired entries", func(t *testing.T) {
+		t.Parallel()
+		repo := initGitRepo(t)
+
+		cache := NewCachingDetector(NewDetector(), time.Millisecond)
+		_, ok := cache.Stale(repo)
+		require.False(t, ok)
+
+		first, err := cache.Detect(repo)
+		require.NoError(t, err)
+		time.Sleep(5 * time.Millisecond)
+		stale, ok := cache.Stale(repo)
+		require.True(t, ok)
+		require.Equal(t, first, stale)
+
+		cache.Invalidate()
+		_, ok = cache.Stale(repo)
+		require.False(t, ok)
+	})
+
+	t.Run("outside a repository is never cached", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+
+		counter := &countingDetector{inner: NewDetector()}
+		cache := NewCachingDetector(counter, time.Hour)
+		for range 2 {
+			info, err := cache.Detect(dir)
+			require.NoError(t, err)
+			require.Equal(t, TypeNone, info.Type)
+		}
+		require.Equal(t, int32(2), counter.calls.Load())
+	})
+}
@@ -0,0 +1,97 @@
+package vcs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+var (
+	// ErrVCSCommandFailed is returned, wrapped, when a command that status
+	// detection depends on fails outright, so the status can't be trusted.
+	// Expected non-zero exits, such as "git diff --quiet" finding changes,
+	// aren't failures.
+	ErrVCSCommandFailed = errors.New("vcs command failed")
+
+	// ErrRepoCorrupt is returned, wrapped along with ErrVCSCommandFailed,
+	// when the failing command reported a damaged repository.
+	ErrRepoCorrupt = errors.New("repository is corrupt")
+)
+
+// corruptionMessages are fragments of git error output that point at a
+// damaged repository rather than a transient or environmental failure.
+// osRunner runs git in the C locale, so they're always in English.
+var corruptionMessages = []string{
+	"corrupt",
+	"bad object",
+	"bad signature",
+	"index file smaller than expected",
+	"unable to read tree",
+	"broken link from",
+	"missing blob",
+	"missing tree",
+}
+
+// notARepositoryMessage is git's complaint about a marker directory it
+// doesn't recognize as a repository at all, such as an empty .git. That's
+// reported as a repository with an unknown status, as before, rather than
+// as a failure.
+const notARepositoryMessage = "not a git repository"
+
+// commandFailure wraps err from running name with args as
+// ErrVCSCommandFailed, adding ErrRepoCorrupt when its error output points at
+// a damaged repository. It returns nil when ctx is done, since the caller
+// reports that as ctx.Err() instead, and for notARepositoryMessage.
+func commandFailure(ctx context.Context, err error, name string, args ...string) error {
+	if err == nil || ctx.Err() != nil {
+		return nil
+	}
+	cmdline := strings.Join(append([]string{name}, args...), " ")
+
+	var stderr string
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		stderr = strings.TrimSpace(string(exitErr.Stderr))
+	}
+	if isNotARepository(err) {
+		return nil
+	}
+	if stderr == "" {
+		return fmt.Errorf("%w: %s: %w", ErrVCSCommandFailed, cmdline, err)
+	}
+	if isCorruptionMessage(stderr) {
+		return fmt.Errorf("%w: %w: %s: %s", ErrRepoCorrupt, ErrVCSCommandFailed, cmdline, stderr)
+	}
+	return fmt.Errorf("%w: %s: %s", ErrVCSCommandFailed, cmdline, stderr)
+}
+
+// isNotARepository reports whether err is git refusing to run because it
+// doesn't recognize the directory as a repository.
+func isNotARepository(err error) bool {
+	var exitErr *exec.ExitError
+	return errors.As(err, &exitErr) && strings.Contains(string(exitErr.Stderr), notARepositoryMessage)
+}
+
+// isCorruptionMessage reports whether git error output describes a damaged
+// repository.
+func isCorruptionMessage(stderr string) bool {
+	stderr = strings.ToLower(stderr)
+	for _, msg := range corruptionMessages {
+		if strings.Contains(stderr, msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// exitCode returns the exit status of a command that ran and failed, or -1
+// when err isn't an exit status, such as when the command couldn't start.
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
@@ -0,0 +1,96 @@
+package vcs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandFailure(t *testing.T) {
+	t.Parallel()
+
+	exit := func(stderr string) error { return &exec.ExitError{Stderr: []byte(stderr)} }
+	args := []string{"status", "--porcelain=v2"}
+
+	t.Run("corrupt repository", func(t *testing.T) {
+		t.Parallel()
+		err := commandFailure(t.Context(), exit("fatal: bad object HEAD\n"), "git", args...)
+		require.ErrorIs(t, err, ErrVCSCommandFailed)
+		require.ErrorIs(t, err, ErrRepoCorrupt)
+		require.ErrorContains(t, err, "git status --porcelain=v2: fatal: bad object HEAD")
+	})
+
+	t.Run("other failure", func(t *testing.T) {
+		t.Parallel()
+		err := commandFailure(t.Context(), exit("fatal: unable to access '.git/config'\n"), "git", args...)
+		require.ErrorIs(t, err, ErrVCSCommandFailed)
+		require.NotErrorIs(t, err, ErrRepoCorrupt)
+	})
+
+	t.Run("command didn't start", func(t *testing.T) {
+		t.Parallel()
+		cause := errors.New("permission denied")
+		err := commandFailure(t.Context(), cause, "git", args...)
+		require.ErrorIs(t, err, ErrVCSCommandFailed)
+		require.ErrorIs(t, err, cause)
+	})
+
+	t.Run("not a repository", func(t *testing.T) {
+		t.Parallel()
+		err := commandFailure(t.Context(), exit("fatal: not a git repository (or any of the parent directories): .git\n"), "git", args...)
+		require.NoError(t, err)
+	})
+
+	t.Run("context done", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		require.NoError(t, commandFailure(ctx, exit("fatal: bad object HEAD\n"), "git", args...))
+	})
+}
+
+func TestDetectCorruptIndex(t *testing.T) {
+	t.Parallel()
+
+	repo := initGitRepo(t)
+	commitFile(t, repo, "a.txt", "a")
+	require.NoError(t, os.WriteFile(filepath.Join(repo, ".git", "index"), []byte("DIRC"), 0o644))
+
+	info, err := NewDetector().Detect(repo)
+	require.ErrorIs(t, err, ErrVCSCommandFailed)
+	require.ErrorIs(t, err, ErrRepoCorrupt)
+	require.Equal(t, TypeGit, info.Type)
+	require.NotEmpty(t, info.Status.CurrentBranch)
+	require.ErrorIs(t, info.Status.Failure, ErrRepoCorrupt)
+}
+
+func TestDetectTranslatedGit(t *testing.T) {
+	// Not parallel: the environment must not leak into other tests.
+	t.Setenv("LANG", "de_DE.UTF-8")
+	t.Setenv("LC_ALL", "de_DE.UTF-8")
+	t.Setenv("LANGUAGE", "de")
+
+	out, err := osRunner{}.Run(t.Context(), t.TempDir(), "sh", "-c", "echo $LC_ALL $LANGUAGE")
+	require.NoError(t, err)
+	require.Equal(t, "C C\n", string(out))
+
+	// An empty .git is still recognized as not a repository rather than
+	// reported as a failure.
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, ".git"), 0o755))
+	info, err := NewDetector().Detect(dir)
+	require.NoError(t, err)
+	require.Equal(t, TypeGit, info.Type)
+	require.NoError(t, info.Status.Failure)
+
+	repo := initGitRepo(t)
+	commitFile(t, repo, "a.txt", "a")
+	require.NoError(t, os.WriteFile(filepath.Join(repo, ".git", "index"), []byte("DIRC"), 0o644))
+	_, err = NewDetector().Detect(repo)
+	require.ErrorIs(t, err, ErrRepoCorrupt)
+}
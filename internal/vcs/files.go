@@ -0,0 +1,49 @@
+package vcs
+
+import "strings"
+
+// MaxChangedFiles caps Status.ChangedFiles so a huge change set, such as a
+// freshly unpacked dependency tree, doesn't hold every path in memory.
+const MaxChangedFiles = 500
+
+// FileCode describes how a file differs from HEAD. Staged and unstaged
+// can be combined; untracked and conflicted stand alone.
+type FileCode uint8
+
+const (
+	FileStaged     FileCode = 1 << iota // Changed in the index
+	FileUnstaged                        // Changed in the working tree but not staged
+	FileUntracked                       // Not tracked and not ignored
+	FileConflicted                      // Unmerged after a conflicting merge, rebase or similar
+)
+
+// Has reports whether c includes every bit of other.
+func (c FileCode) Has(other FileCode) bool {
+	return c&other == other
+}
+
+// String lists the states in c, e.g. "staged+unstaged".
+func (c FileCode) String() string {
+	var states []string
+	for _, state := range []struct {
+		code FileCode
+		name string
+	}{
+		{FileStaged, "staged"},
+		{FileUnstaged, "unstaged"},
+		{FileUntracked, "untracked"},
+		{FileConflicted, "conflicted"},
+	} {
+		if c.Has(state.code) {
+			states = append(states, state.name)
+		}
+	}
+	return strings.Join(states, "+")
+}
+
+// FileStatus is a changed file and how it changed. Path is relative to the
+// repository root; renamed files are listed under their new path.
+type FileStatus struct {
+	Path string
+	Code FileCode
+}
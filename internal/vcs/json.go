@@ -0,0 +1,196 @@
+package vcs
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// DetectJSON detects the repository at or above path like Detect and returns
+// the Info as JSON, for shell prompts and editor plugins. A failed VCS
+// command doesn't make it fail; the JSON reports it in status.failure.
+func DetectJSON(path string) ([]byte, error) {
+	info, err := NewDetector().Detect(path)
+	if err != nil && !errors.Is(err, ErrVCSCommandFailed) {
+		return nil, err
+	}
+	return json.Marshal(info)
+}
+
+// MarshalJSON encodes the Info with stable snake_case keys. Every key is
+// always present so scripts don't have to check for them: type is "none"
+// when no repository was found, times are RFC 3339 or null, and
+// last_commit is null unless it was read.
+func (i Info) MarshalJSON() ([]byte, error) {
+	typ := string(i.Type)
+	if i.Type == TypeNone {
+		typ = "none"
+	}
+	var lastCommit *commitInfoJSON
+	if i.LastCommit != nil {
+		lastCommit = &commitInfoJSON{
+			ShortSHA:   i.LastCommit.ShortSHA,
+			Subject:    i.LastCommit.Subject,
+			AuthorName: i.LastCommit.AuthorName,
+			When:       timeOrNil(i.LastCommit.When),
+		}
+	}
+	return json.Marshal(infoJSON{
+		Type:           typ,
+		RepoName:       i.RepoName,
+		RootPath:       i.RootPath,
+		LinkedWorktree: i.LinkedWorktree,
+		Submodule:      i.Submodule,
+		IsColocated:    i.IsColocated,
+		RemoteURL:      i.RemoteURL,
+		FetchURL:       i.FetchURL,
+		PushURL:        i.PushURL,
+		MergeTool:      i.MergeTool,
+		Editor:         i.Editor,
+		Status:         newStatusJSON(i.Status),
+		LastCommit:     lastCommit,
+	})
+}
+
+// infoJSON is the JSON form of Info. Keys are part of the package's API;
+// add new ones rather than renaming.
+type infoJSON struct {
+	Type           string          `json:"type"`
+	RepoName       string          `json:"repo_name"`
+	RootPath       string          `json:"root_path"`
+	LinkedWorktree bool            `json:"linked_worktree"`
+	Submodule      bool            `json:"submodule"`
+	IsColocated    bool            `json:"is_colocated"`
+	RemoteURL      string          `json:"remote_url"`
+	FetchURL       string          `json:"fetch_url"`
+	PushURL        string          `json:"push_url"`
+	MergeTool      string          `json:"merge_tool"`
+	Editor         string          `json:"editor"`
+	Status         statusJSON      `json:"status"`
+	LastCommit     *commitInfoJSON `json:"last_commit"`
+}
+
+// commitInfoJSON is the JSON form of CommitInfo.
+type commitInfoJSON struct {
+	ShortSHA   string     `json:"short_sha"`
+	Subject    string     `json:"subject"`
+	AuthorName string     `json:"author_name"`
+	When       *time.Time `json:"when"`
+}
+
+// statusJSON is the JSON form of Status, with one key per field.
+type statusJSON struct {
+	HasUncommitted        bool             `json:"has_uncommitted"`
+	HasUntracked          bool             `json:"has_untracked"`
+	HasConflicts          bool             `json:"has_conflicts"`
+	HasStaged             bool             `json:"has_staged"`
+	AheadCount            int              `json:"ahead_count"`
+	BehindCount           int              `json:"behind_count"`
+	CurrentBranch         string           `json:"current_branch"`
+	IsDetached            bool             `json:"is_detached"`
+	DetachedRef           string           `json:"detached_ref"`
+	HasUnpushed           bool             `json:"has_unpushed"`
+	RemoteTrackingOK      bool             `json:"remote_tracking_ok"`
+	UpstreamGone          bool             `json:"upstream_gone"`
+	HasAlternates         bool             `json:"has_alternates"`
+	HasCommitGraph        bool             `json:"has_commit_graph"`
+	IsShallow             bool             `json:"is_shallow"`
+	AtReleasedTag         bool             `json:"at_released_tag"`
+	Locked                bool             `json:"locked"`
+	StashCount            int              `json:"stash_count"`
+	TopStashDescription   string           `json:"top_stash_description"`
+	HasHooks              bool             `json:"has_hooks"`
+	OnProtectedBranch     bool             `json:"on_protected_branch"`
+	BranchDescription     string           `json:"branch_description"`
+	NothingToCommit       bool             `json:"nothing_to_commit"`
+	AuthorCount           int              `json:"author_count"`
+	IsUnborn              bool             `json:"is_unborn"`
+	IsBare                bool             `json:"is_bare"`
+	StagedCount           int              `json:"staged_count"`
+	ModifiedCount         int              `json:"modified_count"`
+	UntrackedCount        int              `json:"untracked_count"`
+	ConflictCount         int              `json:"conflict_count"`
+	LastCommitAuthorTime  *time.Time       `json:"last_commit_author_time"`
+	LastCommitCommitTime  *time.Time       `json:"last_commit_commit_time"`
+	LastFetchTime         *time.Time       `json:"last_fetch_time"`
+	FetchStale            bool             `json:"fetch_stale"`
+	ChangedFiles          []fileStatusJSON `json:"changed_files"`
+	ChangedFilesTruncated bool             `json:"changed_files_truncated"`
+	Operation             string           `json:"operation"`
+	HasDivergentChanges   bool             `json:"has_divergent_changes"`
+	IsDivergent           bool             `json:"is_divergent"`
+	Failure               string           `json:"failure"`
+	ToolUnavailable       bool             `json:"tool_unavailable"`
+	ParentDescription     string           `json:"parent_description"`
+}
+
+// fileStatusJSON is the JSON form of FileStatus. The code is spelled out as
+// in FileCode.String, e.g. "staged+unstaged".
+type fileStatusJSON struct {
+	Path string `json:"path"`
+	Code string `json:"code"`
+}
+
+// newStatusJSON converts s to its JSON form.
+func newStatusJSON(s Status) statusJSON {
+	files := make([]fileStatusJSON, 0, len(s.ChangedFiles))
+	for _, file := range s.ChangedFiles {
+		files = append(files, fileStatusJSON{Path: file.Path, Code: file.Code.String()})
+	}
+	var failure string
+	if s.Failure != nil {
+		failure = s.Failure.Error()
+	}
+	return statusJSON{
+		HasUncommitted:        s.HasUncommitted,
+		HasUntracked:          s.HasUntracked,
+		HasConflicts:          s.HasConflicts,
+		HasStaged:             s.HasStaged,
+		AheadCount:            s.AheadCount,
+		BehindCount:           s.BehindCount,
+		CurrentBranch:         s.CurrentBranch,
+		IsDetached:            s.IsDetached,
+		DetachedRef:           s.DetachedRef,
+		HasUnpushed:           s.HasUnpushed,
+		RemoteTrackingOK:      s.RemoteTrackingOK,
+		UpstreamGone:          s.UpstreamGone,
+		HasAlternates:         s.HasAlternates,
+		HasCommitGraph:        s.HasCommitGraph,
+		IsShallow:             s.IsShallow,
+		AtReleasedTag:         s.AtReleasedTag,
+		Locked:                s.Locked,
+		StashCount:            s.StashCount,
+		TopStashDescription:   s.TopStashDescription,
+		HasHooks:              s.HasHooks,
+		OnProtectedBranch:     s.OnProtectedBranch,
+		BranchDescription:     s.BranchDescription,
+		NothingToCommit:       s.NothingToCommit,
+		AuthorCount:           s.AuthorCount,
+		IsUnborn:              s.IsUnborn,
+		IsBare:                s.IsBare,
+		StagedCount:           s.StagedCount,
+		ModifiedCount:         s.ModifiedCount,
+		UntrackedCount:        s.UntrackedCount,
+		ConflictCount:         s.ConflictCount,
+		LastCommitAuthorTime:  timeOrNil(s.LastCommitAuthorTime),
+		LastCommitCommitTime:  timeOrNil(s.LastCommitCommitTime),
+		LastFetchTime:         timeOrNil(s.LastFetchTime),
+		FetchStale:            s.FetchStale,
+		ChangedFiles:          files,
+		ChangedFilesTruncated: s.ChangedFilesTruncated,
+		Operation:             s.Operation.String(),
+		HasDivergentChanges:   s.HasDivergentChanges,
+		IsDivergent:           s.IsDivergent,
+		Failure:               failure,
+		ToolUnavailable:       s.ToolUnavailable,
+		ParentDescription:     s.ParentDescription,
+	}
+}
+
+// timeOrNil returns nil for the zero time, which encodes as null.
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
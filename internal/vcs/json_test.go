@@ -0,0 +1,96 @@
+package vcs
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/x/exp/golden"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInfoMarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	when := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	info := Info{
+		Type:      TypeGit,
+		RepoName:  "crush",
+		RootPath:  "/src/crush",
+		RemoteURL: "git@github.com:charmbracelet/crush.git",
+		FetchURL:  "git@github.com:charmbracelet/crush.git",
+		PushURL:   "git@github.com:charmbracelet/crush.git",
+		Status: Status{
+			HasStaged:            true,
+			StagedCount:          1,
+			HasUntracked:         true,
+			UntrackedCount:       1,
+			CurrentBranch:        "main",
+			RemoteTrackingOK:     true,
+			AheadCount:           2,
+			HasUnpushed:          true,
+			StashCount:           1,
+			TopStashDescription:  "wip",
+			LastCommitAuthorTime: when,
+			LastCommitCommitTime: when,
+			ChangedFiles: []FileStatus{
+				{Path: "a.go", Code: FileStaged | FileUnstaged},
+				{Path: "notes.txt", Code: FileUntracked},
+			},
+			Operation: OpRebase,
+			Failure:   fmt.Errorf("%w: git stash list", ErrVCSCommandFailed),
+		},
+		LastCommit: &CommitInfo{ShortSHA: "abc1234", Subject: "fix: handle empty input", AuthorName: "Ada", When: when},
+	}
+
+	out, err := json.MarshalIndent(info, "", "  ")
+	require.NoError(t, err)
+	golden.RequireEqual(t, out)
+
+	out, err = json.Marshal(Info{Type: TypeNone})
+	require.NoError(t, err)
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(out, &decoded))
+	require.Equal(t, "none", decoded["type"])
+	require.Nil(t, decoded["last_commit"])
+	require.Equal(t, []any{}, decoded["status"].(map[string]any)["changed_files"])
+}
+
+func TestStatusJSONCoversStatus(t *testing.T) {
+	t.Parallel()
+
+	// A Status field without a JSON key would silently go missing.
+	require.Equal(t, reflect.TypeFor[Status]().NumField(), reflect.TypeFor[statusJSON]().NumField())
+	require.Equal(t, reflect.TypeFor[Info]().NumField(), reflect.TypeFor[infoJSON]().NumField())
+}
+
+func TestDetectJSON(t *testing.T) {
+	t.Parallel()
+
+	repo := initGitRepo(t)
+	commitFile(t, repo, "a.txt", "a")
+
+	out, err := DetectJSON(repo)
+	require.NoError(t, err)
+	var decoded struct {
+		Type     string `json:"type"`
+		RootPath string `json:"root_path"`
+		Status   struct {
+			CurrentBranch        string     `json:"current_branch"`
+			NothingToCommit      bool       `json:"nothing_to_commit"`
+			LastCommitCommitTime *time.Time `json:"last_commit_commit_time"`
+		} `json:"status"`
+	}
+	require.NoError(t, json.Unmarshal(out, &decoded))
+	require.Equal(t, "git", decoded.Type)
+	require.Equal(t, repo, decoded.RootPath)
+	require.NotEmpty(t, decoded.Status.CurrentBranch)
+	require.True(t, decoded.Status.NothingToCommit)
+	require.NotNil(t, decoded.Status.LastCommitCommitTime)
+
+	out, err = DetectJSON(t.TempDir())
+	require.NoError(t, err)
+	require.Contains(t, string(out), `"type":"none"`)
+}
@@ -0,0 +1,62 @@
+package vcs
+
+import "path/filepath"
+
+// Operation is a multi-step git operation that's been started but not yet
+// finished, usually because it stopped on a conflict.
+type Operation int
+
+const (
+	OpNone Operation = iota
+	OpMerge
+	OpRebase
+	OpCherryPick
+	OpBisect
+	OpRevert
+)
+
+// String returns the git command name of the operation, or an empty string
+// for OpNone.
+func (o Operation) String() string {
+	switch o {
+	case OpMerge:
+		return "merge"
+	case OpRebase:
+		return "rebase"
+	case OpCherryPick:
+		return "cherry-pick"
+	case OpBisect:
+		return "bisect"
+	case OpRevert:
+		return "revert"
+	default:
+		return ""
+	}
+}
+
+// operationMarkers lists the files git leaves in the git directory while
+// each operation is in progress. Order matters: an interactive rebase also
+// writes CHERRY_PICK_HEAD when a picked commit conflicts, and a bisect can
+// sit underneath any of the others, so it comes last.
+var operationMarkers = []struct {
+	op    Operation
+	files []string
+}{
+	{OpRebase, []string{"rebase-merge", "rebase-apply"}},
+	{OpMerge, []string{"MERGE_HEAD"}},
+	{OpCherryPick, []string{"CHERRY_PICK_HEAD"}},
+	{OpRevert, []string{"REVERT_HEAD"}},
+	{OpBisect, []string{"BISECT_LOG"}},
+}
+
+// gitOperation returns the operation in progress in gitDir.
+func gitOperation(gitDir string) Operation {
+	for _, marker := range operationMarkers {
+		for _, name := range marker.files {
+			if fileExists(filepath.Join(gitDir, name)) {
+				return marker.op
+			}
+		}
+	}
+	return OpNone
+}
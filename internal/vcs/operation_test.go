@@ -0,0 +1,59 @@
+package vcs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitOperation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		files []string
+		dirs  []string
+		want  Operation
+	}{
+		{name: "none", want: OpNone},
+		{name: "merge", files: []string{"MERGE_HEAD"}, want: OpMerge},
+		{name: "rebase merge backend", dirs: []string{"rebase-merge"}, want: OpRebase},
+		{name: "rebase apply backend", dirs: []string{"rebase-apply"}, want: OpRebase},
+		{name: "cherry-pick", files: []string{"CHERRY_PICK_HEAD"}, want: OpCherryPick},
+		{name: "revert", files: []string{"REVERT_HEAD"}, want: OpRevert},
+		{name: "bisect", files: []string{"BISECT_LOG"}, want: OpBisect},
+		{name: "conflicted pick during rebase", files: []string{"CHERRY_PICK_HEAD"}, dirs: []string{"rebase-merge"}, want: OpRebase},
+		{name: "merge during bisect", files: []string{"BISECT_LOG", "MERGE_HEAD"}, want: OpMerge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			gitDir := filepath.Join(t.TempDir(), ".git")
+			require.NoError(t, os.Mkdir(gitDir, 0o755))
+			for _, name := range tt.files {
+				require.NoError(t, os.WriteFile(filepath.Join(gitDir, name), nil, 0o644))
+			}
+			for _, name := range tt.dirs {
+				require.NoError(t, os.Mkdir(filepath.Join(gitDir, name), 0o755))
+			}
+			require.Equal(t, tt.want, gitOperation(gitDir))
+		})
+	}
+}
+
+func TestGitStatusOperation(t *testing.T) {
+	t.Parallel()
+
+	repo := initGitRepo(t)
+	commitFile(t, repo, "a.txt", "a")
+	head := runGit(t, repo, "rev-parse", "HEAD")
+	require.NoError(t, os.WriteFile(filepath.Join(repo, ".git", "MERGE_HEAD"), []byte(head), 0o644))
+
+	info, err := NewDetector().Detect(repo)
+	require.NoError(t, err)
+	require.Equal(t, OpMerge, info.Status.Operation)
+	require.Equal(t, "merge", info.Status.Operation.String())
+}
@@ -0,0 +1,202 @@
+package vcs
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+// porcelainStatus is what "git status --porcelain=v2 --branch" reports
+// about the branch and the working tree.
+type porcelainStatus struct {
+	oid  string // HEAD commit, or "(initial)" before the first commit
+	head string // Branch name, or "(detached)"
+
+	upstream       string // Configured upstream, e.g. "origin/main"; empty when there's none
+	hasAheadBehind bool   // The upstream exists; "# branch.ab" was present
+	ahead, behind  int    // Zero when "# branch.ab" didn't count them
+
+	staged     int // Changed entries with an index change
+	modified   int // Changed entries with a working tree change
+	conflicted int // Unmerged entries
+	untracked  int // Untracked entries
+
+	files     []FileStatus // At most MaxChangedFiles entries
+	truncated bool         // More files changed than files holds
+}
+
+// parsePorcelainV2 parses the NUL-separated output of
+// "git status --porcelain=v2 --branch -z". Unmerged entries are only
+// counted as conflicted, not also as staged or modified.
+func parsePorcelainV2(output string) porcelainStatus {
+	var ps porcelainStatus
+	records := strings.Split(output, "\x00")
+	for i := 0; i < len(records); i++ {
+		record := records[i]
+		if record == "" {
+			continue
+		}
+
+		var file FileStatus
+		switch record[0] {
+		case '#':
+			key, value, _ := strings.Cut(strings.TrimPrefix(record, "# "), " ")
+			switch key {
+			case "branch.oid":
+				ps.oid = value
+			case "branch.head":
+				ps.head = value
+			case "branch.upstream":
+				ps.upstream = value
+			case "branch.ab":
+				// "+<ahead> -<behind>", or "+? -?" with --no-ahead-behind.
+				ps.hasAheadBehind = true
+				ahead, behind, ok := strings.Cut(value, " ")
+				a, aErr := strconv.Atoi(strings.TrimPrefix(ahead, "+"))
+				b, bErr := strconv.Atoi(strings.TrimPrefix(behind, "-"))
+				if ok && aErr == nil && bErr == nil {
+					ps.ahead, ps.behind = a, b
+				}
+			}
+			continue
+		case '1', '2':
+			// "1 XY sub mH mI mW hH hI path", where X is the index status
+			// and Y the working tree status, "." meaning unchanged. Renames
+			// and copies ("2") add a score field, and their original path
+			// follows as its own record.
+			fields := 9
+			if record[0] == '2' {
+				fields = 10
+				i++
+			}
+			parts := strings.SplitN(record, " ", fields)
+			if len(parts) < fields || len(parts[1]) != 2 {
+				continue
+			}
+			file.Path = parts[fields-1]
+			if parts[1][0] != '.' {
+				ps.staged++
+				file.Code |= FileStaged
+			}
+			if parts[1][1] != '.' {
+				ps.modified++
+				file.Code |= FileUnstaged
+			}
+		case 'u':
+			// "u XY sub m1 m2 m3 mW h1 h2 h3 path"
+			ps.conflicted++
+			if parts := strings.SplitN(record, " ", 11); len(parts) == 11 {
+				file = FileStatus{Path: parts[10], Code: FileConflicted}
+			}
+		case '?':
+			ps.untracked++
+			file = FileStatus{Path: strings.TrimPrefix(record, "? "), Code: FileUntracked}
+		default:
+			continue
+		}
+
+		if file.Path == "" {
+			continue
+		}
+		if len(ps.files) == MaxChangedFiles {
+			ps.truncated = true
+			continue
+		}
+		ps.files = append(ps.files, file)
+	}
+	return ps
+}
+
+// apply copies the parsed branch and working tree state into status.
+func (ps porcelainStatus) apply(status *Status) {
+	switch ps.head {
+	case "(detached)":
+		status.IsDetached = true
+	default:
+		status.CurrentBranch = ps.head
+		status.IsUnborn = ps.oid == "(initial)"
+	}
+
+	status.RemoteTrackingOK = ps.hasAheadBehind
+	// git names a configured upstream but can't count against one that's
+	// gone.
+	status.UpstreamGone = ps.upstream != "" && !ps.hasAheadBehind
+	status.AheadCount, status.BehindCount = ps.ahead, ps.behind
+	status.HasUnpushed = ps.ahead > 0
+
+	status.ConflictCount = ps.conflicted
+	status.StagedCount = ps.staged
+	status.ModifiedCount = ps.modified
+	status.UntrackedCount = ps.untracked
+	status.HasConflicts = ps.conflicted > 0
+	status.HasStaged = ps.staged > 0
+	status.HasUncommitted = ps.modified > 0
+	status.HasUntracked = ps.untracked > 0
+
+	status.ChangedFiles = ps.files
+	status.ChangedFilesTruncated = ps.truncated
+}
+
+// porcelainV2MinVersion is the first git release that understands
+// "git status --porcelain=v2".
+var porcelainV2MinVersion = toolVersion{2, 11}
+
+// noAheadBehindMinVersion is the first git release that understands
+// "git status --no-ahead-behind".
+var noAheadBehindMinVersion = toolVersion{2, 17}
+
+// installedGitVersion caches the version of git on PATH.
+var installedGitVersion versionCache
+
+// gitVersion returns the major and minor version of the git that run runs
+// in dir, or false if it can't be determined.
+func gitVersion(ctx context.Context, run commandRunner, dir string) (toolVersion, bool) {
+	return installedGitVersion.get(ctx, run, func(ctx context.Context) (toolVersion, bool) {
+		output, err := run.Run(ctx, dir, "git", "--version")
+		if err != nil {
+			return toolVersion{}, false
+		}
+		major, minor, ok := parseGitVersion(string(output))
+		return toolVersion{major, minor}, ok
+	})
+}
+
+// gitAtLeast reports whether the git that run runs in dir is version
+// minVersion or later.
+func gitAtLeast(ctx context.Context, run commandRunner, dir string, minVersion toolVersion) bool {
+	v, ok := gitVersion(ctx, run, dir)
+	if !ok {
+		return false
+	}
+	return v[0] > minVersion[0] || v[0] == minVersion[0] && v[1] >= minVersion[1]
+}
+
+// gitSupportsPorcelainV2 reports whether the git that run runs in dir
+// understands "git status --porcelain=v2".
+func gitSupportsPorcelainV2(ctx context.Context, run commandRunner, dir string) bool {
+	return gitAtLeast(ctx, run, dir, porcelainV2MinVersion)
+}
+
+// parseGitVersion extracts the major and minor version from "git --version"
+// output such as "git version 2.39.5" or
+// "git version 2.24.3 (Apple Git-128)".
+func parseGitVersion(output string) (major, minor int, ok bool) {
+	rest, found := strings.CutPrefix(strings.TrimSpace(output), "git version ")
+	if !found {
+		return 0, 0, false
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return 0, 0, false
+	}
+	parts := strings.SplitN(fields[0], ".", 3)
+	if len(parts) < 2 {
+		return 0, 0, false
+	}
+	major, majorErr := strconv.Atoi(parts[0])
+	minor, minorErr := strconv.Atoi(parts[1])
+	if majorErr != nil || minorErr != nil {
+		return 0, 0, false
+	}
+	return major, minor, true
+}
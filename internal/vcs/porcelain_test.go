@@ -0,0 +1,164 @@
+package vcs
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePorcelainV2(t *testing.T) {
+	t.Parallel()
+
+	t.Run("branch with upstream and changes", func(t *testing.T) {
+		t.Parallel()
+		output := strings.Join([]string{
+			"# branch.oid 1111111111111111111111111111111111111111",
+			"# branch.head main",
+			"# branch.upstream origin/main",
+			"# branch.ab +12 -3",
+			"1 M. N... 100644 100644 100644 aaaa bbbb staged.go",
+			"1 .M N... 100644 100644 100644 aaaa aaaa dir/with space.go",
+			"1 MM N... 100644 100644 100644 aaaa bbbb both.go",
+			"2 R. N... 100644 100644 100644 aaaa aaaa R100 new.go", "old.go",
+			"u UU N... 100644 100644 100644 100644 aaaa bbbb cccc conflict.go",
+			"? notes.txt",
+		}, "\x00") + "\x00"
+		ps := parsePorcelainV2(output)
+		require.Equal(t, "main", ps.head)
+		require.True(t, ps.hasAheadBehind)
+		require.Equal(t, 12, ps.ahead)
+		require.Equal(t, 3, ps.behind)
+		require.Equal(t, 3, ps.staged)
+		require.Equal(t, 2, ps.modified)
+		require.Equal(t, 1, ps.conflicted)
+		require.Equal(t, 1, ps.untracked)
+		require.Equal(t, []FileStatus{
+			{Path: "staged.go", Code: FileStaged},
+			{Path: "dir/with space.go", Code: FileUnstaged},
+			{Path: "both.go", Code: FileStaged | FileUnstaged},
+			{Path: "new.go", Code: FileStaged},
+			{Path: "conflict.go", Code: FileConflicted},
+			{Path: "notes.txt", Code: FileUntracked},
+		}, ps.files)
+		require.False(t, ps.truncated)
+
+		var status Status
+		ps.apply(&status)
+		require.Equal(t, "main", status.CurrentBranch)
+		require.True(t, status.RemoteTrackingOK)
+		require.True(t, status.HasUnpushed)
+		require.True(t, status.HasConflicts)
+		require.True(t, status.HasStaged)
+		require.True(t, status.HasUncommitted)
+		require.True(t, status.HasUntracked)
+		require.Equal(t, 3, status.StagedCount)
+		require.Equal(t, 2, status.ModifiedCount)
+		require.Equal(t, 1, status.UntrackedCount)
+		require.Equal(t, 1, status.ConflictCount)
+		require.False(t, status.IsDetached)
+		require.False(t, status.IsUnborn)
+	})
+
+	t.Run("detached", func(t *testing.T) {
+		t.Parallel()
+		var status Status
+		parsePorcelainV2("# branch.oid 1111111111111111111111111111111111111111\x00# branch.head (detached)\x00").apply(&status)
+		require.True(t, status.IsDetached)
+		require.Empty(t, status.CurrentBranch)
+		require.False(t, status.RemoteTrackingOK)
+	})
+
+	t.Run("unborn", func(t *testing.T) {
+		t.Parallel()
+		var status Status
+		parsePorcelainV2("# branch.oid (initial)\x00# branch.head main\x00").apply(&status)
+		require.Equal(t, "main", status.CurrentBranch)
+		require.True(t, status.IsUnborn)
+	})
+
+	t.Run("upstream without counts", func(t *testing.T) {
+		t.Parallel()
+		var status Status
+		parsePorcelainV2("# branch.head main\x00# branch.upstream origin/main\x00# branch.ab +? -?\x00").apply(&status)
+		require.True(t, status.RemoteTrackingOK)
+		require.Zero(t, status.AheadCount)
+		require.Zero(t, status.BehindCount)
+	})
+
+	t.Run("upstream gone", func(t *testing.T) {
+		t.Parallel()
+		var status Status
+		parsePorcelainV2("# branch.head main\x00# branch.upstream origin/main\x00").apply(&status)
+		require.False(t, status.RemoteTrackingOK)
+		require.True(t, status.UpstreamGone)
+
+		status = Status{}
+		parsePorcelainV2("# branch.head main\x00").apply(&status)
+		require.False(t, status.UpstreamGone, "no upstream configured")
+	})
+
+	t.Run("flags follow counts", func(t *testing.T) {
+		t.Parallel()
+		var status Status
+		parsePorcelainV2("# branch.head main\x001 M. N... 100644 100644 100644 aaaa bbbb a.go\x00").apply(&status)
+		require.True(t, status.HasStaged)
+		require.Equal(t, 1, status.StagedCount)
+		require.False(t, status.HasUncommitted)
+		require.Zero(t, status.ModifiedCount)
+		require.False(t, status.HasUntracked)
+		require.Zero(t, status.UntrackedCount)
+	})
+}
+
+func TestParsePorcelainV2Truncates(t *testing.T) {
+	t.Parallel()
+
+	var b strings.Builder
+	for i := range MaxChangedFiles + 10 {
+		fmt.Fprintf(&b, "? file%d.txt\x00", i)
+	}
+	ps := parsePorcelainV2(b.String())
+	require.Len(t, ps.files, MaxChangedFiles)
+	require.True(t, ps.truncated)
+	require.Equal(t, "file0.txt", ps.files[0].Path)
+
+	var status Status
+	ps.apply(&status)
+	require.Len(t, status.ChangedFiles, MaxChangedFiles)
+	require.True(t, status.ChangedFilesTruncated)
+}
+
+func TestFileCodeString(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "staged", FileStaged.String())
+	require.Equal(t, "staged+unstaged", (FileStaged | FileUnstaged).String())
+	require.Equal(t, "conflicted", FileConflicted.String())
+	require.True(t, (FileStaged | FileUnstaged).Has(FileUnstaged))
+	require.False(t, FileStaged.Has(FileUnstaged))
+}
+
+func TestParseGitVersion(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		output       string
+		major, minor int
+		ok           bool
+	}{
+		{"git version 2.39.5\n", 2, 39, true},
+		{"git version 2.24.3 (Apple Git-128)\n", 2, 24, true},
+		{"git version 2.45.1.windows.1\n", 2, 45, true},
+		{"git version 1.8\n", 1, 8, true},
+		{"git version\n", 0, 0, false},
+		{"hub version 2.14.2\n", 0, 0, false},
+	}
+	for _, tt := range tests {
+		major, minor, ok := parseGitVersion(tt.output)
+		require.Equal(t, tt.ok, ok, tt.output)
+		require.Equal(t, tt.major, major, tt.output)
+		require.Equal(t, tt.minor, minor, tt.output)
+	}
+}
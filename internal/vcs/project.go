@@ -0,0 +1,59 @@
+package vcs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ProjectDetectTimeout bounds how long DetectProjects spends on a single
+// project. Slow repositories, such as ones on network filesystems, are
+// reported as TypeNone rather than holding up the rest of the set.
+const ProjectDetectTimeout = 5 * time.Second
+
+// DetectProjects detects the repository containing each of dirs, running at
+// most concurrency detections at a time. Results are in the same order as
+// dirs; directories that aren't in a repository, fail detection or time out
+// get an Info of TypeNone. A concurrency below 1 is treated as 1.
+func DetectProjects(dirs []string, concurrency int) []Info {
+	return detectProjects(dirs, concurrency, ProjectDetectTimeout, projectDetector.DetectContext)
+}
+
+// projectDetector caches project detections, so a launcher that calls
+// DetectProjects on every redraw only re-runs commands for projects that
+// changed.
+var projectDetector = NewCachingDetector(NewDetector(), 10*time.Second)
+
+func detectProjects(dirs []string, concurrency int, timeout time.Duration, detect func(context.Context, string) (Info, error)) []Info {
+	results := make([]Info, len(dirs))
+	concurrency = max(1, min(concurrency, len(dirs)))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range concurrency {
+		wg.Go(func() {
+			for i := range jobs {
+				results[i] = detectWithTimeout(dirs[i], timeout, detect)
+			}
+		})
+	}
+	for i := range dirs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// detectWithTimeout runs detect on dir, cancelling it after timeout.
+func detectWithTimeout(dir string, timeout time.Duration, detect func(context.Context, string) (Info, error)) Info {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	info, err := detect(ctx, dir)
+	if err != nil && !errors.Is(err, ErrVCSCommandFailed) {
+		return Info{Type: TypeNone}
+	}
+	// A failed command is recorded in info.Status.Failure.
+	return info
+}
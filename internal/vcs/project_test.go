@@ -0,0 +1,68 @@
+package vcs
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectProjects(t *testing.T) {
+	t.Parallel()
+
+	repos := []string{initGitRepo(t), initGitRepo(t), initGitRepo(t)}
+	plain := t.TempDir()
+	dirs := []string{repos[0], plain, repos[1], repos[2]}
+
+	got := DetectProjects(dirs, 2)
+	require.Len(t, got, len(dirs))
+	require.Equal(t, TypeGit, got[0].Type)
+	require.Equal(t, repos[0], got[0].RootPath)
+	require.Equal(t, TypeNone, got[1].Type)
+	require.Equal(t, repos[1], got[2].RootPath)
+	require.Equal(t, repos[2], got[3].RootPath)
+}
+
+func TestDetectProjectsBoundsConcurrency(t *testing.T) {
+	t.Parallel()
+
+	var running, peak atomic.Int32
+	var mu sync.Mutex
+	detect := func(_ context.Context, dir string) (Info, error) {
+		n := running.Add(1)
+		mu.Lock()
+		peak.Store(max(peak.Load(), n))
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		running.Add(-1)
+		return Info{Type: TypeGit, RootPath: dir}, nil
+	}
+
+	dirs := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	got := detectProjects(dirs, 3, time.Second, detect)
+	for i, dir := range dirs {
+		require.Equal(t, dir, got[i].RootPath)
+	}
+	require.LessOrEqual(t, peak.Load(), int32(3))
+	require.Greater(t, peak.Load(), int32(1))
+}
+
+func TestDetectProjectsTimeout(t *testing.T) {
+	t.Parallel()
+
+	detect := func(ctx context.Context, dir string) (Info, error) {
+		info := Info{Type: TypeGit, RootPath: dir}
+		if dir == "slow" {
+			<-ctx.Done()
+			return info, ctx.Err()
+		}
+		return info, nil
+	}
+
+	got := detectProjects([]string{"fast", "slow"}, 0, 50*time.Millisecond, detect)
+	require.Equal(t, "fast", got[0].RootPath)
+	require.Equal(t, TypeNone, got[1].Type)
+}
@@ -0,0 +1,192 @@
+package vcs
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// parseRemoteURL splits a git remote URL into its host and repository path
+// (e.g. "charmbracelet/crush"). It understands URLs with a scheme such as
+// https:// and ssh:// as well as scp-like "git@host:owner/repo.git".
+func parseRemoteURL(remote string) (host, repoPath string, err error) {
+	remote = strings.TrimSpace(remote)
+	if remote == "" {
+		return "", "", errors.New("empty remote URL")
+	}
+
+	if strings.Contains(remote, "://") {
+		u, err := url.Parse(remote)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid remote URL %q: %w", remote, err)
+		}
+		host, repoPath = u.Hostname(), u.Path
+	} else {
+		// scp-like syntax: [user@]host:path
+		userHost, path, ok := strings.Cut(remote, ":")
+		if !ok {
+			return "", "", fmt.Errorf("unrecognized remote URL %q", remote)
+		}
+		if i := strings.LastIndex(userHost, "@"); i >= 0 {
+			userHost = userHost[i+1:]
+		}
+		host, repoPath = userHost, path
+	}
+
+	repoPath = strings.TrimSuffix(strings.Trim(repoPath, "/"), ".git")
+	if host == "" || repoPath == "" {
+		return "", "", fmt.Errorf("unrecognized remote URL %q", remote)
+	}
+	return host, repoPath, nil
+}
+
+// repoNameFromRemote returns the repository name in a remote URL, e.g.
+// "crush" for "git@github.com:charmbracelet/crush.git", or "" if the URL
+// isn't recognized.
+func repoNameFromRemote(remote string) string {
+	_, repoPath, err := parseRemoteURL(remote)
+	if err != nil {
+		return ""
+	}
+	return path.Base(repoPath)
+}
+
+// BrowseURL returns a web URL for the current branch on the repository's
+// hosting service, suitable for opening in a browser. GitHub, GitLab and
+// Bitbucket are supported; other hosts return an error. It uses the fetch
+// URL, or RemoteURL for VCS types such as jj that don't set one.
+func BrowseURL(info Info) (string, error) {
+	remote := cmp.Or(info.FetchURL, info.RemoteURL)
+	if remote == "" {
+		return "", errors.New("repository has no remote URL")
+	}
+	branch := info.Status.CurrentBranch
+	if branch == "" || info.Status.IsDetached {
+		return "", errors.New("repository is not on a branch")
+	}
+
+	host, repoPath, err := parseRemoteURL(remote)
+	if err != nil {
+		return "", err
+	}
+
+	segments := strings.Split(branch, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	ref := strings.Join(segments, "/")
+
+	switch host {
+	case "github.com":
+		return fmt.Sprintf("https://%s/%s/tree/%s", host, repoPath, ref), nil
+	case "gitlab.com":
+		return fmt.Sprintf("https://%s/%s/-/tree/%s", host, repoPath, ref), nil
+	case "bitbucket.org":
+		return fmt.Sprintf("https://%s/%s/src/%s", host, repoPath, ref), nil
+	default:
+		return "", fmt.Errorf("unsupported remote host %q", host)
+	}
+}
+
+// RemoteURLs holds the fetch and push URLs of a single git remote.
+type RemoteURLs struct {
+	Fetch string
+	Push  string
+}
+
+// Remotes returns every remote configured in the repository at repoPath,
+// keyed by name, as reported by "git remote -v".
+func Remotes(repoPath string) (map[string]RemoteURLs, error) {
+	output, err := gitOutput(context.Background(), osRunner{}, repoPath, "remote", "-v")
+	if err != nil {
+		return nil, fmt.Errorf("listing remotes: %w", err)
+	}
+	return parseRemotes(output), nil
+}
+
+// parseRemotes parses "git remote -v" output, where each line has the form
+// "<name>\t<url> (fetch)" or "<name>\t<url> (push)". The fetch line of a
+// partial clone also ends with its filter, e.g. "[blob:none]", which is
+// ignored.
+func parseRemotes(output string) map[string]RemoteURLs {
+	remotes := make(map[string]RemoteURLs)
+	for line := range strings.Lines(output) {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		name, remoteURL, kind := fields[0], fields[1], fields[2]
+		urls := remotes[name]
+		switch kind {
+		case "(fetch)":
+			urls.Fetch = remoteURL
+		case "(push)":
+			urls.Push = remoteURL
+		default:
+			continue
+		}
+		remotes[name] = urls
+	}
+	return remotes
+}
+
+// AheadBehind compares HEAD with a remote-tracking branch.
+type AheadBehind struct {
+	Ref    string // Remote-tracking branch compared against, e.g. "upstream/main"
+	Ahead  int    // Commits in HEAD that Ref doesn't have
+	Behind int    // Commits in Ref that HEAD doesn't have
+}
+
+// DetectWithRemotes detects the repository at or above path like Detect and,
+// for git, also compares HEAD with each of the named remotes. A remote is
+// compared through its branch of the same name as the current one, or else
+// through its default branch (<remote>/HEAD). The result is keyed by remote
+// name and leaves out remotes with neither. Info.Status still holds the
+// comparison with the branch's own upstream.
+func DetectWithRemotes(path string, remotes []string) (Info, map[string]AheadBehind, error) {
+	info, err := NewDetector().Detect(path)
+	if err != nil || info.Type != TypeGit {
+		return info, nil, err
+	}
+	return info, remoteAheadBehind(context.Background(), osRunner{}, info.RootPath, info.Status.CurrentBranch, remotes), nil
+}
+
+// remoteAheadBehind compares HEAD with a branch of each of remotes, as
+// described on DetectWithRemotes.
+func remoteAheadBehind(ctx context.Context, run commandRunner, repoPath, branch string, remotes []string) map[string]AheadBehind {
+	result := make(map[string]AheadBehind)
+	for _, remote := range remotes {
+		ref, ok := remoteCompareRef(ctx, run, repoPath, remote, branch)
+		if !ok {
+			continue
+		}
+		output, err := gitOutput(ctx, run, repoPath, "rev-list", "--left-right", "--count", "HEAD..."+ref)
+		if err != nil {
+			continue
+		}
+		ahead, behind := parseAheadBehind(output)
+		result[remote] = AheadBehind{Ref: ref, Ahead: ahead, Behind: behind}
+	}
+	return result
+}
+
+// remoteCompareRef returns the remote-tracking branch of remote to compare
+// HEAD with: <remote>/<branch> if it exists, or else the branch the remote's
+// HEAD points at.
+func remoteCompareRef(ctx context.Context, run commandRunner, repoPath, remote, branch string) (string, bool) {
+	if branch != "" {
+		ref := remote + "/" + branch
+		if _, err := gitOutput(ctx, run, repoPath, "rev-parse", "--verify", "--quiet", "refs/remotes/"+ref); err == nil {
+			return ref, true
+		}
+	}
+	ref, err := gitOutput(ctx, run, repoPath, "symbolic-ref", "--short", "refs/remotes/"+remote+"/HEAD")
+	if err != nil || ref == "" {
+		return "", false
+	}
+	return ref, true
+}
@@ -0,0 +1,228 @@
+package vcs
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBrowseURL(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		remote string
+		branch string
+		want   string
+	}{
+		{
+			name:   "github ssh",
+			remote: "git@github.com:charmbracelet/crush.git",
+			branch: "main",
+			want:   "https://github.com/charmbracelet/crush/tree/main",
+		},
+		{
+			name:   "github https",
+			remote: "https://github.com/charmbracelet/crush.git",
+			branch: "feature/vcs-badges",
+			want:   "https://github.com/charmbracelet/crush/tree/feature/vcs-badges",
+		},
+		{
+			name:   "github ssh scheme",
+			remote: "ssh://git@github.com/charmbracelet/crush",
+			branch: "main",
+			want:   "https://github.com/charmbracelet/crush/tree/main",
+		},
+		{
+			name:   "gitlab",
+			remote: "git@gitlab.com:group/sub/project.git",
+			branch: "main",
+			want:   "https://gitlab.com/group/sub/project/-/tree/main",
+		},
+		{
+			name:   "bitbucket",
+			remote: "https://bitbucket.org/team/repo.git",
+			branch: "dev",
+			want:   "https://bitbucket.org/team/repo/src/dev",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			info := Info{FetchURL: tt.remote, Status: Status{CurrentBranch: tt.branch}}
+			got, err := BrowseURL(info)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("unknown host", func(t *testing.T) {
+		t.Parallel()
+		info := Info{
+			FetchURL: "git@git.example.com:team/repo.git",
+			Status:   Status{CurrentBranch: "main"},
+		}
+		_, err := BrowseURL(info)
+		require.ErrorContains(t, err, "unsupported remote host")
+	})
+
+	t.Run("jj remote", func(t *testing.T) {
+		t.Parallel()
+		info := Info{
+			Type:      TypeJujutsu,
+			RemoteURL: "git@github.com:charmbracelet/crush.git",
+			Status:    Status{CurrentBranch: "main"},
+		}
+		got, err := BrowseURL(info)
+		require.NoError(t, err)
+		require.Equal(t, "https://github.com/charmbracelet/crush/tree/main", got)
+	})
+
+	t.Run("no remote", func(t *testing.T) {
+		t.Parallel()
+		_, err := BrowseURL(Info{Status: Status{CurrentBranch: "main"}})
+		require.Error(t, err)
+	})
+}
+
+func TestRemotes(t *testing.T) {
+	t.Parallel()
+
+	repo := initGitRepo(t)
+	runGit(t, repo, "remote", "add", "origin", "https://github.com/me/crush.git")
+	runGit(t, repo, "remote", "set-url", "--push", "origin", "git@github.com:me/crush.git")
+	runGit(t, repo, "remote", "add", "upstream", "https://github.com/charmbracelet/crush.git")
+	runGit(t, repo, "remote", "set-url", "--push", "upstream", "no_push")
+
+	remotes, err := Remotes(repo)
+	require.NoError(t, err)
+	require.Equal(t, map[string]RemoteURLs{
+		"origin": {
+			Fetch: "https://github.com/me/crush.git",
+			Push:  "git@github.com:me/crush.git",
+		},
+		"upstream": {
+			Fetch: "https://github.com/charmbracelet/crush.git",
+			Push:  "no_push",
+		},
+	}, remotes)
+}
+
+func TestRemotesPartialClone(t *testing.T) {
+	t.Parallel()
+
+	repo := initGitRepo(t)
+	runGit(t, repo, "remote", "add", "origin", "https://github.com/charmbracelet/crush.git")
+	runGit(t, repo, "config", "remote.origin.promisor", "true")
+	runGit(t, repo, "config", "remote.origin.partialclonefilter", "blob:none")
+
+	remotes, err := Remotes(repo)
+	require.NoError(t, err)
+	require.Equal(t, map[string]RemoteURLs{
+		"origin": {
+			Fetch: "https://github.com/charmbracelet/crush.git",
+			Push:  "https://github.com/charmbracelet/crush.git",
+		},
+	}, remotes)
+
+	// The filter follows the kind on the fetch line.
+	require.Equal(t, map[string]RemoteURLs{
+		"origin": {Fetch: "https://example.com/x.git", Push: "https://example.com/x.git"},
+	}, parseRemotes("origin\thttps://example.com/x.git (fetch) [blob:none]\norigin\thttps://example.com/x.git (push)\n"))
+}
+
+func TestRemotesNone(t *testing.T) {
+	t.Parallel()
+
+	remotes, err := Remotes(initGitRepo(t))
+	require.NoError(t, err)
+	require.Empty(t, remotes)
+}
+
+func TestRepoNameFromRemote(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		remote string
+		want   string
+	}{
+		{remote: "git@github.com:charmbracelet/crush.git", want: "crush"},
+		{remote: "https://github.com/charmbracelet/crush.git", want: "crush"},
+		{remote: "https://github.com/charmbracelet/crush/", want: "crush"},
+		{remote: "ssh://git@github.com:22/charmbracelet/crush.git", want: "crush"},
+		{remote: "gitlab.com:group/sub/project", want: "project"},
+		{remote: "", want: ""},
+		{remote: "not a remote", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.remote, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, repoNameFromRemote(tt.remote))
+		})
+	}
+}
+
+func TestExtractRepoName(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "crush", extractRepoName("/tmp/build-42", "git@github.com:charmbracelet/crush.git"))
+	require.Equal(t, "build-42", extractRepoName("/tmp/build-42", ""))
+}
+
+func TestParseJujutsuRemoteList(t *testing.T) {
+	t.Parallel()
+
+	output := "origin git@github.com:charmbracelet/crush.git\nupstream https://example.com/crush\n"
+	require.Equal(t, map[string]string{
+		"origin":   "git@github.com:charmbracelet/crush.git",
+		"upstream": "https://example.com/crush",
+	}, parseJujutsuRemoteList(output))
+}
+
+func TestDetectWithRemotes(t *testing.T) {
+	t.Parallel()
+
+	repo := initGitRepo(t)
+	commitFile(t, repo, "a.txt", "a")
+	base := strings.TrimSpace(runGit(t, repo, "rev-parse", "HEAD"))
+	commitFile(t, repo, "b.txt", "b")
+	upstreamOnly := strings.TrimSpace(runGit(t, repo, "rev-parse", "HEAD"))
+	runGit(t, repo, "reset", "-q", "--hard", base)
+	commitFile(t, repo, "c.txt", "c")
+	branch := strings.TrimSpace(runGit(t, repo, "symbolic-ref", "--short", "HEAD"))
+
+	runGit(t, repo, "remote", "add", "origin", "https://github.com/me/crush.git")
+	runGit(t, repo, "remote", "add", "upstream", "https://github.com/charmbracelet/crush.git")
+	runGit(t, repo, "remote", "add", "mirror", "https://example.com/crush.git")
+	runGit(t, repo, "update-ref", "refs/remotes/origin/"+branch, base)
+	runGit(t, repo, "update-ref", "refs/remotes/upstream/"+branch, upstreamOnly)
+	// The mirror has no branch of the same name, only a default branch.
+	runGit(t, repo, "update-ref", "refs/remotes/mirror/trunk", base)
+	runGit(t, repo, "symbolic-ref", "refs/remotes/mirror/HEAD", "refs/remotes/mirror/trunk")
+
+	info, sync, err := DetectWithRemotes(repo, []string{"origin", "upstream", "mirror", "missing"})
+	require.NoError(t, err)
+	require.Equal(t, TypeGit, info.Type)
+	require.Equal(t, map[string]AheadBehind{
+		"origin":   {Ref: "origin/" + branch, Ahead: 1, Behind: 0},
+		"upstream": {Ref: "upstream/" + branch, Ahead: 1, Behind: 1},
+		"mirror":   {Ref: "mirror/trunk", Ahead: 1, Behind: 0},
+	}, sync)
+
+	// No upstream is configured, so the default status has nothing to
+	// compare against.
+	require.False(t, info.Status.RemoteTrackingOK)
+	require.Zero(t, info.Status.AheadCount)
+}
+
+func TestDetectWithRemotesNotGit(t *testing.T) {
+	t.Parallel()
+
+	info, sync, err := DetectWithRemotes(t.TempDir(), []string{"origin"})
+	require.NoError(t, err)
+	require.Equal(t, TypeNone, info.Type)
+	require.Nil(t, sync)
+}
@@ -0,0 +1,76 @@
+package vcs
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"sync"
+)
+
+// commandRunner runs the VCS commands behind status detection. Detectors
+// take one so tests and sandboxed environments can stand in for real git
+// and jj processes.
+type commandRunner interface {
+	// Run runs name with args in dir and returns its standard output. A
+	// non-zero exit is reported as an error, as with exec.Cmd.Output.
+	Run(ctx context.Context, dir, name string, args ...string) ([]byte, error)
+}
+
+// osRunner runs commands as processes found on PATH, in the C locale:
+// commandFailure tells failures apart by their English error messages, which
+// a translated git wouldn't print.
+type osRunner struct{}
+
+func (osRunner) Run(ctx context.Context, dir, name string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "LC_ALL=C", "LANGUAGE=C")
+	return cmd.Output()
+}
+
+// runnerOr returns run, or an osRunner if run is nil, so detectors built as
+// zero values run real commands.
+func runnerOr(run commandRunner) commandRunner {
+	if run == nil {
+		return osRunner{}
+	}
+	return run
+}
+
+// runnerHas reports whether run can run the named command. Only an osRunner
+// can be missing one; other runners are assumed to handle every command.
+func runnerHas(run commandRunner, name string) bool {
+	if _, ok := run.(osRunner); ok {
+		return toolAvailable(name)
+	}
+	return true
+}
+
+// toolVersion is the major and minor version of a VCS command.
+type toolVersion = [2]int
+
+// versionCache remembers the version of a command found on PATH, which
+// can't change while the process runs. A lookup cut short by its context
+// isn't remembered.
+type versionCache struct {
+	mu      sync.Mutex
+	done    bool
+	version toolVersion
+	ok      bool
+}
+
+// get returns the cached version, or looks it up with lookup when run is an
+// osRunner. Other runners stand in for different commands, so they're asked
+// every time.
+func (c *versionCache) get(ctx context.Context, run commandRunner, lookup func(context.Context) (toolVersion, bool)) (toolVersion, bool) {
+	if _, ok := run.(osRunner); !ok {
+		return lookup(ctx)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.done {
+		c.version, c.ok = lookup(ctx)
+		c.done = ctx.Err() == nil
+	}
+	return c.version, c.ok
+}
@@ -0,0 +1,213 @@
+package vcs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRunner answers commands with canned output keyed by the command line,
+// e.g. "git --no-optional-locks stash list". Commands in failures exit 128
+// with the given error output; unknown commands fail to start, except the
+// version queries, which get fakeVersions unless outputs has them.
+type fakeRunner struct {
+	outputs  map[string]string
+	failures map[string]string
+
+	mu    sync.Mutex
+	calls []string // "<dir>: <command line>" for each call
+}
+
+func (f *fakeRunner) Run(_ context.Context, dir, name string, args ...string) ([]byte, error) {
+	line := strings.Join(append([]string{name}, args...), " ")
+	f.mu.Lock()
+	f.calls = append(f.calls, dir+": "+line)
+	f.mu.Unlock()
+	if output, ok := f.outputs[line]; ok {
+		return []byte(output), nil
+	}
+	if output, ok := fakeVersions[line]; ok {
+		return []byte(output), nil
+	}
+	if stderr, ok := f.failures[line]; ok {
+		return nil, &exec.ExitError{Stderr: []byte(stderr)}
+	}
+	return nil, errors.New("unexpected command: " + line)
+}
+
+// fakeGitRepo creates a directory that looks like a git repository on disk,
+// with HEAD holding head, without running git.
+func fakeGitRepo(t *testing.T, head string) string {
+	t.Helper()
+	repo := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(repo, ".git", "hooks"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(repo, ".git", "HEAD"), []byte(head+"\n"), 0o644))
+	return repo
+}
+
+// fakeVersions are the versions fakeRunner reports by default: releases
+// with every feature detection relies on.
+var fakeVersions = map[string]string{
+	"git --version": "git version 2.43.0\n",
+	"jj --version":  "jj 0.28.2\n",
+}
+
+const fakePorcelainCmd = "git --no-optional-locks status --porcelain=v2 --branch -z"
+
+func TestGitDetectorFakeRunner(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ahead, behind and conflicted", func(t *testing.T) {
+		t.Parallel()
+		repo := fakeGitRepo(t, "ref: refs/heads/main")
+		run := &fakeRunner{outputs: map[string]string{
+			fakePorcelainCmd: strings.Join([]string{
+				"# branch.oid 1111111111111111111111111111111111111111",
+				"# branch.head main",
+				"# branch.upstream origin/main",
+				"# branch.ab +2 -5",
+				"u UU N... 100644 100644 100644 100644 aaaa bbbb cccc a.go",
+				"u AA N... 000000 100644 100644 100644 0000 bbbb cccc b.go",
+			}, "\x00") + "\x00",
+			"git --no-optional-locks stash list": "stash@{0}: On main: wip\n",
+		}}
+
+		info, err := (&gitDetector{run: run}).Detect(repo)
+		require.NoError(t, err)
+		require.Equal(t, TypeGit, info.Type)
+		require.Equal(t, "main", info.Status.CurrentBranch)
+		require.True(t, info.Status.RemoteTrackingOK)
+		require.Equal(t, 2, info.Status.AheadCount)
+		require.Equal(t, 5, info.Status.BehindCount)
+		require.True(t, info.Status.HasConflicts)
+		require.Equal(t, 2, info.Status.ConflictCount)
+		require.Equal(t, 1, info.Status.StashCount)
+		require.Equal(t, "wip", info.Status.TopStashDescription)
+		for _, call := range run.calls {
+			require.True(t, strings.HasPrefix(call, repo+": "), call)
+		}
+	})
+
+	t.Run("detached at a tag", func(t *testing.T) {
+		t.Parallel()
+		const head = "2222222222222222222222222222222222222222"
+		repo := fakeGitRepo(t, head)
+		run := &fakeRunner{outputs: map[string]string{
+			fakePorcelainCmd: "# branch.oid " + head + "\x00# branch.head (detached)\x00",
+			"git --no-optional-locks rev-parse --short HEAD":             "2222222\n",
+			"git --no-optional-locks describe --tags --exact-match HEAD": "v1.2.3\n",
+		}}
+
+		info, err := (&gitDetector{run: run}).Detect(repo)
+		require.NoError(t, err)
+		require.True(t, info.Status.IsDetached)
+		require.Empty(t, info.Status.CurrentBranch)
+		require.Equal(t, "v1.2.3", info.Status.DetachedRef)
+	})
+}
+
+func TestGitDetectorFakeRunnerFailure(t *testing.T) {
+	t.Parallel()
+
+	repo := fakeGitRepo(t, "ref: refs/heads/main")
+	run := &fakeRunner{failures: map[string]string{
+		fakePorcelainCmd: "error: index file smaller than expected\nfatal: index file corrupt\n",
+	}}
+
+	info, err := (&gitDetector{run: run}).Detect(repo)
+	require.ErrorIs(t, err, ErrVCSCommandFailed)
+	require.ErrorIs(t, err, ErrRepoCorrupt)
+	require.ErrorContains(t, err, "index file corrupt")
+	require.Equal(t, err, info.Status.Failure)
+
+	// What was learned before the failure is still reported.
+	require.Equal(t, TypeGit, info.Type)
+	require.Equal(t, repo, info.RootPath)
+	require.Equal(t, "main", info.Status.CurrentBranch)
+}
+
+func TestJujutsuDetectorFakeRunner(t *testing.T) {
+	t.Parallel()
+
+	repo := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(repo, ".jj"), 0o755))
+	run := &fakeRunner{outputs: map[string]string{
+		"jj --color=never log -r @ --no-graph -T bookmarks":                                             "feature\n",
+		"jj --color=never status":                                                                       "Working copy changes:\nM a.go\n",
+		"jj --color=never log -r @ --no-graph -T conflict ++ \" \" ++ divergent":                        "true false",
+		"jj --color=never log -r trunk() --no-graph -T root":                                            "false",
+		"jj --color=never log -r trunk()..@ ~ (@ & empty()) --no-graph -T change_id.short() ++ \"\\n\"": "aaaa\nbbbb\n",
+		"jj --color=never log -r @..trunk() --no-graph -T change_id.short() ++ \"\\n\"":                 "cccc\n",
+	}}
+
+	info, err := (&jujutsuDetector{run: run}).Detect(repo)
+	require.NoError(t, err)
+	require.Equal(t, TypeJujutsu, info.Type)
+	require.Equal(t, "feature", info.Status.CurrentBranch)
+	require.True(t, info.Status.HasUncommitted)
+	require.True(t, info.Status.HasConflicts)
+	require.False(t, info.Status.IsDivergent)
+	require.Equal(t, 2, info.Status.AheadCount)
+	require.Equal(t, 1, info.Status.BehindCount)
+}
+
+func TestGitDetectorFakeRunnerOldGit(t *testing.T) {
+	t.Parallel()
+
+	// The fake runner's git is too old for porcelain v2, whatever git is
+	// installed, so detection takes the legacy path.
+	repo := fakeGitRepo(t, "ref: refs/heads/main")
+	run := &fakeRunner{outputs: map[string]string{
+		"git --version": "git version 2.10.0\n",
+	}}
+
+	_, _ = (&gitDetector{run: run}).Detect(repo)
+	require.Contains(t, run.calls, repo+": git --version")
+	require.NotContains(t, run.calls, repo+": "+fakePorcelainCmd)
+}
+
+func TestVersionCache(t *testing.T) {
+	t.Parallel()
+
+	lookups := 0
+	lookup := func(ctx context.Context) (toolVersion, bool) {
+		lookups++
+		if ctx.Err() != nil {
+			return toolVersion{}, false
+		}
+		return toolVersion{2, 43}, true
+	}
+
+	t.Run("remembers the version of the command on PATH", func(t *testing.T) {
+		var cache versionCache
+		lookups = 0
+
+		cancelled, cancel := context.WithCancel(t.Context())
+		cancel()
+		_, ok := cache.get(cancelled, osRunner{}, lookup)
+		require.False(t, ok, "a cancelled lookup isn't remembered")
+
+		for range 2 {
+			v, ok := cache.get(t.Context(), osRunner{}, lookup)
+			require.True(t, ok)
+			require.Equal(t, toolVersion{2, 43}, v)
+		}
+		require.Equal(t, 2, lookups)
+	})
+
+	t.Run("asks other runners every time", func(t *testing.T) {
+		var cache versionCache
+		lookups = 0
+		for range 2 {
+			_, _ = cache.get(t.Context(), &fakeRunner{}, lookup)
+		}
+		require.Equal(t, 2, lookups)
+	})
+}
@@ -0,0 +1,69 @@
+package vcs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// SubmoduleState is the sync state of a submodule, as marked by the first
+// column of "git submodule status".
+type SubmoduleState byte
+
+const (
+	SubmoduleInSync        SubmoduleState = ' ' // Checked out at the recorded commit
+	SubmoduleUninitialized SubmoduleState = '-' // Not initialized
+	SubmoduleOutOfSync     SubmoduleState = '+' // Checked out commit differs from the recorded one
+	SubmoduleConflict      SubmoduleState = 'U' // Merge conflicts
+)
+
+// SubmoduleInfo describes one submodule of a repository.
+type SubmoduleInfo struct {
+	Path  string // Path relative to the superproject root
+	SHA   string // Checked out commit, or the recorded one when uninitialized
+	Ref   string // "git describe" of SHA, e.g. "heads/main"; empty when unknown
+	State SubmoduleState
+}
+
+// Submodules lists the submodules of the repository at repoPath, including
+// nested ones.
+func Submodules(repoPath string) ([]SubmoduleInfo, error) {
+	// Not gitOutput: trimming would drop the state column of the first line.
+	output, err := gitRun(context.Background(), osRunner{}, repoPath, "submodule", "status", "--recursive")
+	if err != nil {
+		return nil, fmt.Errorf("listing submodules: %w", err)
+	}
+	return parseSubmoduleStatus(string(output)), nil
+}
+
+// parseSubmoduleStatus parses "git submodule status" output, where each line
+// is a state character followed by "<sha> <path>" and an optional
+// " (<describe>)".
+func parseSubmoduleStatus(output string) []SubmoduleInfo {
+	var submodules []SubmoduleInfo
+	for line := range strings.Lines(output) {
+		line = strings.TrimRight(line, "\n")
+		if len(line) < 2 {
+			continue
+		}
+		state := SubmoduleState(line[0])
+		switch state {
+		case SubmoduleInSync, SubmoduleUninitialized, SubmoduleOutOfSync, SubmoduleConflict:
+		default:
+			continue
+		}
+
+		sha, rest, ok := strings.Cut(line[1:], " ")
+		if !ok {
+			continue
+		}
+		path, ref := rest, ""
+		if strings.HasSuffix(rest, ")") {
+			if i := strings.LastIndex(rest, " ("); i >= 0 {
+				path, ref = rest[:i], rest[i+2:len(rest)-1]
+			}
+		}
+		submodules = append(submodules, SubmoduleInfo{Path: path, SHA: sha, Ref: ref, State: state})
+	}
+	return submodules
+}
@@ -0,0 +1,65 @@
+package vcs
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSubmoduleStatus(t *testing.T) {
+	t.Parallel()
+
+	output := " 1111111111111111111111111111111111111111 libs/core (heads/main)\n" +
+		"-2222222222222222222222222222222222222222 libs/unused\n" +
+		"+3333333333333333333333333333333333333333 vendor/with space (v1.2.0-3-g3333333)\n" +
+		"U4444444444444444444444444444444444444444 libs/conflicted\n"
+
+	require.Equal(t, []SubmoduleInfo{
+		{Path: "libs/core", SHA: "1111111111111111111111111111111111111111", Ref: "heads/main", State: SubmoduleInSync},
+		{Path: "libs/unused", SHA: "2222222222222222222222222222222222222222", State: SubmoduleUninitialized},
+		{Path: "vendor/with space", SHA: "3333333333333333333333333333333333333333", Ref: "v1.2.0-3-g3333333", State: SubmoduleOutOfSync},
+		{Path: "libs/conflicted", SHA: "4444444444444444444444444444444444444444", State: SubmoduleConflict},
+	}, parseSubmoduleStatus(output))
+
+	require.Empty(t, parseSubmoduleStatus(""))
+}
+
+func TestSubmodules(t *testing.T) {
+	t.Parallel()
+
+	lib := initGitRepo(t)
+	commitFile(t, lib, "lib.go", "package lib")
+
+	repo := initGitRepo(t)
+	commitFile(t, repo, "README.md", "hello")
+	runGit(t, repo, "-c", "protocol.file.allow=always", "submodule", "add", lib, "lib")
+	runGit(t, repo, "commit", "-m", "Add lib submodule")
+
+	submodules, err := Submodules(repo)
+	require.NoError(t, err)
+	require.Len(t, submodules, 1)
+	require.Equal(t, "lib", submodules[0].Path)
+	require.Equal(t, SubmoduleInSync, submodules[0].State)
+	recorded := strings.TrimSpace(runGit(t, lib, "rev-parse", "HEAD"))
+	require.Equal(t, recorded, submodules[0].SHA)
+
+	// A new commit checked out in the submodule but not recorded in the
+	// superproject leaves it out of sync.
+	commitFile(t, filepath.Join(repo, "lib"), "more.go", "package lib")
+
+	submodules, err = Submodules(repo)
+	require.NoError(t, err)
+	require.Len(t, submodules, 1)
+	require.Equal(t, SubmoduleOutOfSync, submodules[0].State)
+	require.NotEqual(t, recorded, submodules[0].SHA)
+}
+
+func TestSubmodulesNone(t *testing.T) {
+	t.Parallel()
+
+	submodules, err := Submodules(initGitRepo(t))
+	require.NoError(t, err)
+	require.Empty(t, submodules)
+}
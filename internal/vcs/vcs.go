@@ -3,10 +3,19 @@
 package vcs
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 // Type represents the type of version control system.
@@ -17,30 +26,274 @@ const (
 	TypeGit Type = "git"
 	// TypeJujutsu represents a Jujutsu repository.
 	TypeJujutsu Type = "jj"
+	// TypeMercurial represents a Mercurial repository.
+	TypeMercurial Type = "hg"
+	// TypeSubversion represents a Subversion working copy.
+	TypeSubversion Type = "svn"
+	// TypeFossil represents a Fossil checkout.
+	TypeFossil Type = "fossil"
 	// TypeNone represents no VCS detected.
 	TypeNone Type = ""
 )
 
+// ErrUnknownType is returned, wrapped, by ParseType for a name that isn't a
+// known VCS.
+var ErrUnknownType = errors.New("unknown VCS type")
+
+// String returns the display name of the VCS, such as "Git" or "Jujutsu",
+// or "none" for TypeNone. Types registered by other detectors are returned
+// as is.
+func (t Type) String() string {
+	switch t {
+	case TypeGit:
+		return "Git"
+	case TypeJujutsu:
+		return "Jujutsu"
+	case TypeMercurial:
+		return "Mercurial"
+	case TypeSubversion:
+		return "Subversion"
+	case TypeFossil:
+		return "Fossil"
+	case TypeNone:
+		return "none"
+	default:
+		return string(t)
+	}
+}
+
+// ParseType parses a VCS name from config or the command line. It accepts
+// the command name ("jj") or the display name ("Jujutsu") in any case, and
+// "none" for TypeNone.
+func ParseType(s string) (Type, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	if name == "none" {
+		return TypeNone, nil
+	}
+	for _, t := range []Type{TypeGit, TypeJujutsu, TypeMercurial, TypeSubversion, TypeFossil} {
+		if name == string(t) || name == strings.ToLower(t.String()) {
+			return t, nil
+		}
+	}
+	return TypeNone, fmt.Errorf("%w: %q", ErrUnknownType, s)
+}
+
 // Status represents the current state of a VCS repository.
 type Status struct {
-	HasUncommitted   bool // Uncommitted changes (modified/added/deleted files)
-	HasUntracked     bool // Untracked files
-	HasConflicts     bool // Merge conflicts
-	HasStaged        bool // Staged changes ready to commit
-	AheadCount       int  // Commits ahead of remote
-	BehindCount      int  // Commits behind remote
-	CurrentBranch    string
-	IsDetached       bool // Detached HEAD state
-	HasUnpushed      bool // Has commits not pushed to remote
-	RemoteTrackingOK bool // Remote tracking branch exists and is accessible
+	HasUncommitted      bool   // Uncommitted changes (modified/added/deleted files)
+	HasUntracked        bool   // Untracked files
+	HasConflicts        bool   // Merge conflicts
+	HasStaged           bool   // Staged changes ready to commit
+	AheadCount          int    // Commits ahead of remote
+	BehindCount         int    // Commits behind remote
+	CurrentBranch       string // Checked out branch; empty when detached
+	IsDetached          bool   // Detached HEAD state
+	DetachedRef         string // What HEAD points at when detached (tag, tag description, short hash or "PR #N")
+	HasUnpushed         bool   // Has commits not pushed to remote
+	RemoteTrackingOK    bool   // Remote tracking branch exists and is accessible
+	UpstreamGone        bool   // An upstream is configured but its remote-tracking branch no longer exists, e.g. after it was deleted on the remote
+	HasAlternates       bool   // Objects are borrowed from another store via alternates
+	HasCommitGraph      bool   // A commit-graph file speeds up history walks
+	IsShallow           bool   // Shallow clone with truncated history, so AheadCount and BehindCount can't be trusted
+	AtReleasedTag       bool   // HEAD is exactly at a tag that also exists on the remote
+	Locked              bool   // Another git process holds the index lock; working tree status was skipped
+	StashCount          int    // Number of stash entries
+	TopStashDescription string // Message of the most recent stash entry; empty when there's none
+	HasHooks            bool   // Executable hooks may run on commit/push
+	OnProtectedBranch   bool   // CurrentBranch matches DetectOptions.ProtectedBranches
+	BranchDescription   string // Note set with "git branch --edit-description"; empty when unset
+	NothingToCommit     bool   // Clean git tree with nothing staged, or an empty jj working-copy change
+	AuthorCount         int    // Distinct authors on the branch since the default branch; needs DetectOptions.CountAuthors
+	IsUnborn            bool   // CurrentBranch has no commits yet, as in a freshly initialized repository
+	IsBare              bool   // Bare git repository: RootPath is the git directory and there's no working tree
+
+	// Git file counts behind HasStaged, HasUncommitted and HasUntracked.
+	// The flags stay set whenever the counts are non-zero. "git status
+	// --porcelain=v2" reports every file, so the counts are always filled
+	// in; older git only fills in the staged and modified counts when
+	// DetectOptions.CountFiles is set.
+	StagedCount    int
+	ModifiedCount  int
+	UntrackedCount int
+
+	// ConflictCount is the number of unmerged paths, so for git HasConflicts
+	// == (ConflictCount > 0). Other VCS types only set HasConflicts.
+	ConflictCount int
+
+	// Author and committer times of HEAD. They differ for commits that were
+	// rebased, amended or cherry-picked.
+	LastCommitAuthorTime time.Time
+	LastCommitCommitTime time.Time
+
+	// LastFetchTime is when FETCH_HEAD was last written, i.e. the last fetch
+	// or pull; zero when the repository was never fetched. FetchStale reports
+	// that it's older than DetectOptions.FetchStaleAfter.
+	LastFetchTime time.Time
+	FetchStale    bool
+
+	// ChangedFiles lists the changed files, capped at MaxChangedFiles with
+	// ChangedFilesTruncated set when there were more (git). It's only
+	// filled in by git releases with "git status --porcelain=v2".
+	ChangedFiles          []FileStatus
+	ChangedFilesTruncated bool
+
+	// Operation is the merge, rebase or other multi-step operation that's
+	// in progress (git).
+	Operation Operation
+
+	// HasDivergentChanges reports that some jj change id resolves to more
+	// than one visible commit, usually after concurrent edits.
+	HasDivergentChanges bool
+
+	// IsDivergent reports that the jj working-copy change itself is one of
+	// those divergent changes.
+	IsDivergent bool
+
+	// Failure is the error a core command such as "git status" failed with,
+	// wrapping ErrVCSCommandFailed (and ErrRepoCorrupt for a damaged
+	// repository). Detection returns it too, along with the partial Info.
+	// Status fields other than the branch can't be trusted when it's set.
+	Failure error
+
+	// ToolUnavailable reports that the repository was found but its command
+	// (git or jj) isn't on PATH, so nothing beyond what can be read from
+	// disk, such as the git branch, is known.
+	ToolUnavailable bool
+
+	// ParentDescription is the first line of the parent change's description
+	// (jj). The working-copy change is often empty while the real work lives
+	// in its parent, so this gives the status area something meaningful.
+	ParentDescription string
+}
+
+// Diff describes which fields differ between s and other, one entry per
+// field such as "AheadCount 2→3" or "HasUncommitted false→true". It's meant
+// for logging why a refresh produced a different status.
+func (s Status) Diff(other Status) []string {
+	var changes []string
+	before, after := reflect.ValueOf(s), reflect.ValueOf(other)
+	for i := range before.NumField() {
+		a, b := before.Field(i).Interface(), after.Field(i).Interface()
+		if reflect.DeepEqual(a, b) {
+			continue
+		}
+		format := "%s %v→%v"
+		if before.Field(i).Kind() == reflect.String {
+			format = "%s %q→%q"
+		}
+		changes = append(changes, fmt.Sprintf(format, before.Type().Field(i).Name, a, b))
+	}
+	return changes
+}
+
+// Traffic light glyphs returned by Status.TrafficLight, from most to least
+// severe.
+const (
+	TrafficLightRed    rune = '✖' // Conflicts need resolving, or the status couldn't be read
+	TrafficLightYellow rune = '●' // Working tree has changes, or wasn't checked
+	TrafficLightGreen  rune = '✓' // Nothing to do
+)
+
+// TrafficLight collapses the status into a single glyph for very constrained
+// displays: red for conflicts or a failed status read, yellow for any local
+// changes or a working tree that wasn't checked (Locked or ToolUnavailable)
+// and green otherwise.
+func (s Status) TrafficLight() rune {
+	switch {
+	case s.HasConflicts || s.Failure != nil:
+		return TrafficLightRed
+	case s.Locked || s.ToolUnavailable:
+		return TrafficLightYellow
+	case s.HasUncommitted || s.HasStaged || s.HasUntracked:
+		return TrafficLightYellow
+	default:
+		return TrafficLightGreen
+	}
+}
+
+// IsClean reports whether everything is committed and in sync with the
+// upstream: no staged, modified, untracked or conflicted files, HEAD on a
+// branch, and neither ahead nor behind. A status whose working tree wasn't
+// read (Locked, Failure or ToolUnavailable) is never clean, and neither is a
+// branch whose upstream is gone.
+func (s Status) IsClean() bool {
+	switch {
+	case s.Locked || s.Failure != nil || s.ToolUnavailable:
+		return false
+	case s.HasStaged || s.HasUncommitted || s.HasUntracked || s.HasConflicts:
+		return false
+	case s.IsDetached || s.UpstreamGone:
+		return false
+	default:
+		return s.AheadCount == 0 && s.BehindCount == 0 && !s.HasUnpushed
+	}
+}
+
+// Summary describes the status in a few words, such as "3 staged, 1
+// untracked, ↑2". Counts are left out when only the flag is known, as in
+// "modified". It's "clean" when IsClean and "unknown" when the working tree
+// wasn't read.
+func (s Status) Summary() string {
+	if s.Locked || s.Failure != nil || s.ToolUnavailable {
+		return "unknown"
+	}
+	var parts []string
+	add := func(set bool, count int, word string) {
+		switch {
+		case count > 0:
+			parts = append(parts, fmt.Sprintf("%d %s", count, word))
+		case set:
+			parts = append(parts, word)
+		}
+	}
+	add(s.HasConflicts, s.ConflictCount, "conflicted")
+	add(s.HasStaged, s.StagedCount, "staged")
+	add(s.HasUncommitted, s.ModifiedCount, "modified")
+	add(s.HasUntracked, s.UntrackedCount, "untracked")
+	if s.IsDetached {
+		parts = append(parts, "detached")
+	}
+	if s.UpstreamGone {
+		parts = append(parts, "upstream gone")
+	}
+	if s.AheadCount > 0 {
+		parts = append(parts, fmt.Sprintf("↑%d", s.AheadCount))
+	}
+	if s.BehindCount > 0 {
+		parts = append(parts, fmt.Sprintf("↓%d", s.BehindCount))
+	}
+	if len(parts) == 0 {
+		return "clean"
+	}
+	return strings.Join(parts, ", ")
 }
 
 // Info contains information about a VCS repository.
 type Info struct {
-	Type     Type
-	RepoName string
-	RootPath string
-	Status   Status
+	Type           Type
+	RepoName       string
+	RootPath       string
+	LinkedWorktree bool   // RootPath is a worktree added with "git worktree add"
+	Submodule      bool   // RootPath is a submodule checked out inside another repository
+	IsColocated    bool   // RootPath holds both a .git and a .jj, as "jj git init --colocate" sets up
+	RemoteURL      string // URL of the default remote; RepoName is derived from it when set
+	FetchURL       string // URL the default remote fetches from
+	PushURL        string // URL the default remote pushes to; matches FetchURL unless overridden
+	MergeTool      string // Configured merge.tool; empty when unset or DetectOptions.ReadToolConfig is off
+	Editor         string // Configured core.editor; empty when unset or DetectOptions.ReadToolConfig is off
+	Status         Status
+
+	// LastCommit describes the commit HEAD points at (git). It's nil unless
+	// DetectOptions.ReadLastCommit is set, and on an unborn branch.
+	LastCommit *CommitInfo
+}
+
+// CommitInfo describes a commit for display.
+type CommitInfo struct {
+	ShortSHA   string    // Abbreviated commit hash, as "git log --format=%h" prints it
+	Subject    string    // First line of the commit message
+	AuthorName string    // Author name
+	When       time.Time // Author date
 }
 
 // Detector is an interface for detecting VCS repositories.
@@ -48,59 +301,455 @@ type Detector interface {
 	// Detect checks if a VCS repository exists at or above the given path.
 	// Returns Info with Type set to TypeNone if no repository is found.
 	Detect(path string) (Info, error)
+
+	// DetectContext is like Detect, but stops running VCS commands once ctx
+	// is done. It then returns whatever Info was gathered so far along with
+	// ctx.Err().
+	DetectContext(ctx context.Context, path string) (Info, error)
+}
+
+// DetectOptions enables optional, more expensive status checks. The zero
+// value performs only the default local checks.
+type DetectOptions struct {
+	// CheckReleasedTag queries the remote's tags to determine whether HEAD
+	// is at a published release. This requires network access.
+	CheckReleasedTag bool
+
+	// RefreshIndex runs "git update-index --refresh" before checking for
+	// changes, so files whose mtime changed but content didn't aren't
+	// reported as dirty. It's opt-in because it writes the index, which
+	// can contend with git commands the user runs at the same time.
+	RefreshIndex bool
+
+	// CountFiles fills in the StagedCount and ModifiedCount fields on git
+	// releases without "git status --porcelain=v2", by listing changed files
+	// instead of stopping at the first one.
+	CountFiles bool
+
+	// CountAuthors fills in AuthorCount by summarizing the authors of the
+	// commits between the default branch and HEAD, which walks history.
+	CountAuthors bool
+
+	// ProtectedBranches lists branch names, optionally with path.Match
+	// wildcards, that shouldn't be committed to directly. Nil means
+	// DefaultProtectedBranches.
+	ProtectedBranches []string
+
+	// FetchStaleAfter is how old the last fetch may get before
+	// Status.FetchStale is set. Zero means DefaultFetchStaleAfter.
+	FetchStaleAfter time.Duration
+
+	// BranchOnly makes git detection read the branch straight from HEAD,
+	// only running git when HEAD can't be interpreted, for displays that
+	// redraw often and only need Type, RepoName, RootPath and
+	// CurrentBranch. Every other Status field, including IsUnborn, is left
+	// zero, and RepoName comes from the directory since the remote isn't
+	// read. Other VCS types ignore it.
+	BranchOnly bool
+
+	// SkipAheadBehind leaves AheadCount and BehindCount zero instead of
+	// walking history to compare the branch with its upstream.
+	// RemoteTrackingOK is still set.
+	SkipAheadBehind bool
+
+	// SkipUntracked doesn't look for untracked files, which means walking
+	// the whole working tree. HasUntracked stays false and no longer keeps
+	// NothingToCommit from being set.
+	SkipUntracked bool
+
+	// SkipStash leaves StashCount and TopStashDescription empty.
+	SkipStash bool
+
+	// Prefer picks the VCS reported for colocated repositories, whose root
+	// holds both a .git and a .jj. The zero value keeps Git.
+	Prefer Type
+
+	// ReadLastCommit fills in Info.LastCommit with one more "git log"
+	// call. BranchOnly detection skips it.
+	ReadLastCommit bool
+
+	// ReadToolConfig fills in Info.MergeTool and Info.Editor from the git
+	// config, which takes one more "git config" call for each. BranchOnly
+	// detection skips it.
+	ReadToolConfig bool
+
+	// SearchAboveHome lets the search for a repository root continue past
+	// the user's home directory, as git itself does. By default it stops
+	// there, like the directories in GIT_CEILING_DIRECTORIES, so a stray
+	// .git far above the project isn't picked up.
+	SearchAboveHome bool
+}
+
+// DefaultFetchStaleAfter is the fetch age past which a repository's view of
+// its remote is considered stale.
+const DefaultFetchStaleAfter = 24 * time.Hour
+
+// DefaultProtectedBranches are the branches conventionally protected from
+// direct commits.
+var DefaultProtectedBranches = []string{"main", "master", "release/*"}
+
+// isProtectedBranch reports whether branch matches one of patterns.
+func isProtectedBranch(branch string, patterns []string) bool {
+	if branch == "" {
+		return false
+	}
+	for _, pattern := range patterns {
+		if ok, err := path.Match(pattern, branch); err == nil && ok {
+			return true
+		}
+	}
+	return false
 }
 
 // detector implements Detector by checking for multiple VCS types.
 type detector struct {
 	detectors []Detector
+	byType    map[Type]Detector
+	prefer    Type
 }
 
 // NewDetector creates a new Detector that checks for multiple VCS types
-// in priority order (Git, then Jujutsu).
+// in priority order (Git, then Jujutsu, Mercurial, Subversion and Fossil).
 func NewDetector() Detector {
-	return &detector{
-		detectors: []Detector{
-			&gitDetector{},
-			&jujutsuDetector{},
-		},
+	return NewDetectorWithOptions(DetectOptions{})
+}
+
+// NewDetectorWithOptions creates a new Detector like NewDetector, enabling
+// the optional checks selected in opts.
+func NewDetectorWithOptions(opts DetectOptions) Detector {
+	d := newDetector([]Detector{
+		NewGitDetector(opts),
+		&jujutsuDetector{searchAboveHome: opts.SearchAboveHome},
+		&mercurialDetector{searchAboveHome: opts.SearchAboveHome},
+		&subversionDetector{searchAboveHome: opts.SearchAboveHome},
+		&fossilDetector{searchAboveHome: opts.SearchAboveHome},
+	})
+	d.prefer = opts.Prefer
+	return d
+}
+
+// NewDetectorWith creates a Detector that tries detectors in the given
+// order and returns the first match. Embedders can use it to add detectors
+// for other VCS types alongside the built-in ones, or to change priority:
+//
+//	vcs.NewDetectorWith(pijulDetector{}, vcs.NewGitDetector(vcs.DetectOptions{}))
+func NewDetectorWith(detectors ...Detector) Detector {
+	return newDetector(detectors)
+}
+
+// newDetector wraps detectors, remembering the built-in ones that can be
+// colocated.
+func newDetector(detectors []Detector) *detector {
+	d := &detector{detectors: detectors, byType: make(map[Type]Detector)}
+	for _, det := range detectors {
+		switch det.(type) {
+		case *gitDetector:
+			d.byType[TypeGit] = det
+		case *jujutsuDetector:
+			d.byType[TypeJujutsu] = det
+		}
 	}
+	return d
+}
+
+// NewGitDetector returns the built-in Git detector, for use with
+// NewDetectorWith.
+func NewGitDetector(opts DetectOptions) Detector {
+	return &gitDetector{opts: opts}
+}
+
+// NewJujutsuDetector returns the built-in Jujutsu detector, for use with
+// NewDetectorWith.
+func NewJujutsuDetector() Detector {
+	return &jujutsuDetector{}
+}
+
+// NewMercurialDetector returns the built-in Mercurial detector, for use with
+// NewDetectorWith.
+func NewMercurialDetector() Detector {
+	return &mercurialDetector{}
+}
+
+// NewSubversionDetector returns the built-in Subversion detector, for use
+// with NewDetectorWith.
+func NewSubversionDetector() Detector {
+	return &subversionDetector{}
+}
+
+// NewFossilDetector returns the built-in Fossil detector, for use with
+// NewDetectorWith.
+func NewFossilDetector() Detector {
+	return &fossilDetector{}
+}
+
+// DetectWithOptions detects the repository at or above path like
+// NewDetectorWithOptions(opts).Detect(path).
+func DetectWithOptions(path string, opts DetectOptions) (Info, error) {
+	return NewDetectorWithOptions(opts).Detect(path)
+}
+
+// NewDetectorWithPreference creates a new Detector like NewDetector that
+// reports colocated repositories as prefer, e.g. TypeJujutsu for repositories
+// driven with jj.
+func NewDetectorWithPreference(prefer Type) Detector {
+	return NewDetectorWithOptions(DetectOptions{Prefer: prefer})
 }
 
 // Detect tries each VCS detector in order and returns the first match.
 func (d *detector) Detect(path string) (Info, error) {
+	return d.DetectContext(context.Background(), path)
+}
+
+// DetectContext is like Detect, but honors ctx as described on Detector.
+func (d *detector) DetectContext(ctx context.Context, path string) (Info, error) {
 	for _, det := range d.detectors {
-		info, err := det.Detect(path)
+		info, err := det.DetectContext(ctx, path)
 		if err != nil {
-			return Info{}, err
+			return info, err
 		}
 		if info.Type != TypeNone {
-			return info, nil
+			return d.resolveColocated(ctx, info)
 		}
 	}
 	return Info{Type: TypeNone}, nil
 }
 
-// findVCSRoot walks up the directory tree looking for a VCS marker directory.
-// For Git, it also accepts .git as a file (worktrees and submodules).
-func findVCSRoot(startPath, markerDir string) (string, bool) {
+// colocatedWith maps each VCS type to the one that can share its working
+// tree root.
+var colocatedWith = map[Type]Type{
+	TypeGit:     TypeJujutsu,
+	TypeJujutsu: TypeGit,
+}
+
+// resolveColocated marks info as colocated when its root also holds the
+// marker of the VCS it can share a root with, and detects that VCS instead
+// when it's the preferred one. Only markers at the same root count, so a git
+// checkout nested in a jj repository is still reported as git.
+func (d *detector) resolveColocated(ctx context.Context, info Info) (Info, error) {
+	other, ok := colocatedWith[info.Type]
+	if !ok || !hasVCSMarker(info.RootPath, vcsMarkers[other]) {
+		return info, nil
+	}
+	if det, ok := d.byType[other]; ok && d.prefer == other {
+		preferred, err := det.DetectContext(ctx, info.RootPath)
+		if err != nil {
+			return preferred, err
+		}
+		if preferred.Type == other && preferred.RootPath == info.RootPath {
+			info = preferred
+		}
+	}
+	info.IsColocated = true
+	return info, nil
+}
+
+// vcsMarkers maps each VCS type to the marker that identifies its root.
+var vcsMarkers = map[Type]string{
+	TypeGit:        ".git",
+	TypeJujutsu:    ".jj",
+	TypeMercurial:  ".hg",
+	TypeSubversion: ".svn",
+}
+
+// DetectOutermost detects the repository at or above path like Detect, but
+// keeps walking up past the first match to return the outermost repository
+// of the same type. This yields the monorepo root rather than a nested
+// repository or submodule inside it.
+func DetectOutermost(path string) (Info, error) {
+	return DetectOutermostWithOptions(path, DetectOptions{})
+}
+
+// DetectOutermostWithOptions is like DetectOutermost, but detects with opts
+// and searches above the home directory when opts.SearchAboveHome is set.
+func DetectOutermostWithOptions(path string, opts DetectOptions) (Info, error) {
+	d := NewDetectorWithOptions(opts)
+	info, err := d.Detect(path)
+	if err != nil || info.Type == TypeNone {
+		return info, err
+	}
+
+	marker, ok := vcsMarkers[info.Type]
+	if !ok {
+		return info, nil
+	}
+	root := info.RootPath
+	for {
+		parent := filepath.Dir(root)
+		if parent == root {
+			break
+		}
+		outer, found := findVCSRoot(parent, marker, ceilingDirs(opts.SearchAboveHome))
+		if !found {
+			break
+		}
+		root = outer
+	}
+	if root == info.RootPath {
+		return info, nil
+	}
+	return d.Detect(root)
+}
+
+// GitDir returns the working tree root of the git repository at or above
+// path and its git directory, following the .git file of worktrees and
+// submodules. It reports false if path isn't inside a git repository. Unlike
+// Detect it runs no git commands.
+func GitDir(path string) (root, gitDir string, ok bool) {
+	return GitDirWithOptions(path, DetectOptions{})
+}
+
+// GitDirWithOptions is like GitDir, but searches above the home directory
+// when opts.SearchAboveHome is set.
+func GitDirWithOptions(path string, opts DetectOptions) (root, gitDir string, ok bool) {
+	root, found := findVCSRoot(path, ".git", ceilingDirs(opts.SearchAboveHome))
+	if !found {
+		return "", "", false
+	}
+	return root, resolveGitDir(root), true
+}
+
+// FindRoot returns the nearest directory at or above path that contains
+// marker, a file or directory such as ".pijul", stopping at the same
+// ceilings as the built-in detectors: GIT_CEILING_DIRECTORIES and, unless
+// opts.SearchAboveHome is set, the home directory. Detectors for other VCS
+// types can use it to find their root the way the built-in ones do.
+func FindRoot(path, marker string, opts DetectOptions) (string, bool) {
+	return findVCSRoot(path, marker, ceilingDirs(opts.SearchAboveHome))
+}
+
+// CommitTemplate returns the commit message template that "git commit" in
+// the repository at root starts from, as set with commit.template in the
+// repository's own config, or an empty string when none is. A relative
+// path is resolved against root. Global and system templates are left out
+// because other repositories share them.
+func CommitTemplate(root string) string {
+	path, err := gitOutput(context.Background(), osRunner{}, root, "config", "--local", "--path", "--get", "commit.template")
+	if err != nil || path == "" {
+		return ""
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	return path
+}
+
+// hasVCSMarker reports whether dir contains markerDir. For .git, both
+// directories and files (worktrees/submodules) count; other VCS markers must
+// be directories.
+func hasVCSMarker(dir, markerDir string) bool {
+	info, err := os.Stat(filepath.Join(dir, markerDir))
+	if err != nil {
+		return markerDir == ".git" && isBareGitDir(dir)
+	}
+	return info.IsDir() || markerDir == ".git"
+}
+
+// isBareGitDir reports whether dir is the git directory of a bare
+// repository: it has HEAD, objects and refs like any git directory, and its
+// config sets core.bare. The config check keeps the git directories of
+// submodules under .git/modules from matching.
+func isBareGitDir(dir string) bool {
+	if !fileExists(filepath.Join(dir, "HEAD")) ||
+		!fileExists(filepath.Join(dir, "objects")) ||
+		!fileExists(filepath.Join(dir, "refs")) {
+		return false
+	}
+	config, err := os.ReadFile(filepath.Join(dir, "config"))
+	if err != nil {
+		return false
+	}
+	for line := range strings.Lines(string(config)) {
+		if strings.Join(strings.Fields(strings.ToLower(line)), "") == "bare=true" {
+			return true
+		}
+	}
+	return false
+}
+
+// RepoRootFor returns the root and type of the nearest repository enclosing
+// path, which may be a file or a directory. When one directory holds
+// markers for several VCS types, Git wins, as in Detect. Unlike Detect it
+// runs no VCS commands.
+func RepoRootFor(path string) (string, Type, bool) {
+	return RepoRootForWithOptions(path, DetectOptions{})
+}
+
+// RepoRootForWithOptions is like RepoRootFor, but searches above the home
+// directory when opts.SearchAboveHome is set.
+func RepoRootForWithOptions(path string, opts DetectOptions) (string, Type, bool) {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return "", TypeNone, false
+	}
+	if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+
+	ceilings := ceilingDirs(opts.SearchAboveHome)
+	for {
+		for _, typ := range []Type{TypeGit, TypeJujutsu, TypeMercurial, TypeSubversion} {
+			if hasVCSMarker(dir, vcsMarkers[typ]) {
+				if typ == TypeSubversion {
+					dir = topmostSubversionRoot(dir, ceilings)
+				}
+				return dir, typ, true
+			}
+		}
+		if _, ok := fossilCheckoutFile(dir); ok {
+			return dir, TypeFossil, true
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir || slices.Contains(ceilings, parent) {
+			return "", TypeNone, false
+		}
+		dir = parent
+	}
+}
+
+// ceilingDirs returns the directories that searches for a repository root
+// don't climb into: those listed in GIT_CEILING_DIRECTORIES, which git
+// honors too, and the user's home directory unless searchAboveHome is set.
+// As with git, the starting directory is still searched when it's one of
+// them.
+func ceilingDirs(searchAboveHome bool) []string {
+	var dirs []string
+	for _, dir := range filepath.SplitList(os.Getenv("GIT_CEILING_DIRECTORIES")) {
+		// Git ignores relative entries, and an empty one only changes how
+		// git resolves symlinks in the rest.
+		if filepath.IsAbs(dir) {
+			dirs = append(dirs, filepath.Clean(dir))
+		}
+	}
+	if !searchAboveHome {
+		if home, err := os.UserHomeDir(); err == nil && filepath.IsAbs(home) {
+			dirs = append(dirs, filepath.Clean(home))
+		}
+	}
+	return dirs
+}
+
+// findVCSRoot walks up the directory tree looking for a VCS marker directory,
+// stopping below any of ceilings (see ceilingDirs). For Git, it also accepts
+// .git as a file (worktrees and submodules). For Subversion, it returns the
+// topmost directory of the working copy.
+func findVCSRoot(startPath, markerDir string, ceilings []string) (string, bool) {
 	path, err := filepath.Abs(startPath)
 	if err != nil {
 		return "", false
 	}
 
 	for {
-		vcsPath := filepath.Join(path, markerDir)
-		if info, err := os.Stat(vcsPath); err == nil {
-			// For .git, accept both directories and files (worktrees/submodules).
-			// For other VCS markers, require directories.
-			if info.IsDir() || markerDir == ".git" {
-				return path, true
+		if hasVCSMarker(path, markerDir) {
+			if markerDir == ".svn" {
+				path = topmostSubversionRoot(path, ceilings)
 			}
+			return path, true
 		}
 
 		parent := filepath.Dir(path)
-		if parent == path {
-			// Reached the root directory.
+		if parent == path || slices.Contains(ceilings, parent) {
+			// Reached the root or a ceiling directory.
 			break
 		}
 		path = parent
@@ -109,155 +758,917 @@ func findVCSRoot(startPath, markerDir string) (string, bool) {
 	return "", false
 }
 
-// extractRepoName extracts a repository name from the root path.
-// For example, /path/to/myrepo becomes "myrepo".
-func extractRepoName(rootPath string) string {
+// findVCSRootFile walks up the directory tree like findVCSRoot, but looks for
+// a regular file named after any of markerFiles instead of a directory.
+func findVCSRootFile(startPath string, ceilings []string, markerFiles ...string) (string, bool) {
+	path, err := filepath.Abs(startPath)
+	if err != nil {
+		return "", false
+	}
+
+	for {
+		for _, marker := range markerFiles {
+			if info, err := os.Stat(filepath.Join(path, marker)); err == nil && info.Mode().IsRegular() {
+				return path, true
+			}
+		}
+
+		parent := filepath.Dir(path)
+		if parent == path || slices.Contains(ceilings, parent) {
+			return "", false
+		}
+		path = parent
+	}
+}
+
+// topmostSubversionRoot climbs from dir, which holds a .svn directory, to
+// the highest ancestor that still has one, stopping below any of ceilings.
+// Subversion clients before 1.7 put .svn in every directory of a working
+// copy, not just at its root.
+func topmostSubversionRoot(dir string, ceilings []string) string {
+	for {
+		parent := filepath.Dir(dir)
+		if parent == dir || slices.Contains(ceilings, parent) || !hasVCSMarker(parent, ".svn") {
+			return dir
+		}
+		dir = parent
+	}
+}
+
+// extractRepoName extracts a repository name, preferring the one in the
+// remote URL so that a checkout into e.g. /tmp/build-42 is still named after
+// its project. Without a usable remote, /path/to/myrepo becomes "myrepo".
+func extractRepoName(rootPath, remoteURL string) string {
+	if name := repoNameFromRemote(remoteURL); name != "" {
+		return name
+	}
 	return filepath.Base(rootPath)
 }
 
 // gitDetector detects Git repositories.
-type gitDetector struct{}
+type gitDetector struct {
+	opts DetectOptions
+	run  commandRunner // Nil runs git from PATH
+}
 
 // Detect checks for a .git directory.
 func (g *gitDetector) Detect(path string) (Info, error) {
-	rootPath, found := findVCSRoot(path, ".git")
+	return g.DetectContext(context.Background(), path)
+}
+
+// DetectContext is like Detect, but honors ctx as described on Detector.
+func (g *gitDetector) DetectContext(ctx context.Context, path string) (Info, error) {
+	rootPath, found := findVCSRoot(path, ".git", ceilingDirs(g.opts.SearchAboveHome))
 	if !found {
 		return Info{Type: TypeNone}, nil
 	}
 
-	// Check if .git is a file (submodule) or directory.
+	// Linked worktrees and submodules have a .git file pointing at their
+	// git directory instead of a .git directory. The directory holding that
+	// file is still the top of their working tree, so rootPath stands; the
+	// git directory tells the two apart.
 	gitPath := filepath.Join(rootPath, ".git")
-	info, err := os.Stat(gitPath)
-	if err != nil {
+	gitInfo, err := os.Stat(gitPath)
+	bare := err != nil && isBareGitDir(rootPath)
+	if err != nil && !bare {
 		return Info{Type: TypeNone}, nil
 	}
-
-	// Handle git worktrees and submodules (where .git is a file).
-	if !info.IsDir() {
-		// Read the .git file to find the actual git directory.
-		content, err := os.ReadFile(gitPath)
-		if err == nil {
-			// The file contains something like "gitdir: /path/to/actual/.git"
-			line := strings.TrimSpace(string(content))
-			if strings.HasPrefix(line, "gitdir: ") {
-				// We still use the current directory as the repo root.
-				// The .git file indicates this is a valid git working tree.
-			}
-		}
+	var linkedWorktree, submodule bool
+	if !bare && !gitInfo.IsDir() {
+		linkedWorktree, submodule = gitDirKind(resolveGitDir(rootPath))
 	}
 
-	status := getGitStatus(rootPath)
-
-	return Info{
-		Type:     TypeGit,
-		RepoName: extractRepoName(rootPath),
-		RootPath: rootPath,
-		Status:   status,
-	}, nil
-}
+	run := runnerOr(g.run)
+	if !runnerHas(run, "git") {
+		status := readGitHead(ctx, run, rootPath, resolveGitDir(rootPath))
+		status.IsBare = bare
+		status.ToolUnavailable = true
+		return Info{
+			Type:           TypeGit,
+			RepoName:       extractRepoName(rootPath, ""),
+			RootPath:       rootPath,
+			LinkedWorktree: linkedWorktree,
+			Submodule:      submodule,
+			Status:         status,
+		}, ctx.Err()
+	}
 
-// getGitStatus retrieves the current status of a Git repository.
-func getGitStatus(repoPath string) Status {
-	status := Status{}
+	if g.opts.BranchOnly {
+		status := readGitHead(ctx, run, rootPath, resolveGitDir(rootPath))
+		status.IsBare = bare
+		return Info{
+			Type:           TypeGit,
+			RepoName:       extractRepoName(rootPath, ""),
+			RootPath:       rootPath,
+			LinkedWorktree: linkedWorktree,
+			Submodule:      submodule,
+			Status:         status,
+		}, ctx.Err()
+	}
 
-	// Get current branch and detached HEAD state.
-	cmd := exec.Command("git", "symbolic-ref", "--short", "HEAD")
-	cmd.Dir = repoPath
-	if output, err := cmd.Output(); err == nil {
-		status.CurrentBranch = strings.TrimSpace(string(output))
-	} else {
-		// Check if we're in detached HEAD.
-		cmd = exec.Command("git", "rev-parse", "--short", "HEAD")
-		cmd.Dir = repoPath
-		if output, err := cmd.Output(); err == nil {
-			status.CurrentBranch = strings.TrimSpace(string(output))
-			status.IsDetached = true
-		}
+	status := getGitStatus(ctx, run, rootPath, g.opts)
+	protected := g.opts.ProtectedBranches
+	if protected == nil {
+		protected = DefaultProtectedBranches
 	}
+	status.OnProtectedBranch = isProtectedBranch(status.CurrentBranch, protected)
+	if g.opts.CheckReleasedTag {
+		status.AtReleasedTag = isAtReleasedTag(ctx, run, rootPath, defaultRemote)
+	}
+	if g.opts.CountAuthors {
+		status.AuthorCount = countBranchAuthors(ctx, run, rootPath)
+	}
+	fetchURL, pushURL := getGitRemoteURLs(ctx, run, rootPath, defaultRemote)
 
-	// Check for conflicts.
-	cmd = exec.Command("git", "diff", "--name-only", "--diff-filter=U")
-	cmd.Dir = repoPath
-	if output, err := cmd.Output(); err == nil && len(strings.TrimSpace(string(output))) > 0 {
-		status.HasConflicts = true
+	var lastCommit *CommitInfo
+	if g.opts.ReadLastCommit && !status.IsUnborn {
+		lastCommit = getGitLastCommit(ctx, run, rootPath)
+	}
+	var mergeTool, editor string
+	if g.opts.ReadToolConfig {
+		mergeTool = gitConfigValue(ctx, run, rootPath, "merge.tool")
+		editor = gitConfigValue(ctx, run, rootPath, "core.editor")
 	}
 
-	// Check for staged changes.
-	cmd = exec.Command("git", "diff", "--cached", "--quiet")
-	cmd.Dir = repoPath
-	if err := cmd.Run(); err != nil {
-		// Non-zero exit means there are staged changes.
-		status.HasStaged = true
+	info := Info{
+		Type:           TypeGit,
+		RepoName:       extractRepoName(rootPath, fetchURL),
+		RootPath:       rootPath,
+		LinkedWorktree: linkedWorktree,
+		Submodule:      submodule,
+		RemoteURL:      fetchURL,
+		FetchURL:       fetchURL,
+		PushURL:        pushURL,
+		MergeTool:      mergeTool,
+		Editor:         editor,
+		Status:         status,
+		LastCommit:     lastCommit,
+	}
+	if status.Failure != nil {
+		return info, status.Failure
 	}
+	return info, ctx.Err()
+}
 
-	// Check for uncommitted changes.
-	cmd = exec.Command("git", "diff", "--quiet")
-	cmd.Dir = repoPath
-	if err := cmd.Run(); err != nil {
-		// Non-zero exit means there are uncommitted changes.
-		status.HasUncommitted = true
+// defaultRemote is the remote consulted for repository URLs.
+const defaultRemote = "origin"
+
+// toolLookups remembers toolAvailable results, keyed by command name and
+// PATH.
+var toolLookups sync.Map
+
+// toolAvailable reports whether the named command is on PATH. The lookup
+// runs once per command and PATH value.
+func toolAvailable(name string) bool {
+	key := name + "\x00" + os.Getenv("PATH")
+	if available, ok := toolLookups.Load(key); ok {
+		return available.(bool)
 	}
+	_, err := exec.LookPath(name)
+	toolLookups.Store(key, err == nil)
+	return err == nil
+}
+
+// gitRun runs git in repoPath through run. Status reads happen on every
+// refresh, so --no-optional-locks keeps them from taking the index lock just
+// to write back refreshed stat data, which would contend with git commands
+// the user runs at the same time.
+func gitRun(ctx context.Context, run commandRunner, repoPath string, args ...string) ([]byte, error) {
+	return run.Run(ctx, repoPath, "git", append([]string{"--no-optional-locks"}, args...)...)
+}
 
-	// Check for untracked files.
-	cmd = exec.Command("git", "ls-files", "--others", "--exclude-standard")
-	cmd.Dir = repoPath
-	if output, err := cmd.Output(); err == nil && len(strings.TrimSpace(string(output))) > 0 {
-		status.HasUntracked = true
+// gitOutput runs git like gitRun and returns its trimmed output.
+func gitOutput(ctx context.Context, run commandRunner, repoPath string, args ...string) (string, error) {
+	output, err := gitRun(ctx, run, repoPath, args...)
+	if err != nil {
+		return "", err
 	}
+	return strings.TrimSpace(string(output)), nil
+}
 
-	// Get ahead/behind counts if we have a tracking branch.
-	if !status.IsDetached && status.CurrentBranch != "" {
-		cmd = exec.Command("git", "rev-list", "--left-right", "--count", "HEAD...@{u}")
-		cmd.Dir = repoPath
-		if output, err := cmd.Output(); err == nil {
-			status.RemoteTrackingOK = true
-			parts := strings.Fields(strings.TrimSpace(string(output)))
-			if len(parts) == 2 {
-				// Format is "ahead behind"
-				if ahead := strings.TrimSpace(parts[0]); ahead != "0" {
-					status.AheadCount = len(ahead) // Simple approximation
-					status.HasUnpushed = true
-				}
-				if behind := strings.TrimSpace(parts[1]); behind != "0" {
-					status.BehindCount = len(behind) // Simple approximation
-				}
-			}
+// defaultBranchRef returns the ref of the repository's default branch: the
+// branch the default remote's HEAD points at, or else a local branch named by
+// init.defaultBranch, main or master. In a repository with no commits yet,
+// init.defaultBranch is returned even though the branch doesn't exist, since
+// it's the name the first commit will create. It returns an empty string when
+// none can be found.
+func defaultBranchRef(ctx context.Context, run commandRunner, repoPath string) string {
+	if ref, err := gitOutput(ctx, run, repoPath, "symbolic-ref", "--short", "refs/remotes/"+defaultRemote+"/HEAD"); err == nil {
+		return ref
+	}
+	configured := gitConfigValue(ctx, run, repoPath, "init.defaultBranch")
+	candidates := []string{"main", "master"}
+	if configured != "" {
+		candidates = append([]string{configured}, candidates...)
+	}
+	for _, name := range candidates {
+		if _, err := gitOutput(ctx, run, repoPath, "rev-parse", "--verify", "--quiet", "refs/heads/"+name); err == nil {
+			return name
 		}
 	}
+	if _, err := gitOutput(ctx, run, repoPath, "rev-parse", "--verify", "--quiet", "HEAD"); err != nil {
+		return configured
+	}
+	return ""
+}
 
-	return status
+// countBranchAuthors counts the distinct authors of the commits in
+// <default>..HEAD. Without a default branch the whole history is counted.
+func countBranchAuthors(ctx context.Context, run commandRunner, repoPath string) int {
+	revRange := "HEAD"
+	if base := defaultBranchRef(ctx, run, repoPath); base != "" {
+		revRange = base + "..HEAD"
+	}
+	output, err := gitOutput(ctx, run, repoPath, "shortlog", "-sn", revRange)
+	if err != nil || output == "" {
+		return 0
+	}
+	return strings.Count(output, "\n") + 1
 }
 
-// jujutsuDetector detects Jujutsu repositories.
-type jujutsuDetector struct{}
+// gitConfigValue returns the value of a git config key as seen from
+// repoPath, including global and system config. Unset keys return an empty
+// string.
+func gitConfigValue(ctx context.Context, run commandRunner, repoPath, key string) string {
+	value, err := gitOutput(ctx, run, repoPath, "config", "--get", key)
+	if err != nil {
+		return ""
+	}
+	return value
+}
 
-// Detect checks for a .jj directory.
-func (j *jujutsuDetector) Detect(path string) (Info, error) {
-	rootPath, found := findVCSRoot(path, ".jj")
-	if !found {
-		return Info{Type: TypeNone}, nil
+// getGitRemoteURLs returns the fetch and push URLs of the given remote. Git
+// reports the fetch URL for --push when no pushurl is configured, so both
+// values match unless the remote pushes somewhere else.
+func getGitRemoteURLs(ctx context.Context, run commandRunner, repoPath, remote string) (fetchURL, pushURL string) {
+	fetchURL, err := gitOutput(ctx, run, repoPath, "remote", "get-url", remote)
+	if err != nil {
+		return "", ""
+	}
+	pushURL, err = gitOutput(ctx, run, repoPath, "remote", "get-url", "--push", remote)
+	if err != nil {
+		pushURL = fetchURL
 	}
+	return fetchURL, pushURL
+}
 
-	status := getJujutsuStatus(rootPath)
+// resolveGitDir returns the git directory for a working tree root. When .git
+// is a file (worktrees and submodules) the "gitdir:" pointer it contains is
+// followed, resolving relative targets against the working tree root. A
+// bare repository's root is its git directory.
+func resolveGitDir(rootPath string) string {
+	gitPath := filepath.Join(rootPath, ".git")
+	content, err := os.ReadFile(gitPath)
+	if errors.Is(err, os.ErrNotExist) && isBareGitDir(rootPath) {
+		return rootPath
+	}
+	if err != nil {
+		// Either a regular .git directory or unreadable; use it as is.
+		return gitPath
+	}
+	line := strings.TrimSpace(string(content))
+	target, ok := strings.CutPrefix(line, "gitdir: ")
+	if !ok {
+		return gitPath
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(rootPath, target)
+	}
+	return filepath.Clean(target)
+}
 
-	return Info{
-		Type:     TypeJujutsu,
-		RepoName: extractRepoName(rootPath),
-		RootPath: rootPath,
-		Status:   status,
-	}, nil
+// gitDirKind classifies the git directory a .git file points at. Linked
+// worktrees get their own directory with a commondir file leading back to
+// the main repository; submodules are stored under the superproject's
+// .git/modules.
+func gitDirKind(gitDir string) (linkedWorktree, submodule bool) {
+	if fileExists(filepath.Join(gitDir, "commondir")) {
+		return true, false
+	}
+	return false, strings.Contains(filepath.ToSlash(gitDir), "/.git/modules/")
 }
 
-// getJujutsuStatus retrieves the current status of a Jujutsu repository.
-func getJujutsuStatus(repoPath string) Status {
-	status := Status{}
+// fileExists reports whether a file or directory exists at path.
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
 
-	// Get current change/branch information.
-	// Use jj log to get the current change with its branches.
-	cmd := exec.Command("jj", "log", "-r", "@", "--no-graph", "-T", "branches")
-	cmd.Dir = repoPath
-	if output, err := cmd.Output(); err == nil {
-		branches := strings.TrimSpace(string(output))
+// isAtReleasedTag reports whether HEAD is exactly at a tag that the remote
+// also has, pointing at the same commit.
+func isAtReleasedTag(ctx context.Context, run commandRunner, repoPath, remote string) bool {
+	tag, err := gitOutput(ctx, run, repoPath, "describe", "--tags", "--exact-match", "HEAD")
+	if err != nil {
+		return false
+	}
+	head, err := gitOutput(ctx, run, repoPath, "rev-parse", "HEAD")
+	if err != nil {
+		return false
+	}
+	refs, err := gitOutput(ctx, run, repoPath, "ls-remote", "--tags", remote)
+	if err != nil {
+		return false
+	}
+	return remoteHasTag(refs, tag, head)
+}
+
+// remoteHasTag reports whether "git ls-remote --tags" output lists tag at
+// the given commit. Annotated tags are matched through their peeled
+// "^{}" entry, lightweight tags through the plain ref.
+func remoteHasTag(lsRemote, tag, commit string) bool {
+	ref := "refs/tags/" + tag
+	for line := range strings.Lines(lsRemote) {
+		sha, name, ok := strings.Cut(strings.TrimSpace(line), "\t")
+		if !ok || sha != commit {
+			continue
+		}
+		if name == ref || name == ref+"^{}" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasActiveHooks reports whether hooksDir contains an executable hook. The
+// ".sample" files git installs by default don't run and are ignored.
+func hasActiveHooks(hooksDir string) bool {
+	entries, err := os.ReadDir(hooksDir)
+	if err != nil {
+		return false
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasSuffix(entry.Name(), ".sample") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if info.Mode().Perm()&0o111 != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCommitTimes parses the NUL-separated strict ISO 8601 author and
+// committer dates printed by "git log --format=%aI%x00%cI". Unparseable
+// values are returned as zero times.
+func parseCommitTimes(output string) (author, committer time.Time) {
+	authorStr, committerStr, _ := strings.Cut(strings.TrimSpace(output), "\x00")
+	author, _ = time.Parse(time.RFC3339, authorStr)
+	committer, _ = time.Parse(time.RFC3339, committerStr)
+	return author, committer
+}
+
+// lastCommitFormat is the "git log" format parsed by parseLastCommit: the
+// short hash, author name, author date and subject, separated by NULs.
+const lastCommitFormat = "--format=%h%x00%an%x00%aI%x00%s"
+
+// getGitLastCommit describes HEAD's commit, or returns nil when there's none
+// or git fails.
+func getGitLastCommit(ctx context.Context, run commandRunner, repoPath string) *CommitInfo {
+	output, err := gitOutput(ctx, run, repoPath, "log", "-1", lastCommitFormat)
+	if err != nil {
+		return nil
+	}
+	return parseLastCommit(output)
+}
+
+// parseLastCommit parses the output of "git log -1" with lastCommitFormat.
+// It returns nil for empty or malformed output.
+func parseLastCommit(output string) *CommitInfo {
+	fields := strings.SplitN(strings.TrimRight(output, "\n"), "\x00", 4)
+	if len(fields) != 4 || fields[0] == "" {
+		return nil
+	}
+	when, _ := time.Parse(time.RFC3339, fields[2])
+	return &CommitInfo{
+		ShortSHA:   fields[0],
+		AuthorName: fields[1],
+		When:       when,
+		Subject:    fields[3],
+	}
+}
+
+// prRefFromFetchHead returns "PR #N" when FETCH_HEAD records that commit was
+// fetched from a pull request head ref (refs/pull/N/head), as happens when a
+// PR is checked out with "git fetch origin pull/N/head && git checkout
+// FETCH_HEAD". It returns an empty string otherwise.
+func prRefFromFetchHead(fetchHead, commit string) string {
+	for line := range strings.Lines(fetchHead) {
+		sha, rest, ok := strings.Cut(line, "\t")
+		if !ok || sha != commit {
+			continue
+		}
+		_, ref, ok := strings.Cut(rest, "refs/pull/")
+		if !ok {
+			continue
+		}
+		number, suffix, ok := strings.Cut(ref, "/")
+		if !ok || !strings.HasPrefix(suffix, "head") || number == "" ||
+			strings.Trim(number, "0123456789") != "" {
+			continue
+		}
+		return "PR #" + number
+	}
+	return ""
+}
+
+// parseStashDescription returns the message of the first entry in
+// "git stash list" output. Entries look like "stash@{0}: On main: message"
+// for named stashes and "stash@{0}: WIP on main: abc1234 subject" otherwise;
+// the "On <branch>: " prefix of named stashes is dropped.
+func parseStashDescription(output string) string {
+	first, _, _ := strings.Cut(output, "\n")
+	_, desc, ok := strings.Cut(first, ": ")
+	if !ok {
+		return ""
+	}
+	if rest, ok := strings.CutPrefix(desc, "On "); ok {
+		if _, message, ok := strings.Cut(rest, ": "); ok {
+			return message
+		}
+	}
+	return desc
+}
+
+// countPathsExcept counts the distinct paths listed one per line in output,
+// ignoring those in exclude.
+func countPathsExcept(output string, exclude []string) int {
+	seen := make(map[string]bool)
+	for line := range strings.Lines(output) {
+		path := strings.TrimSpace(line)
+		if path != "" && !slices.Contains(exclude, path) {
+			seen[path] = true
+		}
+	}
+	return len(seen)
+}
+
+// getGitStatus retrieves the current status of a Git repository. Branch,
+// upstream and working tree state come from a single
+// "git status --porcelain=v2 --branch", so they describe one consistent
+// moment; git releases without porcelain v2 fall back to asking for each
+// piece separately.
+func getGitStatus(ctx context.Context, run commandRunner, repoPath string, opts DetectOptions) Status {
+	status := Status{}
+	gitDir := resolveGitDir(repoPath)
+	// resolveGitDir returns the root itself for a bare repository.
+	status.IsBare = gitDir == repoPath
+
+	status.Operation = gitOperation(gitDir)
+
+	// Repositories created with --reference or --shared borrow objects from
+	// another store listed in objects/info/alternates.
+	status.HasAlternates = fileExists(filepath.Join(gitDir, "objects", "info", "alternates"))
+
+	// Without a commit-graph, ahead/behind and log walks parse every commit
+	// object; "git commit-graph write" fixes that on large repositories.
+	status.HasCommitGraph = fileExists(filepath.Join(gitDir, "objects", "info", "commit-graph")) ||
+		fileExists(filepath.Join(gitDir, "objects", "info", "commit-graphs"))
+
+	// "git clone --depth" records the commits whose parents were cut off.
+	// Counting ahead/behind stops at them rather than at the real merge
+	// base.
+	status.IsShallow = fileExists(filepath.Join(gitDir, "shallow"))
+
+	// Another git process is busy with the index. Querying the working tree
+	// now could contend with it or report a half-updated state, so only the
+	// branch is read and the rest is left for the next refresh.
+	locked := fileExists(filepath.Join(gitDir, "index.lock"))
+
+	porcelain, notRepo := false, false
+	if !status.IsBare && !locked && gitSupportsPorcelainV2(ctx, run, repoPath) {
+		if opts.RefreshIndex {
+			// Exits non-zero when files need updating, which is expected.
+			_, _ = gitOutput(ctx, run, repoPath, "update-index", "-q", "--refresh")
+		}
+		args := []string{"status", "--porcelain=v2", "--branch", "-z"}
+		if opts.SkipUntracked {
+			args = append(args, "--untracked-files=no")
+		}
+		if opts.SkipAheadBehind && gitAtLeast(ctx, run, repoPath, noAheadBehindMinVersion) {
+			args = append(args, "--no-ahead-behind")
+		}
+		output, err := gitRun(ctx, run, repoPath, args...)
+		switch {
+		case err == nil:
+			parsePorcelainV2(string(output)).apply(&status)
+			porcelain = true
+		case isNotARepository(err):
+			// Only the marker is there, such as an empty .git directory;
+			// there's no working tree to compare.
+			notRepo = true
+		default:
+			if failure := commandFailure(ctx, err, "git", args...); failure != nil {
+				// Nothing else would be any more reliable, but the HEAD
+				// file still names the branch without asking git.
+				head := readGitHead(ctx, run, repoPath, gitDir)
+				status.CurrentBranch, status.IsDetached, status.DetachedRef = head.CurrentBranch, head.IsDetached, head.DetachedRef
+				status.Failure = failure
+				return status
+			}
+		}
+		if opts.SkipAheadBehind {
+			// Older git counted them anyway; drop them for consistency.
+			status.AheadCount, status.BehindCount, status.HasUnpushed = 0, 0, false
+		}
+	}
+	if !porcelain {
+		getGitBranch(ctx, run, repoPath, gitDir, &status)
+	}
+	if status.IsDetached {
+		status.DetachedRef = detachedHeadName(ctx, run, repoPath, gitDir)
+	}
+
+	if info, err := os.Stat(filepath.Join(gitDir, "FETCH_HEAD")); err == nil {
+		status.LastFetchTime = info.ModTime()
+		maxAge := opts.FetchStaleAfter
+		if maxAge == 0 {
+			maxAge = DefaultFetchStaleAfter
+		}
+		status.FetchStale = time.Since(status.LastFetchTime) > maxAge
+	}
+
+	if status.CurrentBranch != "" {
+		status.BranchDescription = gitConfigValue(ctx, run, repoPath, "branch."+status.CurrentBranch+".description")
+	}
+
+	status.HasHooks = hasActiveHooks(filepath.Join(gitDir, "hooks"))
+
+	if output, err := gitOutput(ctx, run, repoPath, "log", "-1", "--format=%aI%x00%cI"); err == nil {
+		status.LastCommitAuthorTime, status.LastCommitCommitTime = parseCommitTimes(output)
+	}
+
+	if status.IsBare {
+		// There's no working tree, index or stash to read.
+		return status
+	}
+
+	if locked {
+		status.Locked = true
+		return status
+	}
+
+	if !porcelain && !notRepo {
+		getGitWorkingTreeLegacy(ctx, run, repoPath, opts, &status)
+		if status.Failure != nil {
+			return status
+		}
+	}
+
+	// Untracked files count as something to commit, matching git's own
+	// "nothing to commit" message.
+	status.NothingToCommit = !status.HasConflicts && !status.HasStaged &&
+		!status.HasUncommitted && !status.HasUntracked
+
+	// Count stash entries.
+	if !opts.SkipStash {
+		if output, err := gitOutput(ctx, run, repoPath, "stash", "list"); err == nil && output != "" {
+			status.StashCount = strings.Count(output, "\n") + 1
+			status.TopStashDescription = parseStashDescription(output)
+		}
+	}
+
+	return status
+}
+
+// getGitBranch fills in the branch, unborn and detached state of status
+// without reading the working tree. The HEAD file in gitDir names the
+// branch when it can be read; git is asked otherwise.
+func getGitBranch(ctx context.Context, run commandRunner, repoPath, gitDir string, status *Status) {
+	if branch, commit, ok := readGitHeadFile(gitDir); ok {
+		if commit != "" {
+			status.IsDetached = true
+			return
+		}
+		status.CurrentBranch = branch
+		if _, err := gitOutput(ctx, run, repoPath, "rev-parse", "--verify", "--quiet", "HEAD"); err != nil {
+			status.IsUnborn = true
+		}
+		return
+	}
+	if branch, err := gitOutput(ctx, run, repoPath, "symbolic-ref", "--short", "HEAD"); err == nil {
+		status.CurrentBranch = branch
+		// A new repository's branch has no commits yet, so there's nothing
+		// to compare the working tree or an upstream against.
+		if _, err := gitOutput(ctx, run, repoPath, "rev-parse", "--verify", "--quiet", "HEAD"); err != nil {
+			status.IsUnborn = true
+		}
+		return
+	}
+	if _, err := gitOutput(ctx, run, repoPath, "rev-parse", "--verify", "--quiet", "HEAD"); err == nil {
+		status.IsDetached = true
+	}
+}
+
+// readGitHead reads the branch from the HEAD file in gitDir without running
+// git, shortening a detached HEAD's commit into DetachedRef. It falls back to
+// asking git when the file can't be interpreted, as in reftable
+// repositories.
+func readGitHead(ctx context.Context, run commandRunner, repoPath, gitDir string) Status {
+	var status Status
+	branch, commit, ok := readGitHeadFile(gitDir)
+	if !ok {
+		if branch, err := gitOutput(ctx, run, repoPath, "symbolic-ref", "--short", "HEAD"); err == nil {
+			status.CurrentBranch = branch
+		} else if short, err := gitOutput(ctx, run, repoPath, "rev-parse", "--short", "HEAD"); err == nil {
+			status.IsDetached = true
+			status.DetachedRef = short
+		}
+		return status
+	}
+	if commit != "" {
+		status.IsDetached = true
+		status.DetachedRef = commit[:min(len(commit), 7)]
+		return status
+	}
+	status.CurrentBranch = branch
+	return status
+}
+
+// readGitHeadFile reads and parses the HEAD file in gitDir. Linked worktrees
+// keep their own HEAD in the git directory their .git file points to, which
+// resolveGitDir returns.
+func readGitHeadFile(gitDir string) (branch, commit string, ok bool) {
+	content, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return "", "", false
+	}
+	return parseGitHead(string(content))
+}
+
+// parseGitHead parses the contents of a HEAD file: "ref: refs/heads/<branch>"
+// on a branch, or a full commit hash when detached. ok is false for anything
+// else, including the "refs/heads/.invalid" placeholder of reftable
+// repositories, whose real HEAD only git can read.
+func parseGitHead(content string) (branch, commit string, ok bool) {
+	head := strings.TrimSpace(content)
+	if ref, found := strings.CutPrefix(head, "ref: "); found {
+		branch, found = strings.CutPrefix(strings.TrimSpace(ref), "refs/heads/")
+		if !found || branch == "" || branch == ".invalid" {
+			return "", "", false
+		}
+		return branch, "", true
+	}
+	if (len(head) == 40 || len(head) == 64) && isHex(head) {
+		return "", head, true
+	}
+	return "", "", false
+}
+
+// isHex reports whether s consists only of lowercase hexadecimal digits.
+func isHex(s string) bool {
+	for _, r := range s {
+		if !('0' <= r && r <= '9' || 'a' <= r && r <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// detachedHeadName returns what to call a detached HEAD: the PR number of a
+// fetched pull request, else its tag or the nearest tag plus distance
+// ("v1.2.3-4-gabc1234"), else its short hash.
+func detachedHeadName(ctx context.Context, run commandRunner, repoPath, gitDir string) string {
+	name, _ := gitOutput(ctx, run, repoPath, "rev-parse", "--short", "HEAD")
+	if tag, err := gitOutput(ctx, run, repoPath, "describe", "--tags", "--exact-match", "HEAD"); err == nil {
+		name = tag
+	} else if desc, err := gitOutput(ctx, run, repoPath, "describe", "--tags", "HEAD"); err == nil {
+		name = desc
+	}
+	if fetchHead, err := os.ReadFile(filepath.Join(gitDir, "FETCH_HEAD")); err == nil {
+		if head, err := gitOutput(ctx, run, repoPath, "rev-parse", "HEAD"); err == nil {
+			if pr := prRefFromFetchHead(string(fetchHead), head); pr != "" {
+				name = pr
+			}
+		}
+	}
+	return name
+}
+
+// getGitWorkingTreeLegacy fills in the working tree and upstream state of
+// status one git command at a time, for git releases without
+// "git status --porcelain=v2". It sets status.Failure and stops when the
+// working tree can't be compared.
+func getGitWorkingTreeLegacy(ctx context.Context, run commandRunner, repoPath string, opts DetectOptions, status *Status) {
+	if opts.RefreshIndex {
+		// Exits non-zero when files need updating, which is expected.
+		_, _ = gitOutput(ctx, run, repoPath, "update-index", "-q", "--refresh")
+	}
+
+	// Check for conflicts. Paths are listed once per unmerged stage on some
+	// git releases, so count each one once.
+	var conflicted []string
+	if output, err := gitOutput(ctx, run, repoPath, "diff", "--name-only", "--diff-filter=U"); err == nil && output != "" {
+		conflicted = strings.Split(output, "\n")
+		slices.Sort(conflicted)
+		conflicted = slices.Compact(conflicted)
+	}
+	status.ConflictCount = len(conflicted)
+	status.HasConflicts = status.ConflictCount > 0
+
+	if opts.CountFiles {
+		// Unmerged paths also show up in both diffs; they're already
+		// counted as conflicts, so leave them out here.
+		if output, err := gitOutput(ctx, run, repoPath, "diff", "--cached", "--name-only"); err == nil {
+			status.StagedCount = countPathsExcept(output, conflicted)
+		}
+		if output, err := gitOutput(ctx, run, repoPath, "diff", "--name-only"); err == nil {
+			status.ModifiedCount = countPathsExcept(output, conflicted)
+		}
+	}
+
+	// "diff --quiet" exits with 1 when there are changes; any other failure
+	// means the working tree couldn't be compared at all.
+	for _, check := range []struct {
+		args []string
+		flag *bool
+	}{
+		{[]string{"diff", "--cached", "--quiet"}, &status.HasStaged},
+		{[]string{"diff", "--quiet"}, &status.HasUncommitted},
+	} {
+		_, err := gitRun(ctx, run, repoPath, check.args...)
+		if err == nil {
+			continue
+		}
+		if exitCode(err) == 1 {
+			*check.flag = true
+			continue
+		}
+		if status.Failure = commandFailure(ctx, err, "git", check.args...); status.Failure != nil {
+			return
+		}
+	}
+
+	if !opts.SkipUntracked {
+		if output, err := gitOutput(ctx, run, repoPath, "ls-files", "--others", "--exclude-standard"); err == nil {
+			status.UntrackedCount = countPathsExcept(output, nil)
+			status.HasUntracked = status.UntrackedCount > 0
+		}
+	}
+
+	// Get ahead/behind counts if we have a tracking branch.
+	if !status.IsDetached && status.CurrentBranch != "" && opts.SkipAheadBehind {
+		if _, err := gitOutput(ctx, run, repoPath, "rev-parse", "--verify", "--quiet", "@{u}"); err == nil {
+			status.RemoteTrackingOK = true
+		}
+	} else if !status.IsDetached && status.CurrentBranch != "" {
+		if output, err := gitOutput(ctx, run, repoPath, "rev-list", "--left-right", "--count", "HEAD...@{u}"); err == nil {
+			status.RemoteTrackingOK = true
+			status.AheadCount, status.BehindCount = parseAheadBehind(output)
+			status.HasUnpushed = status.AheadCount > 0
+		}
+	}
+	if !status.RemoteTrackingOK && status.CurrentBranch != "" && !status.IsDetached {
+		// git's error for @{u} tells the two cases apart too, but it's
+		// translated; the branch's merge setting isn't.
+		status.UpstreamGone = gitConfigValue(ctx, run, repoPath, "branch."+status.CurrentBranch+".merge") != ""
+	}
+}
+
+// parseAheadBehind parses the "ahead<TAB>behind" output of
+// "git rev-list --left-right --count HEAD...@{u}". Malformed output counts as
+// zero in both directions.
+func parseAheadBehind(output string) (ahead, behind int) {
+	parts := strings.Fields(output)
+	if len(parts) != 2 {
+		return 0, 0
+	}
+	ahead, aheadErr := strconv.Atoi(parts[0])
+	behind, behindErr := strconv.Atoi(parts[1])
+	if aheadErr != nil || behindErr != nil {
+		return 0, 0
+	}
+	return ahead, behind
+}
+
+// jujutsuDetector detects Jujutsu repositories.
+type jujutsuDetector struct {
+	run             commandRunner // Nil runs jj from PATH
+	searchAboveHome bool          // See DetectOptions.SearchAboveHome
+}
+
+// Detect checks for a .jj directory.
+func (j *jujutsuDetector) Detect(path string) (Info, error) {
+	return j.DetectContext(context.Background(), path)
+}
+
+// DetectContext is like Detect, but honors ctx as described on Detector.
+func (j *jujutsuDetector) DetectContext(ctx context.Context, path string) (Info, error) {
+	rootPath, found := findVCSRoot(path, ".jj", ceilingDirs(j.searchAboveHome))
+	if !found {
+		return Info{Type: TypeNone}, nil
+	}
+
+	run := runnerOr(j.run)
+	if !runnerHas(run, "jj") {
+		return Info{
+			Type:     TypeJujutsu,
+			RepoName: extractRepoName(rootPath, ""),
+			RootPath: rootPath,
+			Status:   Status{ToolUnavailable: true},
+		}, ctx.Err()
+	}
+
+	status := getJujutsuStatus(ctx, run, rootPath)
+	remoteURL := getJujutsuRemoteURL(ctx, run, rootPath, defaultRemote)
+
+	return Info{
+		Type:      TypeJujutsu,
+		RepoName:  extractRepoName(rootPath, remoteURL),
+		RootPath:  rootPath,
+		RemoteURL: remoteURL,
+		Status:    status,
+	}, ctx.Err()
+}
+
+// jujutsuBookmarksMinVersion is the first jj release that calls branches
+// bookmarks; later releases dropped the "branches" template keyword.
+var jujutsuBookmarksMinVersion = toolVersion{0, 22}
+
+// installedJujutsuVersion caches the version of jj on PATH.
+var installedJujutsuVersion versionCache
+
+// jujutsuBookmarksKeyword returns the template keyword that lists the
+// bookmarks of a change for the jj that run runs in dir.
+func jujutsuBookmarksKeyword(ctx context.Context, run commandRunner, dir string) string {
+	v, ok := installedJujutsuVersion.get(ctx, run, func(ctx context.Context) (toolVersion, bool) {
+		output, err := run.Run(ctx, dir, "jj", "--version")
+		if err != nil {
+			return toolVersion{}, false
+		}
+		major, minor, ok := parseJujutsuVersion(string(output))
+		return toolVersion{major, minor}, ok
+	})
+	return bookmarksKeywordFor(v[0], v[1], ok)
+}
+
+// bookmarksKeywordFor picks "bookmarks" or the legacy "branches" template
+// keyword for a jj version. Unparseable versions are assumed to be recent.
+func bookmarksKeywordFor(major, minor int, ok bool) string {
+	if ok && (major < jujutsuBookmarksMinVersion[0] ||
+		major == jujutsuBookmarksMinVersion[0] && minor < jujutsuBookmarksMinVersion[1]) {
+		return "branches"
+	}
+	return "bookmarks"
+}
+
+// parseJujutsuVersion extracts the major and minor version from
+// "jj --version" output such as "jj 0.21.0" or
+// "jj 0.28.2-ac4ab6d7d8f2e7b1".
+func parseJujutsuVersion(output string) (major, minor int, ok bool) {
+	rest, found := strings.CutPrefix(strings.TrimSpace(output), "jj ")
+	if !found {
+		return 0, 0, false
+	}
+	version, _, _ := strings.Cut(rest, "-")
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return 0, 0, false
+	}
+	major, majorErr := strconv.Atoi(parts[0])
+	minor, minorErr := strconv.Atoi(parts[1])
+	if majorErr != nil || minorErr != nil {
+		return 0, 0, false
+	}
+	return major, minor, true
+}
+
+// getJujutsuRemoteURL returns the URL of the named git remote of a Jujutsu
+// repository, or "" if it has none.
+func getJujutsuRemoteURL(ctx context.Context, run commandRunner, repoPath, remote string) string {
+	output, err := jjRun(ctx, run, repoPath, "git", "remote", "list")
+	if err != nil {
+		return ""
+	}
+	return parseJujutsuRemoteList(string(output))[remote]
+}
+
+// parseJujutsuRemoteList parses "jj git remote list" output, where each line
+// has the form "<name> <url>".
+func parseJujutsuRemoteList(output string) map[string]string {
+	remotes := make(map[string]string)
+	for line := range strings.Lines(output) {
+		name, remoteURL, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok {
+			continue
+		}
+		remotes[name] = strings.TrimSpace(remoteURL)
+	}
+	return remotes
+}
+
+// getJujutsuStatus retrieves the current status of a Jujutsu repository.
+func getJujutsuStatus(ctx context.Context, run commandRunner, repoPath string) Status {
+	status := Status{}
+
+	// Get current change/branch information.
+	// Use jj log to get the current change with its bookmarks (called
+	// branches before jj 0.22).
+	if output, err := jjRun(ctx, run, repoPath, "log", "-r", "@", "--no-graph", "-T", jujutsuBookmarksKeyword(ctx, run, repoPath)); err == nil {
+		branches := strings.TrimSpace(string(output))
 		if branches != "" {
 			// If multiple branches, take the first one.
 			branchList := strings.Fields(branches)
@@ -269,9 +1680,7 @@ func getJujutsuStatus(repoPath string) Status {
 
 	// If no branch name found, try to get the change ID.
 	if status.CurrentBranch == "" {
-		cmd = exec.Command("jj", "log", "-r", "@", "--no-graph", "-T", "change_id.short()")
-		cmd.Dir = repoPath
-		if output, err := cmd.Output(); err == nil {
+		if output, err := jjRun(ctx, run, repoPath, "log", "-r", "@", "--no-graph", "-T", "change_id.short()"); err == nil {
 			changeID := strings.TrimSpace(string(output))
 			if changeID != "" {
 				status.CurrentBranch = changeID
@@ -280,19 +1689,331 @@ func getJujutsuStatus(repoPath string) Status {
 	}
 
 	// Check for uncommitted changes.
-	cmd = exec.Command("jj", "status")
-	cmd.Dir = repoPath
-	if output, err := cmd.Output(); err == nil {
-		statusOutput := string(output)
+	if output, err := jjRun(ctx, run, repoPath, "status"); err == nil {
 		// Jujutsu shows "Working copy changes:" when there are uncommitted changes.
-		if strings.Contains(statusOutput, "Working copy changes:") {
+		if strings.Contains(string(output), "Working copy changes:") {
 			status.HasUncommitted = true
 		}
-		// Check for conflicts.
-		if strings.Contains(statusOutput, "conflict") {
-			status.HasConflicts = true
+	}
+
+	// The working-copy change records its own conflicts and divergence.
+	if output, err := jjRun(ctx, run, repoPath, "log", "-r", "@", "--no-graph", "-T", `conflict ++ " " ++ divergent`); err == nil {
+		status.HasConflicts, status.IsDivergent = parseJujutsuFlags(string(output))
+	}
+
+	// Count changes on either side of trunk(), which falls back to the root
+	// commit when no trunk bookmark is found; counting against that would
+	// report the whole history as ahead.
+	if output, err := jjRun(ctx, run, repoPath, "log", "-r", "trunk()", "--no-graph", "-T", "root"); err == nil && strings.TrimSpace(string(output)) == "false" {
+		// An empty working-copy change isn't work to push yet.
+		if output, err := jjRun(ctx, run, repoPath, "log", "-r", "trunk()..@ ~ (@ & empty())", "--no-graph", "-T", `change_id.short() ++ "\n"`); err == nil {
+			status.AheadCount = parseJujutsuCount(string(output))
+		}
+		if output, err := jjRun(ctx, run, repoPath, "log", "-r", "@..trunk()", "--no-graph", "-T", `change_id.short() ++ "\n"`); err == nil {
+			status.BehindCount = parseJujutsuCount(string(output))
 		}
 	}
 
+	// An empty working-copy change means there's nothing to commit.
+	if output, err := jjRun(ctx, run, repoPath, "log", "-r", "@", "--no-graph", "-T", "empty"); err == nil {
+		status.NothingToCommit = parseJujutsuEmpty(string(output))
+	}
+
+	// Look for changes rewritten in two places at once.
+	if output, err := jjRun(ctx, run, repoPath, "log", "-r", "divergent()", "--no-graph", "-T", `change_id.short() ++ "\n"`); err == nil {
+		status.HasDivergentChanges = parseJujutsuDivergent(string(output))
+	}
+
+	// Describe the parent change for context when @ is empty.
+	if output, err := jjRun(ctx, run, repoPath, "log", "-r", "@-", "--no-graph", "-T", `description.first_line() ++ "\n"`); err == nil {
+		status.ParentDescription = parseJujutsuParentDescription(string(output))
+	}
+
 	return status
 }
+
+// jjRun runs jj in repoPath through run. Color is turned off so ANSI codes
+// can't end up in parsed output.
+func jjRun(ctx context.Context, run commandRunner, repoPath string, args ...string) ([]byte, error) {
+	return run.Run(ctx, repoPath, "jj", append([]string{"--color=never"}, args...)...)
+}
+
+// parseJujutsuFlags parses the output of the `conflict ++ " " ++ divergent`
+// template, e.g. "true false".
+func parseJujutsuFlags(output string) (conflict, divergent bool) {
+	fields := strings.Fields(output)
+	if len(fields) != 2 {
+		return false, false
+	}
+	return fields[0] == "true", fields[1] == "true"
+}
+
+// parseJujutsuCount counts the change ids "jj log" listed, one per line.
+func parseJujutsuCount(output string) int {
+	count := 0
+	for line := range strings.Lines(output) {
+		if strings.TrimSpace(line) != "" {
+			count++
+		}
+	}
+	return count
+}
+
+// parseJujutsuEmpty parses the output of the "empty" template keyword, which
+// is "true" when the change has no file modifications.
+func parseJujutsuEmpty(output string) bool {
+	return strings.TrimSpace(output) == "true"
+}
+
+// parseJujutsuDivergent reports whether "jj log -r 'divergent()'" listed any
+// change ids.
+func parseJujutsuDivergent(output string) bool {
+	return strings.TrimSpace(output) != ""
+}
+
+// parseJujutsuParentDescription returns the first non-empty description from
+// "jj log -r @-" output. Merges have one line per parent.
+func parseJujutsuParentDescription(output string) string {
+	for line := range strings.Lines(output) {
+		if desc := strings.TrimSpace(line); desc != "" {
+			return desc
+		}
+	}
+	return ""
+}
+
+// mercurialDetector detects Mercurial repositories.
+type mercurialDetector struct {
+	run             commandRunner // Nil runs hg from PATH
+	searchAboveHome bool          // See DetectOptions.SearchAboveHome
+}
+
+// Detect checks for a .hg directory.
+func (m *mercurialDetector) Detect(path string) (Info, error) {
+	return m.DetectContext(context.Background(), path)
+}
+
+// DetectContext is like Detect, but honors ctx as described on Detector.
+func (m *mercurialDetector) DetectContext(ctx context.Context, path string) (Info, error) {
+	rootPath, found := findVCSRoot(path, ".hg", ceilingDirs(m.searchAboveHome))
+	if !found {
+		return Info{Type: TypeNone}, nil
+	}
+
+	return Info{
+		Type:     TypeMercurial,
+		RepoName: extractRepoName(rootPath, ""),
+		RootPath: rootPath,
+		Status:   getMercurialStatus(ctx, runnerOr(m.run), rootPath),
+	}, ctx.Err()
+}
+
+// getMercurialStatus retrieves the current status of a Mercurial repository.
+func getMercurialStatus(ctx context.Context, run commandRunner, repoPath string) Status {
+	status := Status{}
+
+	if output, err := run.Run(ctx, repoPath, "hg", "branch"); err == nil {
+		status.CurrentBranch = strings.TrimSpace(string(output))
+	}
+
+	if output, err := run.Run(ctx, repoPath, "hg", "status"); err == nil {
+		status.HasUncommitted, status.HasUntracked = parseMercurialStatus(string(output))
+	}
+
+	// hg status doesn't report merge conflicts; files still unresolved after
+	// a merge are listed by hg resolve instead.
+	if output, err := run.Run(ctx, repoPath, "hg", "resolve", "--list"); err == nil {
+		status.HasConflicts = parseMercurialUnresolved(string(output))
+	}
+
+	return status
+}
+
+// parseMercurialStatus parses "hg status" output, where each line starts
+// with a status code: M, A, R and ! (missing) are uncommitted changes and ?
+// marks an untracked file.
+func parseMercurialStatus(output string) (uncommitted, untracked bool) {
+	for line := range strings.Lines(output) {
+		if line == "" {
+			continue
+		}
+		switch line[0] {
+		case 'M', 'A', 'R', '!':
+			uncommitted = true
+		case '?':
+			untracked = true
+		}
+	}
+	return uncommitted, untracked
+}
+
+// parseMercurialUnresolved reports whether "hg resolve --list" lists any
+// file as unresolved (U).
+func parseMercurialUnresolved(output string) bool {
+	for line := range strings.Lines(output) {
+		if strings.HasPrefix(line, "U ") {
+			return true
+		}
+	}
+	return false
+}
+
+// subversionDetector detects Subversion working copies.
+type subversionDetector struct {
+	run             commandRunner // Nil runs svn from PATH
+	searchAboveHome bool          // See DetectOptions.SearchAboveHome
+}
+
+// Detect checks for a .svn directory.
+func (v *subversionDetector) Detect(path string) (Info, error) {
+	return v.DetectContext(context.Background(), path)
+}
+
+// DetectContext is like Detect, but honors ctx as described on Detector.
+func (v *subversionDetector) DetectContext(ctx context.Context, path string) (Info, error) {
+	rootPath, found := findVCSRoot(path, ".svn", ceilingDirs(v.searchAboveHome))
+	if !found {
+		return Info{Type: TypeNone}, nil
+	}
+
+	return Info{
+		Type:     TypeSubversion,
+		RepoName: extractRepoName(rootPath, ""),
+		RootPath: rootPath,
+		Status:   getSubversionStatus(ctx, runnerOr(v.run), rootPath),
+	}, ctx.Err()
+}
+
+// getSubversionStatus retrieves the current status of a Subversion working
+// copy.
+func getSubversionStatus(ctx context.Context, run commandRunner, repoPath string) Status {
+	status := Status{}
+
+	if output, err := run.Run(ctx, repoPath, "svn", "info", "--show-item", "url"); err == nil {
+		status.CurrentBranch = parseSubversionBranch(string(output))
+	}
+
+	if output, err := run.Run(ctx, repoPath, "svn", "status"); err == nil {
+		status.HasUncommitted, status.HasUntracked, status.HasConflicts = parseSubversionStatus(string(output))
+	}
+
+	return status
+}
+
+// parseSubversionBranch derives a branch name from a working copy URL using
+// the standard layout: "trunk" for .../trunk, the branch or tag name for
+// .../branches/<name> and .../tags/<name>, and the last path element
+// otherwise.
+func parseSubversionBranch(url string) string {
+	parts := strings.Split(strings.Trim(strings.TrimSpace(url), "/"), "/")
+	for i, part := range parts {
+		switch part {
+		case "trunk":
+			return "trunk"
+		case "branches", "tags":
+			if i+1 < len(parts) {
+				return parts[i+1]
+			}
+		}
+	}
+	return parts[len(parts)-1]
+}
+
+// parseSubversionStatus parses "svn status" output. The first column holds
+// the item's state: ? is untracked, C is conflicted, and A, D, M, R, ! and ~
+// are uncommitted changes. A C in the second column is a property conflict
+// and one in the seventh a tree conflict.
+func parseSubversionStatus(output string) (uncommitted, untracked, conflicts bool) {
+	for line := range strings.Lines(output) {
+		line = strings.TrimRight(line, "\n")
+		if line == "" {
+			continue
+		}
+		switch line[0] {
+		case '?':
+			untracked = true
+		case 'C':
+			conflicts = true
+		case 'A', 'D', 'M', 'R', '!', '~':
+			uncommitted = true
+		}
+		if len(line) > 1 && line[1] == 'C' || len(line) > 6 && line[6] == 'C' {
+			conflicts = true
+		}
+	}
+	return uncommitted, untracked, conflicts
+}
+
+// fossilCheckoutFiles are the names Fossil gives the checkout database at
+// the root of a checkout: .fslckout on Unix and _FOSSIL_ on Windows.
+var fossilCheckoutFiles = []string{".fslckout", "_FOSSIL_"}
+
+// fossilCheckoutFile returns the path of the Fossil checkout database in dir,
+// if there is one.
+func fossilCheckoutFile(dir string) (string, bool) {
+	for _, name := range fossilCheckoutFiles {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// fossilDetector detects Fossil checkouts.
+type fossilDetector struct {
+	run             commandRunner // Nil runs fossil from PATH
+	searchAboveHome bool          // See DetectOptions.SearchAboveHome
+}
+
+// Detect checks for a .fslckout or _FOSSIL_ file.
+func (f *fossilDetector) Detect(path string) (Info, error) {
+	return f.DetectContext(context.Background(), path)
+}
+
+// DetectContext is like Detect, but honors ctx as described on Detector.
+func (f *fossilDetector) DetectContext(ctx context.Context, path string) (Info, error) {
+	rootPath, found := findVCSRootFile(path, ceilingDirs(f.searchAboveHome), fossilCheckoutFiles...)
+	if !found {
+		return Info{Type: TypeNone}, nil
+	}
+
+	return Info{
+		Type:     TypeFossil,
+		RepoName: extractRepoName(rootPath, ""),
+		RootPath: rootPath,
+		Status:   getFossilStatus(ctx, runnerOr(f.run), rootPath),
+	}, ctx.Err()
+}
+
+// getFossilStatus retrieves the current status of a Fossil checkout.
+func getFossilStatus(ctx context.Context, run commandRunner, repoPath string) Status {
+	status := Status{}
+
+	if output, err := run.Run(ctx, repoPath, "fossil", "branch", "current"); err == nil {
+		status.CurrentBranch = strings.TrimSpace(string(output))
+	}
+
+	if output, err := run.Run(ctx, repoPath, "fossil", "changes"); err == nil {
+		status.HasUncommitted, status.HasConflicts = parseFossilChanges(string(output))
+	}
+
+	return status
+}
+
+// parseFossilChanges parses "fossil changes" output, which lists one changed
+// file per line prefixed with its state, such as EDITED, ADDED or CONFLICT.
+// Any line is an uncommitted change.
+func parseFossilChanges(output string) (uncommitted, conflicts bool) {
+	for line := range strings.Lines(output) {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		uncommitted = true
+		if fields[0] == "CONFLICT" {
+			conflicts = true
+		}
+	}
+	return uncommitted, conflicts
+}
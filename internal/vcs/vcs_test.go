@@ -1,10 +1,14 @@
 package vcs
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -137,6 +141,211 @@ func TestJujutsuDetector(t *testing.T) {
 	})
 }
 
+func TestMercurialDetector(t *testing.T) {
+	t.Parallel()
+
+	t.Run("detects mercurial repository from subdirectory", func(t *testing.T) {
+		t.Parallel()
+		tmpDir := t.TempDir()
+		require.NoError(t, os.Mkdir(filepath.Join(tmpDir, ".hg"), 0o755))
+		subDir := filepath.Join(tmpDir, "subdir")
+		require.NoError(t, os.Mkdir(subDir, 0o755))
+
+		info, err := (&mercurialDetector{}).Detect(subDir)
+		require.NoError(t, err)
+		require.Equal(t, TypeMercurial, info.Type)
+		require.Equal(t, filepath.Base(tmpDir), info.RepoName)
+		require.Equal(t, tmpDir, info.RootPath)
+	})
+
+	t.Run("returns TypeNone when no mercurial repository found", func(t *testing.T) {
+		t.Parallel()
+		info, err := (&mercurialDetector{}).Detect(t.TempDir())
+		require.NoError(t, err)
+		require.Equal(t, TypeNone, info.Type)
+	})
+
+	t.Run("detected after git and jujutsu", func(t *testing.T) {
+		t.Parallel()
+		tmpDir := t.TempDir()
+		require.NoError(t, os.Mkdir(filepath.Join(tmpDir, ".hg"), 0o755))
+
+		info, err := NewDetector().Detect(tmpDir)
+		require.NoError(t, err)
+		require.Equal(t, TypeMercurial, info.Type)
+
+		require.NoError(t, os.Mkdir(filepath.Join(tmpDir, ".jj"), 0o755))
+		info, err = NewDetector().Detect(tmpDir)
+		require.NoError(t, err)
+		require.Equal(t, TypeJujutsu, info.Type)
+	})
+}
+
+func TestParseMercurialStatus(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name                   string
+		output                 string
+		uncommitted, untracked bool
+	}{
+		{name: "clean", output: ""},
+		{name: "modified", output: "M main.go\n", uncommitted: true},
+		{name: "added and untracked", output: "A new.go\n? notes.txt\n", uncommitted: true, untracked: true},
+		{name: "missing", output: "! gone.go\n", uncommitted: true},
+		{name: "untracked only", output: "? notes.txt\n", untracked: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			uncommitted, untracked := parseMercurialStatus(tt.output)
+			require.Equal(t, tt.uncommitted, uncommitted)
+			require.Equal(t, tt.untracked, untracked)
+		})
+	}
+
+	require.True(t, parseMercurialUnresolved("R a.go\nU b.go\n"))
+	require.False(t, parseMercurialUnresolved("R a.go\n"))
+}
+
+func TestSubversionDetector(t *testing.T) {
+	t.Parallel()
+
+	t.Run("detects checkout root from subdirectory", func(t *testing.T) {
+		t.Parallel()
+		tmpDir := t.TempDir()
+		require.NoError(t, os.Mkdir(filepath.Join(tmpDir, ".svn"), 0o755))
+		subDir := filepath.Join(tmpDir, "src", "pkg")
+		require.NoError(t, os.MkdirAll(subDir, 0o755))
+
+		info, err := NewDetector().Detect(subDir)
+		require.NoError(t, err)
+		require.Equal(t, TypeSubversion, info.Type)
+		require.Equal(t, tmpDir, info.RootPath)
+	})
+
+	t.Run("climbs to the topmost .svn of an old-style checkout", func(t *testing.T) {
+		t.Parallel()
+		tmpDir := t.TempDir()
+		checkout := filepath.Join(tmpDir, "checkout")
+		subDir := filepath.Join(checkout, "src", "pkg")
+		for _, dir := range []string{checkout, filepath.Join(checkout, "src"), subDir} {
+			require.NoError(t, os.MkdirAll(filepath.Join(dir, ".svn"), 0o755))
+		}
+
+		info, err := (&subversionDetector{}).Detect(subDir)
+		require.NoError(t, err)
+		require.Equal(t, TypeSubversion, info.Type)
+		require.Equal(t, checkout, info.RootPath)
+		require.Equal(t, "checkout", info.RepoName)
+
+		root, typ, ok := RepoRootFor(subDir)
+		require.True(t, ok)
+		require.Equal(t, TypeSubversion, typ)
+		require.Equal(t, checkout, root)
+	})
+
+	t.Run("returns TypeNone when no working copy found", func(t *testing.T) {
+		t.Parallel()
+		info, err := (&subversionDetector{}).Detect(t.TempDir())
+		require.NoError(t, err)
+		require.Equal(t, TypeNone, info.Type)
+	})
+}
+
+func TestParseSubversionBranch(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"https://svn.example.com/repo/trunk\n":              "trunk",
+		"https://svn.example.com/repo/trunk/src":            "trunk",
+		"https://svn.example.com/repo/branches/release-1.2": "release-1.2",
+		"https://svn.example.com/repo/branches/feature/sub": "feature",
+		"https://svn.example.com/repo/tags/v1.0":            "v1.0",
+		"https://svn.example.com/repo/project":              "project",
+	}
+	for url, want := range tests {
+		require.Equal(t, want, parseSubversionBranch(url), url)
+	}
+}
+
+func TestParseSubversionStatus(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name                              string
+		output                            string
+		uncommitted, untracked, conflicts bool
+	}{
+		{name: "clean", output: ""},
+		{name: "modified", output: "M       main.c\n", uncommitted: true},
+		{name: "untracked", output: "?       notes.txt\n", untracked: true},
+		{name: "text conflict", output: "C       main.c\n", conflicts: true},
+		{name: "tree conflict", output: "A  +  C lib.c\n      >   local add, incoming add upon update\n", uncommitted: true, conflicts: true},
+		{name: "externals header ignored", output: "\nPerforming status on external item at 'vendor':\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			uncommitted, untracked, conflicts := parseSubversionStatus(tt.output)
+			require.Equal(t, tt.uncommitted, uncommitted)
+			require.Equal(t, tt.untracked, untracked)
+			require.Equal(t, tt.conflicts, conflicts)
+		})
+	}
+}
+
+func TestFossilDetector(t *testing.T) {
+	t.Parallel()
+
+	for _, marker := range []string{".fslckout", "_FOSSIL_"} {
+		t.Run("detects checkout marked by "+marker, func(t *testing.T) {
+			t.Parallel()
+			tmpDir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(tmpDir, marker), nil, 0o644))
+			subDir := filepath.Join(tmpDir, "src")
+			require.NoError(t, os.Mkdir(subDir, 0o755))
+
+			info, err := NewDetector().Detect(subDir)
+			require.NoError(t, err)
+			require.Equal(t, TypeFossil, info.Type)
+			require.Equal(t, tmpDir, info.RootPath)
+			require.Equal(t, filepath.Base(tmpDir), info.RepoName)
+
+			root, typ, ok := RepoRootFor(subDir)
+			require.True(t, ok)
+			require.Equal(t, TypeFossil, typ)
+			require.Equal(t, tmpDir, root)
+		})
+	}
+
+	t.Run("ignores a directory named like the marker", func(t *testing.T) {
+		t.Parallel()
+		tmpDir := t.TempDir()
+		require.NoError(t, os.Mkdir(filepath.Join(tmpDir, ".fslckout"), 0o755))
+
+		info, err := (&fossilDetector{}).Detect(tmpDir)
+		require.NoError(t, err)
+		require.Equal(t, TypeNone, info.Type)
+	})
+}
+
+func TestParseFossilChanges(t *testing.T) {
+	t.Parallel()
+
+	uncommitted, conflicts := parseFossilChanges("")
+	require.False(t, uncommitted)
+	require.False(t, conflicts)
+
+	uncommitted, conflicts = parseFossilChanges("EDITED     main.c\nADDED      util.c\n")
+	require.True(t, uncommitted)
+	require.False(t, conflicts)
+
+	uncommitted, conflicts = parseFossilChanges("CONFLICT   main.c\n")
+	require.True(t, uncommitted)
+	require.True(t, conflicts)
+}
+
 func TestNewDetector(t *testing.T) {
 	t.Parallel()
 
@@ -189,6 +398,34 @@ func TestNewDetector(t *testing.T) {
 		info, err := detector.Detect(tmpDir)
 		require.NoError(t, err)
 		require.Equal(t, TypeGit, info.Type)
+		require.True(t, info.IsColocated)
+	})
+
+	t.Run("prefers jj in colocated repositories when asked", func(t *testing.T) {
+		t.Parallel()
+		tmpDir := t.TempDir()
+		require.NoError(t, os.Mkdir(filepath.Join(tmpDir, ".git"), 0o755))
+		require.NoError(t, os.Mkdir(filepath.Join(tmpDir, ".jj"), 0o755))
+
+		info, err := NewDetectorWithPreference(TypeJujutsu).Detect(tmpDir)
+		require.NoError(t, err)
+		require.Equal(t, TypeJujutsu, info.Type)
+		require.Equal(t, tmpDir, info.RootPath)
+		require.True(t, info.IsColocated)
+	})
+
+	t.Run("keeps a git checkout nested in a jj repository", func(t *testing.T) {
+		t.Parallel()
+		tmpDir := t.TempDir()
+		require.NoError(t, os.Mkdir(filepath.Join(tmpDir, ".jj"), 0o755))
+		nested := filepath.Join(tmpDir, "vendor", "lib")
+		require.NoError(t, os.MkdirAll(filepath.Join(nested, ".git"), 0o755))
+
+		info, err := NewDetectorWithPreference(TypeJujutsu).Detect(nested)
+		require.NoError(t, err)
+		require.Equal(t, TypeGit, info.Type)
+		require.Equal(t, nested, info.RootPath)
+		require.False(t, info.IsColocated)
 	})
 
 	t.Run("returns TypeNone when no VCS found", func(t *testing.T) {
@@ -202,3 +439,1941 @@ func TestNewDetector(t *testing.T) {
 		require.Equal(t, "", info.RepoName)
 	})
 }
+
+func TestGitAlternates(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reports alternates when objects/info/alternates exists", func(t *testing.T) {
+		t.Parallel()
+		tmpDir := t.TempDir()
+
+		infoDir := filepath.Join(tmpDir, ".git", "objects", "info")
+		err := os.MkdirAll(infoDir, 0o755)
+		require.NoError(t, err)
+		err = os.WriteFile(filepath.Join(infoDir, "alternates"), []byte("/srv/git/shared.git/objects\n"), 0o644)
+		require.NoError(t, err)
+
+		detector := &gitDetector{}
+		info, err := detector.Detect(tmpDir)
+		require.NoError(t, err)
+		require.True(t, info.Status.HasAlternates)
+	})
+
+	t.Run("does not report alternates for a plain repository", func(t *testing.T) {
+		t.Parallel()
+		tmpDir := t.TempDir()
+
+		err := os.Mkdir(filepath.Join(tmpDir, ".git"), 0o755)
+		require.NoError(t, err)
+
+		detector := &gitDetector{}
+		info, err := detector.Detect(tmpDir)
+		require.NoError(t, err)
+		require.False(t, info.Status.HasAlternates)
+	})
+}
+
+// initGitRepo initializes a real git repository in a temporary directory.
+func initGitRepo(t *testing.T) string {
+	t.Helper()
+	tmpDir := t.TempDir()
+	runGit(t, tmpDir, "init")
+	return tmpDir
+}
+
+// runGit runs a git command in dir and fails the test on error. A fixed
+// identity is provided so commits work without any global git config.
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Crush Test",
+		"GIT_AUTHOR_EMAIL=crush@example.com",
+		"GIT_COMMITTER_NAME=Crush Test",
+		"GIT_COMMITTER_EMAIL=crush@example.com",
+	)
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, "git %v: %s", args, output)
+	return string(output)
+}
+
+func TestGitRemoteURLs(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reports distinct fetch and push URLs", func(t *testing.T) {
+		t.Parallel()
+		repo := initGitRepo(t)
+		runGit(t, repo, "remote", "add", "origin", "https://mirror.example.com/crush.git")
+		runGit(t, repo, "remote", "set-url", "--push", "origin", "git@github.com:charmbracelet/crush.git")
+
+		info, err := (&gitDetector{}).Detect(repo)
+		require.NoError(t, err)
+		require.Equal(t, "https://mirror.example.com/crush.git", info.FetchURL)
+		require.Equal(t, "git@github.com:charmbracelet/crush.git", info.PushURL)
+	})
+
+	t.Run("push URL matches fetch URL when not overridden", func(t *testing.T) {
+		t.Parallel()
+		repo := initGitRepo(t)
+		runGit(t, repo, "remote", "add", "origin", "git@github.com:charmbracelet/crush.git")
+
+		info, err := (&gitDetector{}).Detect(repo)
+		require.NoError(t, err)
+		require.Equal(t, "git@github.com:charmbracelet/crush.git", info.FetchURL)
+		require.Equal(t, info.FetchURL, info.PushURL)
+	})
+
+	t.Run("names the repository after the remote", func(t *testing.T) {
+		t.Parallel()
+		repo := initGitRepo(t)
+		runGit(t, repo, "remote", "add", "origin", "git@github.com:charmbracelet/crush.git")
+
+		info, err := (&gitDetector{}).Detect(repo)
+		require.NoError(t, err)
+		require.Equal(t, "git@github.com:charmbracelet/crush.git", info.RemoteURL)
+		require.Equal(t, "crush", info.RepoName)
+	})
+
+	t.Run("leaves URLs empty without a remote", func(t *testing.T) {
+		t.Parallel()
+		repo := initGitRepo(t)
+
+		info, err := (&gitDetector{}).Detect(repo)
+		require.NoError(t, err)
+		require.Empty(t, info.FetchURL)
+		require.Empty(t, info.PushURL)
+		require.Empty(t, info.RemoteURL)
+		require.Equal(t, filepath.Base(repo), info.RepoName)
+	})
+}
+
+func TestGitCommitGraph(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reports a commit-graph when present", func(t *testing.T) {
+		t.Parallel()
+		tmpDir := t.TempDir()
+
+		infoDir := filepath.Join(tmpDir, ".git", "objects", "info")
+		err := os.MkdirAll(infoDir, 0o755)
+		require.NoError(t, err)
+		err = os.WriteFile(filepath.Join(infoDir, "commit-graph"), nil, 0o644)
+		require.NoError(t, err)
+
+		info, err := (&gitDetector{}).Detect(tmpDir)
+		require.NoError(t, err)
+		require.True(t, info.Status.HasCommitGraph)
+	})
+
+	t.Run("does not report a commit-graph when missing", func(t *testing.T) {
+		t.Parallel()
+		tmpDir := t.TempDir()
+
+		err := os.MkdirAll(filepath.Join(tmpDir, ".git", "objects", "info"), 0o755)
+		require.NoError(t, err)
+
+		info, err := (&gitDetector{}).Detect(tmpDir)
+		require.NoError(t, err)
+		require.False(t, info.Status.HasCommitGraph)
+	})
+}
+
+func TestStatusTrafficLight(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		status Status
+		want   rune
+	}{
+		{name: "clean", status: Status{}, want: TrafficLightGreen},
+		{name: "ahead only", status: Status{AheadCount: 2, HasUnpushed: true}, want: TrafficLightGreen},
+		{name: "uncommitted", status: Status{HasUncommitted: true}, want: TrafficLightYellow},
+		{name: "staged", status: Status{HasStaged: true}, want: TrafficLightYellow},
+		{name: "untracked", status: Status{HasUntracked: true}, want: TrafficLightYellow},
+		{name: "conflicts win over dirty", status: Status{HasConflicts: true, HasUncommitted: true}, want: TrafficLightRed},
+		{name: "failure", status: Status{Failure: ErrVCSCommandFailed}, want: TrafficLightRed},
+		{name: "locked", status: Status{Locked: true}, want: TrafficLightYellow},
+		{name: "tool unavailable", status: Status{ToolUnavailable: true}, want: TrafficLightYellow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, tt.status.TrafficLight())
+		})
+	}
+}
+
+func TestStatusIsClean(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		status Status
+		want   bool
+	}{
+		{name: "clean", status: Status{CurrentBranch: "main", RemoteTrackingOK: true}, want: true},
+		{name: "stash doesn't count", status: Status{StashCount: 2}, want: true},
+		{name: "staged", status: Status{HasStaged: true, StagedCount: 1}, want: false},
+		{name: "uncommitted", status: Status{HasUncommitted: true}, want: false},
+		{name: "untracked", status: Status{HasUntracked: true}, want: false},
+		{name: "conflicts", status: Status{HasConflicts: true}, want: false},
+		{name: "detached", status: Status{IsDetached: true}, want: false},
+		{name: "ahead", status: Status{AheadCount: 1, HasUnpushed: true}, want: false},
+		{name: "behind", status: Status{BehindCount: 3}, want: false},
+		{name: "upstream gone", status: Status{CurrentBranch: "feature", UpstreamGone: true}, want: false},
+		{name: "locked", status: Status{Locked: true}, want: false},
+		{name: "tool unavailable", status: Status{ToolUnavailable: true}, want: false},
+		{name: "failed", status: Status{Failure: ErrVCSCommandFailed}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, tt.status.IsClean())
+		})
+	}
+}
+
+func TestStatusSummary(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		status Status
+		want   string
+	}{
+		{name: "clean", status: Status{CurrentBranch: "main"}, want: "clean"},
+		{
+			name:   "counts and ahead",
+			status: Status{HasStaged: true, StagedCount: 3, HasUntracked: true, UntrackedCount: 1, AheadCount: 2, HasUnpushed: true},
+			want:   "3 staged, 1 untracked, ↑2",
+		},
+		{
+			name:   "flags without counts",
+			status: Status{HasStaged: true, HasUncommitted: true},
+			want:   "staged, modified",
+		},
+		{
+			name:   "everything",
+			status: Status{HasConflicts: true, ConflictCount: 2, HasStaged: true, StagedCount: 1, HasUncommitted: true, ModifiedCount: 4, HasUntracked: true, UntrackedCount: 5, AheadCount: 1, BehindCount: 6},
+			want:   "2 conflicted, 1 staged, 4 modified, 5 untracked, ↑1, ↓6",
+		},
+		{name: "detached", status: Status{IsDetached: true, DetachedRef: "v1.0.0"}, want: "detached"},
+		{name: "behind", status: Status{BehindCount: 3}, want: "↓3"},
+		{name: "upstream gone", status: Status{HasUncommitted: true, UpstreamGone: true}, want: "modified, upstream gone"},
+		{name: "locked", status: Status{Locked: true, HasStaged: true}, want: "unknown"},
+		{name: "failed", status: Status{Failure: ErrVCSCommandFailed}, want: "unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, tt.status.Summary())
+			require.Equal(t, tt.want == "clean", tt.status.IsClean())
+		})
+	}
+}
+
+// commitFile writes name with content in repo and commits it.
+func commitFile(t *testing.T, repo, name, content string) {
+	t.Helper()
+	err := os.WriteFile(filepath.Join(repo, name), []byte(content), 0o644)
+	require.NoError(t, err)
+	runGit(t, repo, "add", name)
+	runGit(t, repo, "commit", "-m", "update "+name)
+}
+
+func TestRemoteHasTag(t *testing.T) {
+	t.Parallel()
+
+	const head = "1111111111111111111111111111111111111111"
+	const other = "2222222222222222222222222222222222222222"
+	lsRemote := other + "\trefs/tags/v1.0.0\n" +
+		head + "\trefs/tags/v1.0.0^{}\n" +
+		head + "\trefs/tags/v1.1.0\n"
+
+	require.True(t, remoteHasTag(lsRemote, "v1.0.0", head), "annotated tag via peeled ref")
+	require.True(t, remoteHasTag(lsRemote, "v1.1.0", head), "lightweight tag")
+	require.False(t, remoteHasTag(lsRemote, "v1.1.0", other), "tag at a different commit")
+	require.False(t, remoteHasTag(lsRemote, "v2.0.0", head), "tag missing from remote")
+	require.False(t, remoteHasTag("", "v1.0.0", head), "no remote tags")
+}
+
+func TestGitAtReleasedTag(t *testing.T) {
+	t.Parallel()
+
+	setup := func(t *testing.T) string {
+		remote := t.TempDir()
+		runGit(t, remote, "init", "--bare")
+		repo := initGitRepo(t)
+		runGit(t, repo, "remote", "add", "origin", remote)
+		commitFile(t, repo, "README.md", "hello")
+		runGit(t, repo, "tag", "-a", "v1.0.0", "-m", "release")
+		return repo
+	}
+	detector := NewDetectorWithOptions(DetectOptions{CheckReleasedTag: true})
+
+	t.Run("tag pushed to the remote", func(t *testing.T) {
+		t.Parallel()
+		repo := setup(t)
+		runGit(t, repo, "push", "origin", "v1.0.0")
+
+		info, err := detector.Detect(repo)
+		require.NoError(t, err)
+		require.True(t, info.Status.AtReleasedTag)
+	})
+
+	t.Run("tag only exists locally", func(t *testing.T) {
+		t.Parallel()
+		repo := setup(t)
+
+		info, err := detector.Detect(repo)
+		require.NoError(t, err)
+		require.False(t, info.Status.AtReleasedTag)
+	})
+
+	t.Run("not checked by default", func(t *testing.T) {
+		t.Parallel()
+		repo := setup(t)
+		runGit(t, repo, "push", "origin", "v1.0.0")
+
+		info, err := NewDetector().Detect(repo)
+		require.NoError(t, err)
+		require.False(t, info.Status.AtReleasedTag)
+	})
+}
+
+func TestGitIndexLock(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reports busy and skips working tree checks while locked", func(t *testing.T) {
+		t.Parallel()
+		repo := initGitRepo(t)
+		err := os.WriteFile(filepath.Join(repo, "untracked.txt"), []byte("new"), 0o644)
+		require.NoError(t, err)
+		err = os.WriteFile(filepath.Join(repo, ".git", "index.lock"), nil, 0o644)
+		require.NoError(t, err)
+
+		info, err := (&gitDetector{}).Detect(repo)
+		require.NoError(t, err)
+		require.True(t, info.Status.Locked)
+		require.False(t, info.Status.HasUntracked)
+		require.NotEmpty(t, info.Status.CurrentBranch)
+	})
+
+	t.Run("checks the working tree when unlocked", func(t *testing.T) {
+		t.Parallel()
+		repo := initGitRepo(t)
+		err := os.WriteFile(filepath.Join(repo, "untracked.txt"), []byte("new"), 0o644)
+		require.NoError(t, err)
+
+		info, err := (&gitDetector{}).Detect(repo)
+		require.NoError(t, err)
+		require.False(t, info.Status.Locked)
+		require.True(t, info.Status.HasUntracked)
+	})
+}
+
+func TestDetectOutermost(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns the outermost git repository", func(t *testing.T) {
+		t.Parallel()
+		outer := t.TempDir()
+		inner := filepath.Join(outer, "services", "api")
+		nested := filepath.Join(inner, "cmd")
+		require.NoError(t, os.Mkdir(filepath.Join(outer, ".git"), 0o755))
+		require.NoError(t, os.MkdirAll(filepath.Join(inner, ".git"), 0o755))
+		require.NoError(t, os.MkdirAll(nested, 0o755))
+
+		info, err := NewDetector().Detect(nested)
+		require.NoError(t, err)
+		require.Equal(t, inner, info.RootPath)
+
+		info, err = DetectOutermost(nested)
+		require.NoError(t, err)
+		require.Equal(t, TypeGit, info.Type)
+		require.Equal(t, outer, info.RootPath)
+		require.Equal(t, filepath.Base(outer), info.RepoName)
+	})
+
+	t.Run("returns the only repository when not nested", func(t *testing.T) {
+		t.Parallel()
+		tmpDir := t.TempDir()
+		require.NoError(t, os.Mkdir(filepath.Join(tmpDir, ".git"), 0o755))
+
+		info, err := DetectOutermost(tmpDir)
+		require.NoError(t, err)
+		require.Equal(t, tmpDir, info.RootPath)
+	})
+
+	t.Run("returns TypeNone when no repository found", func(t *testing.T) {
+		t.Parallel()
+		info, err := DetectOutermost(t.TempDir())
+		require.NoError(t, err)
+		require.Equal(t, TypeNone, info.Type)
+	})
+}
+
+func TestGitStashCount(t *testing.T) {
+	t.Parallel()
+
+	repo := initGitRepo(t)
+	commitFile(t, repo, "README.md", "hello")
+
+	info, err := (&gitDetector{}).Detect(repo)
+	require.NoError(t, err)
+	require.Zero(t, info.Status.StashCount)
+
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "README.md"), []byte("one"), 0o644))
+	runGit(t, repo, "stash")
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "README.md"), []byte("two"), 0o644))
+	runGit(t, repo, "stash")
+
+	info, err = (&gitDetector{}).Detect(repo)
+	require.NoError(t, err)
+	require.Equal(t, 2, info.Status.StashCount)
+}
+
+func TestGitTopStashDescription(t *testing.T) {
+	t.Parallel()
+
+	repo := initGitRepo(t)
+	commitFile(t, repo, "README.md", "hello")
+
+	info, err := (&gitDetector{}).Detect(repo)
+	require.NoError(t, err)
+	require.Empty(t, info.Status.TopStashDescription)
+
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "README.md"), []byte("one"), 0o644))
+	runGit(t, repo, "stash", "push", "-m", "older experiment")
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "README.md"), []byte("two"), 0o644))
+	runGit(t, repo, "stash", "push", "-m", "half-done: refactor")
+
+	info, err = (&gitDetector{}).Detect(repo)
+	require.NoError(t, err)
+	require.Equal(t, "half-done: refactor", info.Status.TopStashDescription)
+}
+
+func TestParseStashDescription(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{name: "named", output: "stash@{0}: On main: try new parser\nstash@{1}: On main: older", want: "try new parser"},
+		{name: "unnamed", output: "stash@{0}: WIP on main: abc1234 Add tests", want: "WIP on main: abc1234 Add tests"},
+		{name: "empty", output: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, parseStashDescription(tt.output))
+		})
+	}
+}
+
+func TestParseJujutsuEmpty(t *testing.T) {
+	t.Parallel()
+
+	require.True(t, parseJujutsuEmpty("true"))
+	require.True(t, parseJujutsuEmpty("true\n"))
+	require.False(t, parseJujutsuEmpty("false"))
+	require.False(t, parseJujutsuEmpty(""))
+}
+
+func TestGitNothingToCommit(t *testing.T) {
+	t.Parallel()
+
+	repo := initGitRepo(t)
+	commitFile(t, repo, "README.md", "hello")
+
+	info, err := (&gitDetector{}).Detect(repo)
+	require.NoError(t, err)
+	require.True(t, info.Status.NothingToCommit)
+
+	t.Run("modified", func(t *testing.T) {
+		t.Parallel()
+		repo := initGitRepo(t)
+		commitFile(t, repo, "README.md", "hello")
+		require.NoError(t, os.WriteFile(filepath.Join(repo, "README.md"), []byte("changed"), 0o644))
+
+		info, err := (&gitDetector{}).Detect(repo)
+		require.NoError(t, err)
+		require.False(t, info.Status.NothingToCommit)
+	})
+
+	t.Run("staged", func(t *testing.T) {
+		t.Parallel()
+		repo := initGitRepo(t)
+		commitFile(t, repo, "README.md", "hello")
+		require.NoError(t, os.WriteFile(filepath.Join(repo, "new.txt"), []byte("new"), 0o644))
+		runGit(t, repo, "add", "new.txt")
+
+		info, err := (&gitDetector{}).Detect(repo)
+		require.NoError(t, err)
+		require.False(t, info.Status.NothingToCommit)
+	})
+
+	t.Run("untracked", func(t *testing.T) {
+		t.Parallel()
+		repo := initGitRepo(t)
+		commitFile(t, repo, "README.md", "hello")
+		require.NoError(t, os.WriteFile(filepath.Join(repo, "new.txt"), []byte("new"), 0o644))
+
+		info, err := (&gitDetector{}).Detect(repo)
+		require.NoError(t, err)
+		require.False(t, info.Status.NothingToCommit)
+	})
+}
+
+func TestParseJujutsuDivergent(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		output string
+		want   bool
+	}{
+		{name: "divergent change listed twice", output: "qpvuntsm\nqpvuntsm\n", want: true},
+		{name: "no divergent changes", output: "", want: false},
+		{name: "blank lines only", output: "\n\n", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, parseJujutsuDivergent(tt.output))
+		})
+	}
+}
+
+func TestParseJujutsuParentDescription(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{name: "empty @ on top of described parent", output: "Add login form\n", want: "Add login form"},
+		{name: "parent without description", output: "\n", want: ""},
+		{name: "merge uses first described parent", output: "\nFix flaky test\nBump deps\n", want: "Fix flaky test"},
+		{name: "no output", output: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, parseJujutsuParentDescription(tt.output))
+		})
+	}
+}
+
+func TestGitHooks(t *testing.T) {
+	t.Parallel()
+
+	setup := func(t *testing.T, hooks map[string]os.FileMode) string {
+		tmpDir := t.TempDir()
+		hooksDir := filepath.Join(tmpDir, ".git", "hooks")
+		require.NoError(t, os.MkdirAll(hooksDir, 0o755))
+		for name, mode := range hooks {
+			require.NoError(t, os.WriteFile(filepath.Join(hooksDir, name), []byte("#!/bin/sh\n"), mode))
+		}
+		return tmpDir
+	}
+
+	t.Run("reports an executable hook", func(t *testing.T) {
+		t.Parallel()
+		repo := setup(t, map[string]os.FileMode{
+			"pre-commit":        0o755,
+			"pre-push.sample":   0o755,
+			"commit-msg.sample": 0o755,
+		})
+
+		info, err := (&gitDetector{}).Detect(repo)
+		require.NoError(t, err)
+		require.True(t, info.Status.HasHooks)
+	})
+
+	t.Run("ignores sample hooks", func(t *testing.T) {
+		t.Parallel()
+		repo := setup(t, map[string]os.FileMode{
+			"pre-commit.sample": 0o755,
+			"pre-push.sample":   0o755,
+		})
+
+		info, err := (&gitDetector{}).Detect(repo)
+		require.NoError(t, err)
+		require.False(t, info.Status.HasHooks)
+	})
+
+	t.Run("ignores non-executable hooks", func(t *testing.T) {
+		t.Parallel()
+		repo := setup(t, map[string]os.FileMode{"pre-commit": 0o644})
+
+		info, err := (&gitDetector{}).Detect(repo)
+		require.NoError(t, err)
+		require.False(t, info.Status.HasHooks)
+	})
+}
+
+func TestGitRunNoOptionalLocks(t *testing.T) {
+	t.Parallel()
+
+	run := &fakeRunner{}
+	_, _ = gitRun(t.Context(), run, "/repo", "diff", "--quiet")
+	require.Equal(t, []string{"/repo: git --no-optional-locks diff --quiet"}, run.calls)
+}
+
+func TestGitMergeToolAndEditor(t *testing.T) {
+	t.Parallel()
+
+	withToolConfig := &gitDetector{opts: DetectOptions{ReadToolConfig: true}}
+
+	t.Run("reads configured values", func(t *testing.T) {
+		t.Parallel()
+		repo := initGitRepo(t)
+		runGit(t, repo, "config", "merge.tool", "vimdiff")
+		runGit(t, repo, "config", "core.editor", "nvim -f")
+
+		info, err := withToolConfig.Detect(repo)
+		require.NoError(t, err)
+		require.Equal(t, "vimdiff", info.MergeTool)
+		require.Equal(t, "nvim -f", info.Editor)
+	})
+
+	t.Run("empty when unset", func(t *testing.T) {
+		t.Parallel()
+		repo := initGitRepo(t)
+		// Shadow any global or system config for this check.
+		runGit(t, repo, "config", "merge.tool", "")
+		runGit(t, repo, "config", "core.editor", "")
+
+		info, err := withToolConfig.Detect(repo)
+		require.NoError(t, err)
+		require.Empty(t, info.MergeTool)
+		require.Empty(t, info.Editor)
+	})
+
+	t.Run("not read by default", func(t *testing.T) {
+		t.Parallel()
+		repo := fakeGitRepo(t, "ref: refs/heads/main")
+		run := &fakeRunner{outputs: map[string]string{
+			fakePorcelainCmd: "# branch.oid 1111111111111111111111111111111111111111\x00# branch.head main\x00",
+		}}
+
+		_, err := (&gitDetector{run: run}).Detect(repo)
+		require.NoError(t, err)
+		for _, call := range run.calls {
+			require.NotContains(t, call, "merge.tool")
+			require.NotContains(t, call, "core.editor")
+		}
+	})
+}
+
+func TestGitDir(t *testing.T) {
+	t.Parallel()
+
+	repo := initGitRepo(t)
+	commitFile(t, repo, "README.md", "hello")
+	sub := filepath.Join(repo, "pkg", "sub")
+	require.NoError(t, os.MkdirAll(sub, 0o755))
+
+	root, gitDir, ok := GitDir(sub)
+	require.True(t, ok)
+	require.Equal(t, repo, root)
+	require.Equal(t, filepath.Join(repo, ".git"), gitDir)
+
+	t.Run("worktree", func(t *testing.T) {
+		t.Parallel()
+		wt := filepath.Join(t.TempDir(), "wt")
+		runGit(t, repo, "worktree", "add", "-b", "wt", wt)
+
+		root, gitDir, ok := GitDir(wt)
+		require.True(t, ok)
+		require.Equal(t, wt, root)
+		require.Equal(t, filepath.Join(repo, ".git", "worktrees", "wt"), gitDir)
+	})
+
+	t.Run("not a repository", func(t *testing.T) {
+		t.Parallel()
+		_, _, ok := GitDir(t.TempDir())
+		require.False(t, ok)
+	})
+}
+
+func TestIsProtectedBranch(t *testing.T) {
+	t.Parallel()
+
+	custom := []string{"develop", "hotfix/*"}
+	tests := []struct {
+		branch   string
+		patterns []string
+		want     bool
+	}{
+		{"main", DefaultProtectedBranches, true},
+		{"master", DefaultProtectedBranches, true},
+		{"release/1.2", DefaultProtectedBranches, true},
+		{"release/1.2/rc", DefaultProtectedBranches, false},
+		{"feature/login", DefaultProtectedBranches, false},
+		{"maintenance", DefaultProtectedBranches, false},
+		{"", DefaultProtectedBranches, false},
+		{"develop", custom, true},
+		{"hotfix/crash", custom, true},
+		{"main", custom, false},
+		{"main", []string{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s in %v", tt.branch, tt.patterns), func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, isProtectedBranch(tt.branch, tt.patterns))
+		})
+	}
+}
+
+func TestGitOnProtectedBranch(t *testing.T) {
+	t.Parallel()
+
+	repo := initGitRepo(t)
+	commitFile(t, repo, "README.md", "hello")
+	runGit(t, repo, "checkout", "-b", "release/2.0")
+
+	info, err := NewDetector().Detect(repo)
+	require.NoError(t, err)
+	require.True(t, info.Status.OnProtectedBranch)
+
+	info, err = NewDetectorWithOptions(DetectOptions{ProtectedBranches: []string{"main"}}).Detect(repo)
+	require.NoError(t, err)
+	require.False(t, info.Status.OnProtectedBranch)
+}
+
+func TestGitBranchDescription(t *testing.T) {
+	t.Parallel()
+
+	repo := initGitRepo(t)
+	commitFile(t, repo, "README.md", "hello")
+	runGit(t, repo, "checkout", "-b", "feature/login")
+
+	info, err := (&gitDetector{}).Detect(repo)
+	require.NoError(t, err)
+	require.Empty(t, info.Status.BranchDescription)
+
+	runGit(t, repo, "config", "branch.feature/login.description", "Login form behind the new auth flag")
+
+	info, err = (&gitDetector{}).Detect(repo)
+	require.NoError(t, err)
+	require.Equal(t, "Login form behind the new auth flag", info.Status.BranchDescription)
+}
+
+func TestGitAuthorCount(t *testing.T) {
+	t.Parallel()
+
+	repo := initGitRepo(t)
+	commitFile(t, repo, "README.md", "hello")
+	runGit(t, repo, "branch", "-M", "main")
+	runGit(t, repo, "checkout", "-b", "feature")
+
+	for i, author := range []string{"Alice <alice@example.com>", "Bob <bob@example.com>", "Alice <alice@example.com>"} {
+		name := fmt.Sprintf("file%d.txt", i)
+		require.NoError(t, os.WriteFile(filepath.Join(repo, name), []byte(name), 0o644))
+		runGit(t, repo, "add", name)
+		runGit(t, repo, "commit", "-m", "Add "+name, "--author", author)
+	}
+
+	info, err := NewDetectorWithOptions(DetectOptions{CountAuthors: true}).Detect(repo)
+	require.NoError(t, err)
+	require.Equal(t, 2, info.Status.AuthorCount)
+
+	info, err = NewDetector().Detect(repo)
+	require.NoError(t, err)
+	require.Zero(t, info.Status.AuthorCount, "only counted when opted in")
+}
+
+func TestDefaultBranchRef(t *testing.T) {
+	t.Parallel()
+
+	t.Run("unborn repository uses init.defaultBranch", func(t *testing.T) {
+		t.Parallel()
+		repo := initGitRepo(t)
+		runGit(t, repo, "config", "init.defaultBranch", "trunk")
+		require.Equal(t, "trunk", defaultBranchRef(t.Context(), osRunner{}, repo))
+	})
+
+	t.Run("existing init.defaultBranch wins over main", func(t *testing.T) {
+		t.Parallel()
+		repo := initGitRepo(t)
+		commitFile(t, repo, "README.md", "hello")
+		runGit(t, repo, "branch", "-M", "trunk")
+		runGit(t, repo, "branch", "main")
+		runGit(t, repo, "config", "init.defaultBranch", "trunk")
+		require.Equal(t, "trunk", defaultBranchRef(t.Context(), osRunner{}, repo))
+	})
+
+	t.Run("falls back to main", func(t *testing.T) {
+		t.Parallel()
+		repo := initGitRepo(t)
+		commitFile(t, repo, "README.md", "hello")
+		runGit(t, repo, "branch", "-M", "main")
+		runGit(t, repo, "config", "init.defaultBranch", "develop")
+		require.Equal(t, "main", defaultBranchRef(t.Context(), osRunner{}, repo))
+	})
+}
+
+func TestRepoRootFor(t *testing.T) {
+	t.Parallel()
+
+	t.Run("file deep inside a git repository", func(t *testing.T) {
+		t.Parallel()
+		repo := initGitRepo(t)
+		dir := filepath.Join(repo, "internal", "pkg", "deep")
+		require.NoError(t, os.MkdirAll(dir, 0o755))
+		file := filepath.Join(dir, "file.go")
+		require.NoError(t, os.WriteFile(file, []byte("package deep"), 0o644))
+
+		root, typ, ok := RepoRootFor(file)
+		require.True(t, ok)
+		require.Equal(t, repo, root)
+		require.Equal(t, TypeGit, typ)
+	})
+
+	t.Run("worktree with a .git file", func(t *testing.T) {
+		t.Parallel()
+		repo := initGitRepo(t)
+		commitFile(t, repo, "README.md", "hello")
+		wt := filepath.Join(t.TempDir(), "wt")
+		runGit(t, repo, "worktree", "add", "-b", "wt", wt)
+
+		root, typ, ok := RepoRootFor(filepath.Join(wt, "README.md"))
+		require.True(t, ok)
+		require.Equal(t, wt, root)
+		require.Equal(t, TypeGit, typ)
+	})
+
+	t.Run("jujutsu", func(t *testing.T) {
+		t.Parallel()
+		repo := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(repo, ".jj"), 0o755))
+		require.NoError(t, os.MkdirAll(filepath.Join(repo, "src"), 0o755))
+
+		root, typ, ok := RepoRootFor(filepath.Join(repo, "src"))
+		require.True(t, ok)
+		require.Equal(t, repo, root)
+		require.Equal(t, TypeJujutsu, typ)
+	})
+
+	t.Run("no repository", func(t *testing.T) {
+		t.Parallel()
+		root, typ, ok := RepoRootFor(t.TempDir())
+		require.False(t, ok)
+		require.Empty(t, root)
+		require.Equal(t, TypeNone, typ)
+	})
+}
+
+func TestGitUnbornHead(t *testing.T) {
+	t.Parallel()
+
+	repo := initGitRepo(t)
+	runGit(t, repo, "symbolic-ref", "HEAD", "refs/heads/trunk")
+
+	info, err := NewDetector().Detect(repo)
+	require.NoError(t, err)
+	require.True(t, info.Status.IsUnborn)
+	require.Equal(t, "trunk", info.Status.CurrentBranch)
+	require.False(t, info.Status.IsDetached)
+	require.False(t, info.Status.RemoteTrackingOK)
+
+	commitFile(t, repo, "a.txt", "a")
+	info, err = NewDetector().Detect(repo)
+	require.NoError(t, err)
+	require.False(t, info.Status.IsUnborn)
+}
+
+func TestGitDetachedAtTag(t *testing.T) {
+	t.Parallel()
+
+	repo := initGitRepo(t)
+	commitFile(t, repo, "a.txt", "a")
+	runGit(t, repo, "tag", "-a", "v1.2.3", "-m", "Release 1.2.3")
+	commitFile(t, repo, "b.txt", "b")
+	next := strings.TrimSpace(runGit(t, repo, "rev-parse", "--short", "HEAD"))
+
+	runGit(t, repo, "checkout", "--detach", "v1.2.3")
+	info, err := NewDetector().Detect(repo)
+	require.NoError(t, err)
+	require.True(t, info.Status.IsDetached)
+	require.Equal(t, "v1.2.3", info.Status.DetachedRef)
+	require.Empty(t, info.Status.CurrentBranch)
+
+	runGit(t, repo, "checkout", "--detach", next)
+	info, err = NewDetector().Detect(repo)
+	require.NoError(t, err)
+	require.True(t, info.Status.IsDetached)
+	require.Equal(t, "v1.2.3-1-g"+next, info.Status.DetachedRef)
+}
+
+func TestGitDetachedPullRequest(t *testing.T) {
+	t.Parallel()
+
+	repo := initGitRepo(t)
+	commitFile(t, repo, "a.txt", "a")
+	head := strings.TrimSpace(runGit(t, repo, "rev-parse", "HEAD"))
+	runGit(t, repo, "checkout", "--detach", "HEAD")
+
+	fetchHead := head + "\t\t'refs/pull/42/head' of github.com:owner/repo\n"
+	require.NoError(t, os.WriteFile(filepath.Join(repo, ".git", "FETCH_HEAD"), []byte(fetchHead), 0o644))
+
+	info, err := (&gitDetector{}).Detect(repo)
+	require.NoError(t, err)
+	require.True(t, info.Status.IsDetached)
+	require.Equal(t, "PR #42", info.Status.DetachedRef)
+}
+
+func TestPRRefFromFetchHead(t *testing.T) {
+	t.Parallel()
+
+	const commit = "0123456789abcdef0123456789abcdef01234567"
+	tests := []struct {
+		name      string
+		fetchHead string
+		want      string
+	}{
+		{"pull head", commit + "\t\t'refs/pull/7/head' of https://github.com/o/r\n", "PR #7"},
+		{"not for merge", commit + "\tnot-for-merge\tbranch 'refs/pull/12/head' of github.com:o/r\n", "PR #12"},
+		{"other commit", "fedcba9876543210fedcba9876543210fedcba98\t\t'refs/pull/7/head' of o/r\n", ""},
+		{"branch fetch", commit + "\t\tbranch 'main' of github.com:o/r\n", ""},
+		{"non-numeric", commit + "\t\t'refs/pull/abc/head' of o/r\n", ""},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, prRefFromFetchHead(tt.fetchHead, commit))
+		})
+	}
+}
+
+func TestGitDetachedHead(t *testing.T) {
+	t.Parallel()
+
+	repo := initGitRepo(t)
+	commitFile(t, repo, "README.md", "hello")
+	runGit(t, repo, "checkout", "--detach")
+
+	info, err := (&gitDetector{}).Detect(repo)
+	require.NoError(t, err)
+	require.True(t, info.Status.IsDetached)
+	require.Equal(t, "", info.Status.CurrentBranch)
+	require.NotEmpty(t, info.Status.DetachedRef)
+
+	short := strings.TrimSpace(runGit(t, repo, "rev-parse", "--short", "HEAD"))
+	require.Equal(t, short, info.Status.DetachedRef)
+}
+
+func TestStatusDiff(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no changes", func(t *testing.T) {
+		t.Parallel()
+		s := Status{CurrentBranch: "main", AheadCount: 2}
+		require.Empty(t, s.Diff(s))
+	})
+
+	t.Run("lists exactly the changed fields", func(t *testing.T) {
+		t.Parallel()
+		before := Status{CurrentBranch: "main", AheadCount: 2, HasStaged: true}
+		after := Status{CurrentBranch: "dev", AheadCount: 3, HasStaged: true, HasUncommitted: true}
+		require.Equal(t, []string{
+			"HasUncommitted false→true",
+			"AheadCount 2→3",
+			`CurrentBranch "main"→"dev"`,
+		}, before.Diff(after))
+	})
+}
+
+func TestGitRefreshIndex(t *testing.T) {
+	t.Parallel()
+
+	repo := initGitRepo(t)
+	commitFile(t, repo, "README.md", "hello")
+
+	// Change the mtime without changing the content so the index is stale.
+	stale := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(repo, "README.md"), stale, stale))
+	cmd := exec.Command("git", "diff-files", "--quiet")
+	cmd.Dir = repo
+	require.Error(t, cmd.Run(), "index should be stale before detection")
+
+	info, err := NewDetectorWithOptions(DetectOptions{RefreshIndex: true}).Detect(repo)
+	require.NoError(t, err)
+	require.False(t, info.Status.HasUncommitted)
+
+	cmd = exec.Command("git", "diff-files", "--quiet")
+	cmd.Dir = repo
+	require.NoError(t, cmd.Run(), "index should be refreshed by detection")
+}
+
+func TestGitCountFiles(t *testing.T) {
+	t.Parallel()
+
+	repo := initGitRepo(t)
+	for _, name := range []string{"conflict.txt", "a.txt", "b.txt", "c.txt"} {
+		commitFile(t, repo, name, "base\n")
+	}
+	base := strings.TrimSpace(runGit(t, repo, "symbolic-ref", "--short", "HEAD"))
+
+	runGit(t, repo, "checkout", "-b", "other")
+	commitFile(t, repo, "conflict.txt", "other\n")
+	runGit(t, repo, "checkout", base)
+	commitFile(t, repo, "conflict.txt", "main\n")
+
+	cmd := exec.Command("git", "merge", "other")
+	cmd.Dir = repo
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Crush Test",
+		"GIT_AUTHOR_EMAIL=crush@example.com",
+		"GIT_COMMITTER_NAME=Crush Test",
+		"GIT_COMMITTER_EMAIL=crush@example.com",
+	)
+	output, err := cmd.CombinedOutput()
+	require.Error(t, err)
+	require.Contains(t, string(output), "CONFLICT")
+
+	// Two staged files and one modified, unstaged file.
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "a.txt"), []byte("staged\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "b.txt"), []byte("staged\n"), 0o644))
+	runGit(t, repo, "add", "a.txt", "b.txt")
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "c.txt"), []byte("modified\n"), 0o644))
+
+	info, err := NewDetectorWithOptions(DetectOptions{CountFiles: true}).Detect(repo)
+	require.NoError(t, err)
+	require.True(t, info.Status.HasConflicts)
+	require.True(t, info.Status.HasStaged)
+	require.True(t, info.Status.HasUncommitted)
+	require.Equal(t, 1, info.Status.ConflictCount)
+	require.Equal(t, 2, info.Status.StagedCount)
+	require.Equal(t, 1, info.Status.ModifiedCount)
+
+	t.Run("legacy path agrees with porcelain v2", func(t *testing.T) {
+		t.Parallel()
+		legacy := Status{CurrentBranch: info.Status.CurrentBranch}
+		getGitWorkingTreeLegacy(t.Context(), osRunner{}, repo, DetectOptions{CountFiles: true}, &legacy)
+		require.Equal(t, info.Status.HasConflicts, legacy.HasConflicts)
+		require.Equal(t, info.Status.HasStaged, legacy.HasStaged)
+		require.Equal(t, info.Status.HasUncommitted, legacy.HasUncommitted)
+		require.Equal(t, info.Status.HasUntracked, legacy.HasUntracked)
+		require.Equal(t, info.Status.ConflictCount, legacy.ConflictCount)
+		require.Equal(t, info.Status.StagedCount, legacy.StagedCount)
+		require.Equal(t, info.Status.ModifiedCount, legacy.ModifiedCount)
+		require.Equal(t, info.Status.RemoteTrackingOK, legacy.RemoteTrackingOK)
+	})
+
+	t.Run("legacy path only counts conflicts by default", func(t *testing.T) {
+		t.Parallel()
+		var legacy Status
+		getGitWorkingTreeLegacy(t.Context(), osRunner{}, repo, DetectOptions{}, &legacy)
+		require.True(t, legacy.HasConflicts)
+		require.Equal(t, 1, legacy.ConflictCount)
+		require.Zero(t, legacy.StagedCount)
+		require.Zero(t, legacy.ModifiedCount)
+	})
+}
+
+func TestGitFileCounts(t *testing.T) {
+	t.Parallel()
+
+	repo := initGitRepo(t)
+	for _, name := range []string{"a.txt", "b.txt", "c.txt", "d.txt"} {
+		commitFile(t, repo, name, "base\n")
+	}
+
+	// a.txt is staged, b.txt staged and modified again, c.txt and d.txt
+	// modified, plus two untracked files.
+	for _, name := range []string{"a.txt", "b.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(repo, name), []byte("staged\n"), 0o644))
+	}
+	runGit(t, repo, "add", "a.txt", "b.txt")
+	for _, name := range []string{"b.txt", "c.txt", "d.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(repo, name), []byte("modified\n"), 0o644))
+	}
+	for _, name := range []string{"new1.txt", "new2.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(repo, name), []byte("new\n"), 0o644))
+	}
+
+	info, err := NewDetector().Detect(repo)
+	require.NoError(t, err)
+	require.Equal(t, 2, info.Status.StagedCount)
+	require.Equal(t, 3, info.Status.ModifiedCount)
+	require.Equal(t, 2, info.Status.UntrackedCount)
+	require.Zero(t, info.Status.ConflictCount)
+	require.True(t, info.Status.HasStaged)
+	require.True(t, info.Status.HasUncommitted)
+	require.True(t, info.Status.HasUntracked)
+	require.False(t, info.Status.HasConflicts)
+
+	var legacy Status
+	getGitWorkingTreeLegacy(t.Context(), osRunner{}, repo, DetectOptions{CountFiles: true}, &legacy)
+	require.Equal(t, info.Status.StagedCount, legacy.StagedCount)
+	require.Equal(t, info.Status.ModifiedCount, legacy.ModifiedCount)
+	require.Equal(t, info.Status.UntrackedCount, legacy.UntrackedCount)
+}
+
+func TestGitUpstreamGone(t *testing.T) {
+	t.Parallel()
+
+	repo := initGitRepo(t)
+	commitFile(t, repo, "a.txt", "a")
+	branch := strings.TrimSpace(runGit(t, repo, "symbolic-ref", "--short", "HEAD"))
+
+	check := func(wantTracking, wantGone bool) {
+		t.Helper()
+		info, err := NewDetector().Detect(repo)
+		require.NoError(t, err)
+		require.Equal(t, wantTracking, info.Status.RemoteTrackingOK)
+		require.Equal(t, wantGone, info.Status.UpstreamGone)
+
+		legacy := Status{CurrentBranch: branch}
+		getGitWorkingTreeLegacy(t.Context(), osRunner{}, repo, DetectOptions{}, &legacy)
+		require.Equal(t, wantTracking, legacy.RemoteTrackingOK)
+		require.Equal(t, wantGone, legacy.UpstreamGone)
+	}
+
+	// No upstream configured.
+	check(false, false)
+
+	runGit(t, repo, "remote", "add", "origin", "https://github.com/me/crush.git")
+	runGit(t, repo, "update-ref", "refs/remotes/origin/"+branch, "HEAD")
+	runGit(t, repo, "branch", "--set-upstream-to=origin/"+branch)
+	check(true, false)
+
+	// The branch was deleted on the remote and pruned locally.
+	runGit(t, repo, "update-ref", "-d", "refs/remotes/origin/"+branch)
+	check(false, true)
+}
+
+func TestGitConflictCount(t *testing.T) {
+	t.Parallel()
+
+	repo := initGitRepo(t)
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		commitFile(t, repo, name, "base\n")
+	}
+	base := strings.TrimSpace(runGit(t, repo, "symbolic-ref", "--short", "HEAD"))
+
+	runGit(t, repo, "checkout", "-b", "other")
+	commitFile(t, repo, "a.txt", "other\n")
+	commitFile(t, repo, "b.txt", "other\n")
+	runGit(t, repo, "checkout", base)
+	commitFile(t, repo, "a.txt", "main\n")
+	commitFile(t, repo, "b.txt", "main\n")
+
+	cmd := exec.Command("git", "merge", "other")
+	cmd.Dir = repo
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Crush Test",
+		"GIT_AUTHOR_EMAIL=crush@example.com",
+		"GIT_COMMITTER_NAME=Crush Test",
+		"GIT_COMMITTER_EMAIL=crush@example.com",
+	)
+	output, err := cmd.CombinedOutput()
+	require.Error(t, err)
+	require.Contains(t, string(output), "CONFLICT")
+
+	info, err := NewDetector().Detect(repo)
+	require.NoError(t, err)
+	require.True(t, info.Status.HasConflicts)
+	require.Equal(t, 2, info.Status.ConflictCount)
+
+	var legacy Status
+	getGitWorkingTreeLegacy(t.Context(), osRunner{}, repo, DetectOptions{}, &legacy)
+	require.True(t, legacy.HasConflicts)
+	require.Equal(t, 2, legacy.ConflictCount)
+
+	runGit(t, repo, "add", "a.txt")
+	info, err = NewDetector().Detect(repo)
+	require.NoError(t, err)
+	require.Equal(t, 1, info.Status.ConflictCount)
+}
+
+func TestGitLastCommitTimes(t *testing.T) {
+	t.Parallel()
+
+	repo := initGitRepo(t)
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "README.md"), []byte("hello"), 0o644))
+	runGit(t, repo, "add", "README.md")
+
+	cmd := exec.Command("git", "commit", "-m", "rebased commit")
+	cmd.Dir = repo
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Crush Test",
+		"GIT_AUTHOR_EMAIL=crush@example.com",
+		"GIT_COMMITTER_NAME=Crush Test",
+		"GIT_COMMITTER_EMAIL=crush@example.com",
+		"GIT_AUTHOR_DATE=2024-01-02T03:04:05Z",
+		"GIT_COMMITTER_DATE=2024-02-03T04:05:06Z",
+	)
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(output))
+
+	info, err := (&gitDetector{}).Detect(repo)
+	require.NoError(t, err)
+	require.True(t, info.Status.LastCommitAuthorTime.Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)))
+	require.True(t, info.Status.LastCommitCommitTime.Equal(time.Date(2024, 2, 3, 4, 5, 6, 0, time.UTC)))
+}
+
+func TestParseCommitTimes(t *testing.T) {
+	t.Parallel()
+
+	author, committer := parseCommitTimes("2024-01-02T03:04:05+01:00\x002024-02-03T04:05:06-05:00\n")
+	require.True(t, author.Equal(time.Date(2024, 1, 2, 2, 4, 5, 0, time.UTC)))
+	require.True(t, committer.Equal(time.Date(2024, 2, 3, 9, 5, 6, 0, time.UTC)))
+
+	author, committer = parseCommitTimes("")
+	require.True(t, author.IsZero())
+	require.True(t, committer.IsZero())
+}
+
+func TestGitLastCommit(t *testing.T) {
+	t.Parallel()
+
+	repo := initGitRepo(t)
+	opts := DetectOptions{ReadLastCommit: true}
+
+	info, err := DetectWithOptions(repo, opts)
+	require.NoError(t, err)
+	require.True(t, info.Status.IsUnborn)
+	require.Nil(t, info.LastCommit)
+
+	commitFile(t, repo, "a.txt", "a")
+	info, err = DetectWithOptions(repo, opts)
+	require.NoError(t, err)
+	require.NotNil(t, info.LastCommit)
+	require.Equal(t, strings.TrimSpace(runGit(t, repo, "rev-parse", "--short", "HEAD")), info.LastCommit.ShortSHA)
+	require.Equal(t, "update a.txt", info.LastCommit.Subject)
+	require.Equal(t, "Crush Test", info.LastCommit.AuthorName)
+	require.True(t, info.LastCommit.When.Equal(info.Status.LastCommitAuthorTime))
+
+	info, err = NewDetector().Detect(repo)
+	require.NoError(t, err)
+	require.Nil(t, info.LastCommit, "not read unless asked for")
+
+	info, err = DetectWithOptions(repo, DetectOptions{ReadLastCommit: true, BranchOnly: true})
+	require.NoError(t, err)
+	require.Nil(t, info.LastCommit, "skipped in branch-only mode")
+}
+
+func TestParseLastCommit(t *testing.T) {
+	t.Parallel()
+
+	commit := parseLastCommit("abc1234\x00Ada Lovelace\x002024-01-02T03:04:05+01:00\x00fix: handle empty input\n")
+	require.Equal(t, &CommitInfo{
+		ShortSHA:   "abc1234",
+		Subject:    "fix: handle empty input",
+		AuthorName: "Ada Lovelace",
+		When:       commit.When,
+	}, commit)
+	require.True(t, commit.When.Equal(time.Date(2024, 1, 2, 2, 4, 5, 0, time.UTC)))
+
+	require.Nil(t, parseLastCommit(""))
+	require.Nil(t, parseLastCommit("abc1234\x00Ada"))
+}
+
+func TestGitFetchStale(t *testing.T) {
+	t.Parallel()
+
+	repo := initGitRepo(t)
+	commitFile(t, repo, "a.txt", "a")
+
+	info, err := (&gitDetector{}).Detect(repo)
+	require.NoError(t, err)
+	require.True(t, info.Status.LastFetchTime.IsZero(), "never fetched")
+	require.False(t, info.Status.FetchStale)
+
+	fetchHead := filepath.Join(repo, ".git", "FETCH_HEAD")
+	require.NoError(t, os.WriteFile(fetchHead, nil, 0o644))
+
+	tests := []struct {
+		name       string
+		age        time.Duration
+		staleAfter time.Duration
+		want       bool
+	}{
+		{"fresh", time.Hour, 0, false},
+		{"stale", 2 * DefaultFetchStaleAfter, 0, true},
+		{"stale with custom age", 2 * time.Hour, time.Hour, true},
+		{"fresh with custom age", 2 * time.Hour, 3 * time.Hour, false},
+	}
+	for _, tt := range tests {
+		fetched := time.Now().Add(-tt.age)
+		require.NoError(t, os.Chtimes(fetchHead, fetched, fetched))
+
+		info, err := (&gitDetector{opts: DetectOptions{FetchStaleAfter: tt.staleAfter}}).Detect(repo)
+		require.NoError(t, err, tt.name)
+		require.WithinDuration(t, fetched, info.Status.LastFetchTime, time.Second, tt.name)
+		require.Equal(t, tt.want, info.Status.FetchStale, tt.name)
+	}
+}
+
+func TestGitAheadBehindMultiDigit(t *testing.T) {
+	t.Parallel()
+
+	repo := initGitRepo(t)
+	commitFile(t, repo, "base.txt", "base")
+	runGit(t, repo, "branch", "-M", "main")
+	runGit(t, repo, "branch", "upstream")
+
+	for i := range 12 {
+		commitFile(t, repo, fmt.Sprintf("local%d.txt", i), "local")
+	}
+	runGit(t, repo, "checkout", "upstream")
+	for i := range 10 {
+		commitFile(t, repo, fmt.Sprintf("remote%d.txt", i), "remote")
+	}
+	runGit(t, repo, "checkout", "main")
+	runGit(t, repo, "branch", "--set-upstream-to", "upstream")
+
+	info, err := NewDetector().Detect(repo)
+	require.NoError(t, err)
+	require.True(t, info.Status.RemoteTrackingOK)
+	require.Equal(t, 12, info.Status.AheadCount)
+	require.Equal(t, 10, info.Status.BehindCount)
+	require.True(t, info.Status.HasUnpushed)
+}
+
+func TestParseAheadBehind(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		output        string
+		ahead, behind int
+	}{
+		{"12\t5\n", 12, 5},
+		{"0\t0\n", 0, 0},
+		{"0\t103\n", 0, 103},
+		{"", 0, 0},
+		{"x\t1\n", 0, 0},
+	}
+	for _, tt := range tests {
+		ahead, behind := parseAheadBehind(tt.output)
+		require.Equal(t, tt.ahead, ahead, tt.output)
+		require.Equal(t, tt.behind, behind, tt.output)
+	}
+}
+
+func TestDetectContextCancelled(t *testing.T) {
+	t.Parallel()
+
+	repo := initGitRepo(t)
+	commitFile(t, repo, "a.txt", "a")
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	info, err := NewDetector().DetectContext(ctx, repo)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, TypeGit, info.Type, "partial info keeps what was found without running git")
+	require.Equal(t, repo, info.RootPath)
+	require.Zero(t, info.Status.LastCommitCommitTime, "git commands don't run once cancelled")
+
+	info, err = NewDetector().DetectContext(t.Context(), repo)
+	require.NoError(t, err)
+	require.NotZero(t, info.Status.LastCommitCommitTime)
+}
+
+func TestGitDetectorWorktreeAndSubmoduleRoots(t *testing.T) {
+	t.Parallel()
+
+	repo := initGitRepo(t)
+	commitFile(t, repo, "README.md", "hello")
+
+	t.Run("main working tree", func(t *testing.T) {
+		t.Parallel()
+		info, err := NewDetector().Detect(repo)
+		require.NoError(t, err)
+		require.Equal(t, repo, info.RootPath)
+		require.False(t, info.LinkedWorktree)
+		require.False(t, info.Submodule)
+	})
+
+	t.Run("linked worktree nested inside the main working tree", func(t *testing.T) {
+		t.Parallel()
+		wt := filepath.Join(repo, ".worktrees", "feature")
+		runGit(t, repo, "worktree", "add", "-b", "feature", wt)
+		sub := filepath.Join(wt, "pkg")
+		require.NoError(t, os.Mkdir(sub, 0o755))
+
+		info, err := NewDetector().Detect(sub)
+		require.NoError(t, err)
+		require.Equal(t, wt, info.RootPath)
+		require.Equal(t, "feature", info.RepoName)
+		require.Equal(t, "feature", info.Status.CurrentBranch)
+		require.True(t, info.LinkedWorktree)
+		require.False(t, info.Submodule)
+	})
+
+	t.Run("linked worktree elsewhere", func(t *testing.T) {
+		t.Parallel()
+		wt := filepath.Join(t.TempDir(), "elsewhere")
+		runGit(t, repo, "worktree", "add", "-b", "elsewhere", wt)
+
+		info, err := NewDetector().Detect(wt)
+		require.NoError(t, err)
+		require.Equal(t, wt, info.RootPath)
+		require.True(t, info.LinkedWorktree)
+	})
+
+	t.Run("submodule", func(t *testing.T) {
+		t.Parallel()
+		lib := initGitRepo(t)
+		commitFile(t, lib, "lib.go", "package lib")
+		super := initGitRepo(t)
+		commitFile(t, super, "main.go", "package main")
+		runGit(t, super, "-c", "protocol.file.allow=always", "submodule", "add", lib, "lib")
+
+		info, err := NewDetector().Detect(filepath.Join(super, "lib"))
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(super, "lib"), info.RootPath)
+		require.True(t, info.Submodule)
+		require.False(t, info.LinkedWorktree)
+	})
+}
+
+func TestGitChangedFiles(t *testing.T) {
+	t.Parallel()
+
+	repo := initGitRepo(t)
+	commitFile(t, repo, "a.txt", "a")
+	commitFile(t, repo, "b.txt", "b")
+
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "a.txt"), []byte("staged"), 0o644))
+	runGit(t, repo, "add", "a.txt")
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "b.txt"), []byte("modified"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "new file.txt"), []byte("new"), 0o644))
+
+	info, err := NewDetector().Detect(repo)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []FileStatus{
+		{Path: "a.txt", Code: FileStaged},
+		{Path: "b.txt", Code: FileUnstaged},
+		{Path: "new file.txt", Code: FileUntracked},
+	}, info.Status.ChangedFiles)
+	require.False(t, info.Status.ChangedFilesTruncated)
+}
+
+func TestJujutsuBookmarksKeyword(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{output: "jj 0.21.0\n", want: "branches"},
+		{output: "jj 0.22.0\n", want: "bookmarks"},
+		{output: "jj 0.28.2-ac4ab6d7d8f2e7b1\n", want: "bookmarks"},
+		{output: "jj 1.0.0\n", want: "bookmarks"},
+		{output: "garbage", want: "bookmarks"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.output, func(t *testing.T) {
+			t.Parallel()
+			major, minor, ok := parseJujutsuVersion(tt.output)
+			require.Equal(t, tt.want, bookmarksKeywordFor(major, minor, ok))
+		})
+	}
+}
+
+func TestJujutsuBookmarkName(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("jj"); err != nil {
+		t.Skip("jj is not installed")
+	}
+
+	repo := t.TempDir()
+	runJJ := func(args ...string) {
+		cmd := exec.Command("jj", args...)
+		cmd.Dir = repo
+		cmd.Env = append(os.Environ(), "JJ_USER=Crush Test", "JJ_EMAIL=crush@example.com")
+		output, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(output))
+	}
+	runJJ("git", "init")
+
+	// The change-id fallback names anonymous heads.
+	info, err := (&jujutsuDetector{}).Detect(repo)
+	require.NoError(t, err)
+	require.NotEmpty(t, info.Status.CurrentBranch)
+
+	if jujutsuBookmarksKeyword(t.Context(), osRunner{}, repo) == "bookmarks" {
+		runJJ("bookmark", "create", "feature", "-r", "@")
+	} else {
+		runJJ("branch", "create", "feature", "-r", "@")
+	}
+	info, err = (&jujutsuDetector{}).Detect(repo)
+	require.NoError(t, err)
+	require.Equal(t, "feature", info.Status.CurrentBranch)
+}
+
+func TestParseJujutsuFlags(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		output              string
+		conflict, divergent bool
+	}{
+		{output: "false false", conflict: false, divergent: false},
+		{output: "true false\n", conflict: true, divergent: false},
+		{output: "false true", conflict: false, divergent: true},
+		{output: "", conflict: false, divergent: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.output, func(t *testing.T) {
+			t.Parallel()
+			conflict, divergent := parseJujutsuFlags(tt.output)
+			require.Equal(t, tt.conflict, conflict)
+			require.Equal(t, tt.divergent, divergent)
+		})
+	}
+}
+
+func TestParseJujutsuCount(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, 0, parseJujutsuCount(""))
+	require.Equal(t, 2, parseJujutsuCount("qpvuntsm\nzzzzzzzz\n"))
+}
+
+func TestJujutsuCleanRepoState(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("jj"); err != nil {
+		t.Skip("jj is not installed")
+	}
+
+	repo := t.TempDir()
+	cmd := exec.Command("jj", "git", "init")
+	cmd.Dir = repo
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(output))
+
+	info, err := (&jujutsuDetector{}).Detect(repo)
+	require.NoError(t, err)
+	require.False(t, info.Status.HasConflicts)
+	require.False(t, info.Status.IsDivergent)
+	require.Zero(t, info.Status.AheadCount, "trunk() is the root without a trunk bookmark")
+	require.Zero(t, info.Status.BehindCount)
+}
+
+// fakeDetector reports a repository of its type wherever marker exists.
+type fakeDetector struct {
+	typ    Type
+	marker string
+}
+
+func (f fakeDetector) Detect(path string) (Info, error) {
+	return f.DetectContext(context.Background(), path)
+}
+
+func (f fakeDetector) DetectContext(_ context.Context, path string) (Info, error) {
+	rootPath, found := FindRoot(path, f.marker, DetectOptions{})
+	if !found {
+		return Info{Type: TypeNone}, nil
+	}
+	return Info{Type: f.typ, RootPath: rootPath}, nil
+}
+
+func TestNewDetectorWith(t *testing.T) {
+	t.Parallel()
+
+	pijul := fakeDetector{typ: "pijul", marker: ".pijul"}
+
+	t.Run("custom detector participates", func(t *testing.T) {
+		t.Parallel()
+		tmpDir := t.TempDir()
+		require.NoError(t, os.Mkdir(filepath.Join(tmpDir, ".pijul"), 0o755))
+
+		info, err := NewDetectorWith(NewGitDetector(DetectOptions{}), pijul).Detect(tmpDir)
+		require.NoError(t, err)
+		require.Equal(t, Type("pijul"), info.Type)
+		require.Equal(t, tmpDir, info.RootPath)
+	})
+
+	t.Run("order sets priority", func(t *testing.T) {
+		t.Parallel()
+		tmpDir := t.TempDir()
+		require.NoError(t, os.Mkdir(filepath.Join(tmpDir, ".pijul"), 0o755))
+		require.NoError(t, os.Mkdir(filepath.Join(tmpDir, ".hg"), 0o755))
+
+		info, err := NewDetectorWith(pijul, NewMercurialDetector()).Detect(tmpDir)
+		require.NoError(t, err)
+		require.Equal(t, Type("pijul"), info.Type)
+
+		info, err = NewDetectorWith(NewMercurialDetector(), pijul).Detect(tmpDir)
+		require.NoError(t, err)
+		require.Equal(t, TypeMercurial, info.Type)
+	})
+
+	t.Run("no detectors finds nothing", func(t *testing.T) {
+		t.Parallel()
+		info, err := NewDetectorWith().Detect(t.TempDir())
+		require.NoError(t, err)
+		require.Equal(t, TypeNone, info.Type)
+	})
+}
+
+func TestGitSkipOptions(t *testing.T) {
+	t.Parallel()
+
+	upstream := initGitRepo(t)
+	commitFile(t, upstream, "a.txt", "a")
+	repo := t.TempDir()
+	runGit(t, repo, "clone", upstream, ".")
+	commitFile(t, repo, "b.txt", "b")
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "b.txt"), []byte("stashed"), 0o644))
+	runGit(t, repo, "stash")
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "new.txt"), []byte("new"), 0o644))
+
+	info, err := DetectWithOptions(repo, DetectOptions{})
+	require.NoError(t, err)
+	require.Equal(t, 1, info.Status.AheadCount)
+	require.True(t, info.Status.HasUntracked)
+	require.Equal(t, 1, info.Status.StashCount)
+
+	opts := DetectOptions{SkipAheadBehind: true, SkipUntracked: true, SkipStash: true}
+	info, err = DetectWithOptions(repo, opts)
+	require.NoError(t, err)
+	require.True(t, info.Status.RemoteTrackingOK)
+	require.Zero(t, info.Status.AheadCount)
+	require.False(t, info.Status.HasUnpushed)
+	require.False(t, info.Status.HasUntracked)
+	require.True(t, info.Status.NothingToCommit)
+	require.Zero(t, info.Status.StashCount)
+	require.Empty(t, info.Status.TopStashDescription)
+
+	t.Run("legacy path", func(t *testing.T) {
+		t.Parallel()
+		legacy := Status{CurrentBranch: info.Status.CurrentBranch}
+		getGitWorkingTreeLegacy(t.Context(), osRunner{}, repo, opts, &legacy)
+		require.True(t, legacy.RemoteTrackingOK)
+		require.Zero(t, legacy.AheadCount)
+		require.False(t, legacy.HasUntracked)
+	})
+}
+
+func TestGitBranchOnly(t *testing.T) {
+	t.Parallel()
+
+	repo := initGitRepo(t)
+	commitFile(t, repo, "a.txt", "a")
+	runGit(t, repo, "checkout", "-b", "feature/fast")
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "new.txt"), []byte("new"), 0o644))
+
+	info, err := DetectWithOptions(repo, DetectOptions{BranchOnly: true})
+	require.NoError(t, err)
+	require.Equal(t, TypeGit, info.Type)
+	require.Equal(t, repo, info.RootPath)
+	require.Equal(t, filepath.Base(repo), info.RepoName)
+	require.Equal(t, Status{CurrentBranch: "feature/fast"}, info.Status)
+
+	head := strings.TrimSpace(runGit(t, repo, "rev-parse", "HEAD"))
+	runGit(t, repo, "checkout", "--detach")
+	info, err = DetectWithOptions(repo, DetectOptions{BranchOnly: true})
+	require.NoError(t, err)
+	require.Equal(t, Status{IsDetached: true, DetachedRef: head[:7]}, info.Status)
+}
+
+func TestParseGitHead(t *testing.T) {
+	t.Parallel()
+
+	const sha1 = "0123456789abcdef0123456789abcdef01234567"
+	sha256 := strings.Repeat("ab", 32)
+
+	tests := []struct {
+		name    string
+		content string
+		branch  string
+		commit  string
+		ok      bool
+	}{
+		{name: "branch", content: "ref: refs/heads/main\n", branch: "main", ok: true},
+		{name: "nested branch", content: "ref: refs/heads/feature/fast-head\n", branch: "feature/fast-head", ok: true},
+		{name: "detached sha1", content: sha1 + "\n", commit: sha1, ok: true},
+		{name: "detached sha256", content: sha256 + "\n", commit: sha256, ok: true},
+		{name: "reftable placeholder", content: "ref: refs/heads/.invalid\n"},
+		{name: "non-branch ref", content: "ref: refs/remotes/origin/main\n"},
+		{name: "short hash", content: "0123456\n"},
+		{name: "garbage", content: "not a head\n"},
+		{name: "empty", content: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			branch, commit, ok := parseGitHead(tt.content)
+			require.Equal(t, tt.branch, branch)
+			require.Equal(t, tt.commit, commit)
+			require.Equal(t, tt.ok, ok)
+		})
+	}
+}
+
+func TestReadGitHeadWorktree(t *testing.T) {
+	t.Parallel()
+
+	repo := initGitRepo(t)
+	commitFile(t, repo, "a.txt", "a")
+	worktree := filepath.Join(t.TempDir(), "wt")
+	runGit(t, repo, "worktree", "add", "-b", "wt-branch", worktree)
+
+	status := readGitHead(t.Context(), osRunner{}, worktree, resolveGitDir(worktree))
+	require.Equal(t, "wt-branch", status.CurrentBranch)
+
+	var legacy Status
+	getGitBranch(t.Context(), osRunner{}, worktree, resolveGitDir(worktree), &legacy)
+	require.Equal(t, "wt-branch", legacy.CurrentBranch)
+	require.False(t, legacy.IsUnborn)
+}
+
+func TestDetectWithoutTool(t *testing.T) {
+	gitRepo := initGitRepo(t)
+	commitFile(t, gitRepo, "a.txt", "a")
+	jjRepo := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(jjRepo, ".jj"), 0o755))
+
+	// Not parallel: the empty PATH must not leak into other tests.
+	t.Setenv("PATH", t.TempDir())
+
+	info, err := NewDetector().Detect(gitRepo)
+	require.NoError(t, err)
+	require.Equal(t, TypeGit, info.Type)
+	require.True(t, info.Status.ToolUnavailable)
+	require.NotEmpty(t, info.Status.CurrentBranch, "the branch is read from HEAD without git")
+	require.False(t, info.Status.NothingToCommit)
+
+	info, err = NewDetector().Detect(jjRepo)
+	require.NoError(t, err)
+	require.Equal(t, TypeJujutsu, info.Type)
+	require.True(t, info.Status.ToolUnavailable)
+}
+
+func TestDetectStopsAtCeiling(t *testing.T) {
+	outer := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(outer, ".git"), 0o755))
+	ceiling := filepath.Join(outer, "ceiling")
+	inner := filepath.Join(ceiling, "project", "src")
+	require.NoError(t, os.MkdirAll(inner, 0o755))
+
+	// Not parallel: the environment must not leak into other tests.
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GIT_CEILING_DIRECTORIES", "")
+
+	t.Run("GIT_CEILING_DIRECTORIES", func(t *testing.T) {
+		t.Setenv("GIT_CEILING_DIRECTORIES", "relative:"+t.TempDir()+string(filepath.ListSeparator)+ceiling)
+
+		info, err := NewDetector().Detect(inner)
+		require.NoError(t, err)
+		require.Equal(t, TypeNone, info.Type)
+		_, _, ok := RepoRootFor(inner)
+		require.False(t, ok)
+		_, _, ok = GitDir(inner)
+		require.False(t, ok)
+
+		// The starting directory isn't excluded, so the search from the
+		// ceiling itself goes on up, as in git.
+		info, err = NewDetector().Detect(ceiling)
+		require.NoError(t, err)
+		require.Equal(t, TypeGit, info.Type)
+		require.Equal(t, outer, info.RootPath)
+	})
+
+	t.Run("home directory", func(t *testing.T) {
+		t.Setenv("HOME", ceiling)
+
+		info, err := NewDetector().Detect(inner)
+		require.NoError(t, err)
+		require.Equal(t, TypeNone, info.Type)
+
+		info, err = DetectWithOptions(inner, DetectOptions{SearchAboveHome: true})
+		require.NoError(t, err)
+		require.Equal(t, TypeGit, info.Type)
+		require.Equal(t, outer, info.RootPath)
+
+		opts := DetectOptions{SearchAboveHome: true}
+		_, _, ok := RepoRootFor(inner)
+		require.False(t, ok)
+		root, typ, ok := RepoRootForWithOptions(inner, opts)
+		require.True(t, ok)
+		require.Equal(t, outer, root)
+		require.Equal(t, TypeGit, typ)
+		_, _, ok = GitDir(inner)
+		require.False(t, ok)
+		root, _, ok = GitDirWithOptions(inner, opts)
+		require.True(t, ok)
+		require.Equal(t, outer, root)
+		root, ok = FindRoot(inner, ".git", opts)
+		require.True(t, ok)
+		require.Equal(t, outer, root)
+
+		info, err = DetectOutermost(inner)
+		require.NoError(t, err)
+		require.Equal(t, TypeNone, info.Type)
+		info, err = DetectOutermostWithOptions(inner, opts)
+		require.NoError(t, err)
+		require.Equal(t, outer, info.RootPath)
+
+		counter := &countingDetector{inner: NewDetectorWithOptions(opts)}
+		cache := NewCachingDetectorWithOptions(counter, time.Hour, opts)
+		for range 2 {
+			info, err = cache.Detect(inner)
+			require.NoError(t, err)
+			require.Equal(t, outer, info.RootPath)
+		}
+		require.Equal(t, int32(1), counter.calls.Load())
+		stale, ok := cache.Stale(inner)
+		require.True(t, ok)
+		require.Equal(t, info, stale)
+	})
+
+	t.Run("old-style subversion checkout", func(t *testing.T) {
+		t.Setenv("GIT_CEILING_DIRECTORIES", ceiling)
+		svn := filepath.Join(ceiling, "svn")
+		for _, dir := range []string{ceiling, svn, filepath.Join(svn, "trunk")} {
+			require.NoError(t, os.MkdirAll(filepath.Join(dir, ".svn"), 0o755))
+		}
+		defer os.RemoveAll(filepath.Join(ceiling, ".svn"))
+
+		// The climb to the topmost .svn stops below the ceiling too.
+		root, typ, ok := RepoRootFor(filepath.Join(svn, "trunk"))
+		require.True(t, ok)
+		require.Equal(t, TypeSubversion, typ)
+		require.Equal(t, svn, root)
+		root, ok = FindRoot(filepath.Join(svn, "trunk"), ".svn", DetectOptions{})
+		require.True(t, ok)
+		require.Equal(t, svn, root)
+	})
+
+	t.Run("no ceiling", func(t *testing.T) {
+		info, err := NewDetector().Detect(inner)
+		require.NoError(t, err)
+		require.Equal(t, TypeGit, info.Type)
+		require.Equal(t, outer, info.RootPath)
+	})
+}
+
+func TestDetectBareRepository(t *testing.T) {
+	t.Parallel()
+
+	source := initGitRepo(t)
+	commitFile(t, source, "a.txt", "a")
+	bare := filepath.Join(t.TempDir(), "repo.git")
+	runGit(t, source, "clone", "--bare", source, bare)
+
+	for _, dir := range []string{bare, filepath.Join(bare, "refs", "heads")} {
+		info, err := NewDetector().Detect(dir)
+		require.NoError(t, err)
+		require.Equal(t, TypeGit, info.Type)
+		require.Equal(t, bare, info.RootPath)
+		require.Equal(t, "repo.git", info.RepoName)
+		require.True(t, info.Status.IsBare)
+		require.NotEmpty(t, info.Status.CurrentBranch)
+		require.False(t, info.Status.IsUnborn)
+		require.False(t, info.Status.LastCommitCommitTime.IsZero())
+		require.Nil(t, info.Status.Failure)
+		require.False(t, info.Status.NothingToCommit)
+	}
+
+	root, typ, ok := RepoRootFor(bare)
+	require.True(t, ok)
+	require.Equal(t, TypeGit, typ)
+	require.Equal(t, bare, root)
+
+	t.Run("git init --bare", func(t *testing.T) {
+		t.Parallel()
+		bare := t.TempDir()
+		runGit(t, bare, "init", "--bare")
+
+		info, err := NewDetector().Detect(bare)
+		require.NoError(t, err)
+		require.Equal(t, TypeGit, info.Type)
+		require.True(t, info.Status.IsBare)
+		require.True(t, info.Status.IsUnborn)
+	})
+
+	t.Run("not inside a regular git directory", func(t *testing.T) {
+		t.Parallel()
+		require.False(t, isBareGitDir(filepath.Join(source, ".git")))
+	})
+}
+
+func TestDetectShallowClone(t *testing.T) {
+	t.Parallel()
+
+	repo := initGitRepo(t)
+	commitFile(t, repo, "a.txt", "a")
+
+	info, err := NewDetector().Detect(repo)
+	require.NoError(t, err)
+	require.False(t, info.Status.IsShallow)
+
+	head := runGit(t, repo, "rev-parse", "HEAD")
+	require.NoError(t, os.WriteFile(filepath.Join(repo, ".git", "shallow"), []byte(head), 0o644))
+	info, err = NewDetector().Detect(repo)
+	require.NoError(t, err)
+	require.True(t, info.Status.IsShallow)
+}
+
+func TestTypeString(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "Git", TypeGit.String())
+	require.Equal(t, "Jujutsu", TypeJujutsu.String())
+	require.Equal(t, "Mercurial", TypeMercurial.String())
+	require.Equal(t, "Subversion", TypeSubversion.String())
+	require.Equal(t, "Fossil", TypeFossil.String())
+	require.Equal(t, "none", TypeNone.String())
+	require.Equal(t, "pijul", Type("pijul").String())
+}
+
+func TestParseType(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in   string
+		want Type
+	}{
+		{in: "git", want: TypeGit},
+		{in: "Git", want: TypeGit},
+		{in: "jj", want: TypeJujutsu},
+		{in: "jujutsu", want: TypeJujutsu},
+		{in: " JJ ", want: TypeJujutsu},
+		{in: "hg", want: TypeMercurial},
+		{in: "Mercurial", want: TypeMercurial},
+		{in: "svn", want: TypeSubversion},
+		{in: "subversion", want: TypeSubversion},
+		{in: "fossil", want: TypeFossil},
+		{in: "none", want: TypeNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			t.Parallel()
+			got, err := ParseType(tt.in)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+
+	for _, in := range []string{"", "cvs", "gitt", "pijul"} {
+		t.Run("unknown "+in, func(t *testing.T) {
+			t.Parallel()
+			got, err := ParseType(in)
+			require.ErrorIs(t, err, ErrUnknownType)
+			require.Equal(t, TypeNone, got)
+		})
+	}
+}
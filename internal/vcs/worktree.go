@@ -0,0 +1,37 @@
+package vcs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// PrunableWorktrees returns the paths of linked worktrees registered in the
+// repository at repoPath whose directories no longer exist. These are the
+// entries "git worktree prune" would remove.
+func PrunableWorktrees(repoPath string) ([]string, error) {
+	output, err := gitOutput(context.Background(), osRunner{}, repoPath, "worktree", "list", "--porcelain")
+	if err != nil {
+		return nil, fmt.Errorf("listing worktrees: %w", err)
+	}
+
+	var prunable []string
+	for _, path := range parseWorktreeList(output) {
+		if !fileExists(path) {
+			prunable = append(prunable, path)
+		}
+	}
+	return prunable, nil
+}
+
+// parseWorktreeList returns the worktree paths from
+// "git worktree list --porcelain" output, main worktree first.
+func parseWorktreeList(output string) []string {
+	var paths []string
+	for line := range strings.Lines(output) {
+		if path, ok := strings.CutPrefix(strings.TrimRight(line, "\n"), "worktree "); ok {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,41 @@
+package vcs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWorktreeList(t *testing.T) {
+	t.Parallel()
+
+	output := "worktree /src/crush\nHEAD 1111111111111111111111111111111111111111\nbranch refs/heads/main\n\n" +
+		"worktree /src/crush-feature\nHEAD 2222222222222222222222222222222222222222\nbranch refs/heads/feature\n\n" +
+		"worktree /tmp/gone\nHEAD 3333333333333333333333333333333333333333\ndetached\nprunable gitdir file points to non-existent location\n"
+
+	require.Equal(t, []string{"/src/crush", "/src/crush-feature", "/tmp/gone"}, parseWorktreeList(output))
+}
+
+func TestPrunableWorktrees(t *testing.T) {
+	t.Parallel()
+
+	repo := initGitRepo(t)
+	commitFile(t, repo, "README.md", "hello")
+
+	kept := filepath.Join(t.TempDir(), "kept")
+	removed := filepath.Join(t.TempDir(), "removed")
+	runGit(t, repo, "worktree", "add", "-b", "kept", kept)
+	runGit(t, repo, "worktree", "add", "-b", "removed", removed)
+
+	prunable, err := PrunableWorktrees(repo)
+	require.NoError(t, err)
+	require.Empty(t, prunable)
+
+	require.NoError(t, os.RemoveAll(removed))
+
+	prunable, err = PrunableWorktrees(repo)
+	require.NoError(t, err)
+	require.Equal(t, []string{removed}, prunable)
+}